@@ -0,0 +1,253 @@
+package gochain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// RunInfo identifies a chain, LLM or tool run. ParentRunID links a run to
+// the one that started it, including chains invoked from tool handlers
+// with the handler context.
+type RunInfo struct {
+	RunID       string
+	ParentRunID string
+	SessionID   string
+}
+
+type ChainStartEvent struct {
+	RunInfo
+	Input string
+}
+
+type ChainEndEvent struct {
+	RunInfo
+	Input    string
+	Results  []ToolResult
+	Err      error
+	Duration time.Duration
+}
+
+type LLMStartEvent struct {
+	RunInfo
+	Provider string
+	Model    string
+	Messages []Message
+	Options  map[string]interface{}
+}
+
+type LLMEndEvent struct {
+	RunInfo
+	Generation *Generation
+	Duration   time.Duration
+}
+
+type LLMErrorEvent struct {
+	RunInfo
+	Provider string
+	Err      error
+	Duration time.Duration
+}
+
+// ToolSelectedEvent lists the calls the model made. Handlers may edit,
+// drop or add calls before they run.
+type ToolSelectedEvent struct {
+	RunInfo
+	Calls []FunctionResponse
+}
+
+// ToolStartEvent is sent before a handler runs. Handlers may edit Call,
+// and returning an error from OnToolStart vetoes the call.
+type ToolStartEvent struct {
+	RunInfo
+	Call FunctionResponse
+}
+
+type ToolEndEvent struct {
+	RunInfo
+	Result   ToolResult
+	Duration time.Duration
+}
+
+type ToolErrorEvent struct {
+	RunInfo
+	Result   ToolResult
+	Duration time.Duration
+}
+
+type ParseErrorEvent struct {
+	RunInfo
+	Raw string
+	Err error
+}
+
+// CallbackHandler observes a chain run. Embed BaseCallbackHandler to
+// implement only some of the hooks. Tool calls of one turn run
+// concurrently, so handlers must be safe for use by multiple goroutines.
+type CallbackHandler interface {
+	OnChainStart(ctx context.Context, e *ChainStartEvent)
+	OnChainEnd(ctx context.Context, e *ChainEndEvent)
+	OnLLMStart(ctx context.Context, e *LLMStartEvent)
+	OnLLMEnd(ctx context.Context, e *LLMEndEvent)
+	OnLLMError(ctx context.Context, e *LLMErrorEvent)
+	OnToolSelected(ctx context.Context, e *ToolSelectedEvent) error
+	OnToolStart(ctx context.Context, e *ToolStartEvent) error
+	OnToolEnd(ctx context.Context, e *ToolEndEvent)
+	OnToolError(ctx context.Context, e *ToolErrorEvent)
+	OnParseError(ctx context.Context, e *ParseErrorEvent)
+}
+
+// RunContextHandler is implemented by callback handlers that carry runs in
+// the context, such as tracing handlers. RunContext is called after the
+// start hook of every chain, LLM and tool run, and the run goes on with the
+// returned context: the LLM request or tool handler, the runs it starts and
+// the remaining hooks of the run all get it.
+type RunContextHandler interface {
+	RunContext(ctx context.Context, info RunInfo) context.Context
+}
+
+type BaseCallbackHandler struct{}
+
+func (BaseCallbackHandler) OnChainStart(context.Context, *ChainStartEvent)           {}
+func (BaseCallbackHandler) OnChainEnd(context.Context, *ChainEndEvent)               {}
+func (BaseCallbackHandler) OnLLMStart(context.Context, *LLMStartEvent)               {}
+func (BaseCallbackHandler) OnLLMEnd(context.Context, *LLMEndEvent)                   {}
+func (BaseCallbackHandler) OnLLMError(context.Context, *LLMErrorEvent)               {}
+func (BaseCallbackHandler) OnToolSelected(context.Context, *ToolSelectedEvent) error { return nil }
+func (BaseCallbackHandler) OnToolStart(context.Context, *ToolStartEvent) error       { return nil }
+func (BaseCallbackHandler) OnToolEnd(context.Context, *ToolEndEvent)                 {}
+func (BaseCallbackHandler) OnToolError(context.Context, *ToolErrorEvent)             {}
+func (BaseCallbackHandler) OnParseError(context.Context, *ParseErrorEvent)           {}
+
+// callbacks fans events out to every handler in order. Veto hooks stop at
+// the first error.
+type callbacks []CallbackHandler
+
+func (c callbacks) chainStart(ctx context.Context, e *ChainStartEvent) {
+	for _, h := range c {
+		h.OnChainStart(ctx, e)
+	}
+}
+
+func (c callbacks) chainEnd(ctx context.Context, e *ChainEndEvent) {
+	for _, h := range c {
+		h.OnChainEnd(ctx, e)
+	}
+}
+
+func (c callbacks) llmStart(ctx context.Context, e *LLMStartEvent) {
+	for _, h := range c {
+		h.OnLLMStart(ctx, e)
+	}
+}
+
+func (c callbacks) llmEnd(ctx context.Context, e *LLMEndEvent) {
+	for _, h := range c {
+		h.OnLLMEnd(ctx, e)
+	}
+}
+
+func (c callbacks) llmError(ctx context.Context, e *LLMErrorEvent) {
+	for _, h := range c {
+		h.OnLLMError(ctx, e)
+	}
+}
+
+func (c callbacks) runContext(ctx context.Context, info RunInfo) context.Context {
+	for _, h := range c {
+		if rc, ok := h.(RunContextHandler); ok {
+			ctx = rc.RunContext(ctx, info)
+		}
+	}
+
+	return ctx
+}
+
+func (c callbacks) toolSelected(ctx context.Context, e *ToolSelectedEvent) error {
+	for _, h := range c {
+		if err := h.OnToolSelected(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c callbacks) toolStart(ctx context.Context, e *ToolStartEvent) error {
+	for _, h := range c {
+		if err := h.OnToolStart(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c callbacks) toolEnd(ctx context.Context, e *ToolEndEvent) {
+	for _, h := range c {
+		h.OnToolEnd(ctx, e)
+	}
+}
+
+func (c callbacks) toolError(ctx context.Context, e *ToolErrorEvent) {
+	for _, h := range c {
+		h.OnToolError(ctx, e)
+	}
+}
+
+func (c callbacks) parseError(ctx context.Context, e *ParseErrorEvent) {
+	for _, h := range c {
+		h.OnParseError(ctx, e)
+	}
+}
+
+type runIDKey struct{}
+
+// ContextWithRunID marks ctx as belonging to runID, so runs started with
+// it are recorded as its children.
+func ContextWithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+func RunIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// newRunID returns a random version 4 UUID.
+func newRunID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+
+	return string(s[:])
+}
+
+func (a *Chain) AddCallbackHandler(h CallbackHandler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Copy so running invocations keep their own list.
+	a.callbacks = append(callbacks{}, append(a.callbacks, h)...)
+}
+
+// WithCallbacks adds handlers for a single invocation, after the ones
+// registered on the Chain.
+func WithCallbacks(handlers ...CallbackHandler) InvokeOption {
+	return func(inv *invocation) {
+		inv.callbacks = append(append(callbacks{}, inv.callbacks...), handlers...)
+	}
+}
@@ -0,0 +1,305 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recorder records the hooks called and the run of each.
+type recorder struct {
+	gochain.BaseCallbackHandler
+
+	mu     sync.Mutex
+	events []string
+	runs   map[string]gochain.RunInfo
+}
+
+func (r *recorder) add(event string, info gochain.RunInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+	if r.runs == nil {
+		r.runs = map[string]gochain.RunInfo{}
+	}
+	r.runs[event] = info
+}
+
+func (r *recorder) OnChainStart(_ context.Context, e *gochain.ChainStartEvent) {
+	r.add("chain start", e.RunInfo)
+}
+
+func (r *recorder) OnChainEnd(_ context.Context, e *gochain.ChainEndEvent) {
+	r.add("chain end", e.RunInfo)
+}
+
+func (r *recorder) OnLLMStart(_ context.Context, e *gochain.LLMStartEvent) {
+	r.add("llm start", e.RunInfo)
+}
+
+func (r *recorder) OnLLMEnd(_ context.Context, e *gochain.LLMEndEvent) {
+	r.add("llm end", e.RunInfo)
+}
+
+func (r *recorder) OnLLMError(_ context.Context, e *gochain.LLMErrorEvent) {
+	r.add("llm error", e.RunInfo)
+}
+
+func (r *recorder) OnToolSelected(_ context.Context, e *gochain.ToolSelectedEvent) error {
+	r.add("tool selected", e.RunInfo)
+	return nil
+}
+
+func (r *recorder) OnToolStart(_ context.Context, e *gochain.ToolStartEvent) error {
+	r.add("tool start "+e.Call.Tool, e.RunInfo)
+	return nil
+}
+
+func (r *recorder) OnToolEnd(_ context.Context, e *gochain.ToolEndEvent) {
+	r.add("tool end "+e.Result.Tool, e.RunInfo)
+}
+
+func (r *recorder) OnToolError(_ context.Context, e *gochain.ToolErrorEvent) {
+	r.add("tool error "+e.Result.Tool, e.RunInfo)
+}
+
+func (r *recorder) OnParseError(_ context.Context, e *gochain.ParseErrorEvent) {
+	r.add("parse error", e.RunInfo)
+}
+
+func TestCallbackOrder(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Respond(
+		gochaintest.ToolCall("echo", map[string]interface{}{"text": "hi"}),
+		gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "hi"}),
+	)
+
+	chain := gochain.New(llm)
+	rec := &recorder{}
+	chain.AddCallbackHandler(rec)
+	if err := chain.RegisterTool("echo", "Echo", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		return input["text"], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Invoke(context.Background(), "say hi", gochain.WithSessionID("s1")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"chain start",
+		"llm start", "llm end", "tool selected", "tool start echo", "tool end echo",
+		"llm start", "llm end", "tool selected", "tool start conversationalResponse", "tool end conversationalResponse",
+		"chain end",
+	}
+	if !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("events = %q, want %q", rec.events, want)
+	}
+
+	chainRun := rec.runs["chain start"]
+	if chainRun.RunID == "" || chainRun.ParentRunID != "" || chainRun.SessionID != "s1" {
+		t.Errorf("chain run = %+v", chainRun)
+	}
+
+	if rec.runs["chain end"] != chainRun || rec.runs["tool selected"] != chainRun {
+		t.Errorf("chain events have different runs: %+v", rec.runs)
+	}
+
+	for _, event := range []string{"llm start", "tool start echo"} {
+		run := rec.runs[event]
+		if run.ParentRunID != chainRun.RunID || run.RunID == chainRun.RunID || run.SessionID != "s1" {
+			t.Errorf("%s run = %+v, want a child of %s", event, run, chainRun.RunID)
+		}
+	}
+
+	if rec.runs["llm start"] != rec.runs["llm end"] || rec.runs["tool start echo"] != rec.runs["tool end echo"] {
+		t.Error("start and end of a run have different run IDs")
+	}
+}
+
+func TestCallbackNestedChain(t *testing.T) {
+	reply := gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "ok"})
+	inner := gochain.New(gochaintest.NewFakeLLM().Default(reply))
+
+	outer := gochain.New(gochaintest.NewFakeLLM().Respond(gochaintest.ToolCall("ask", nil), reply))
+	if err := outer.RegisterTool("ask", "Ask the inner chain", nil, func(ctx context.Context, _ map[string]interface{}) (interface{}, error) {
+		return inner.Run(ctx, "inner question")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	outerRec, innerRec := &recorder{}, &recorder{}
+	inner.AddCallbackHandler(innerRec)
+	if err := outer.Invoke(context.Background(), "question", gochain.WithCallbacks(outerRec)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := innerRec.runs["chain start"].ParentRunID, outerRec.runs["tool start ask"].RunID; got != want {
+		t.Errorf("inner chain parent = %q, want the tool run %q", got, want)
+	}
+}
+
+// editor rewrites and vetoes calls.
+type editor struct {
+	gochain.BaseCallbackHandler
+}
+
+func (editor) OnToolSelected(_ context.Context, e *gochain.ToolSelectedEvent) error {
+	for i := range e.Calls {
+		if e.Calls[i].Tool == "old" {
+			e.Calls[i].Tool = "new"
+		}
+	}
+
+	return nil
+}
+
+func (editor) OnToolStart(_ context.Context, e *gochain.ToolStartEvent) error {
+	if e.Call.Tool == "delete" {
+		return errors.New("not allowed")
+	}
+
+	e.Call.ToolInput = map[string]interface{}{"edited": true}
+
+	return nil
+}
+
+func TestCallbackEditsAndVetoes(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.Text(`[{"tool":"old","toolInput":{}},{"tool":"delete","toolInput":{}}]`))
+
+	chain := gochain.New(llm)
+	chain.AddCallbackHandler(editor{})
+
+	var input map[string]interface{}
+	if err := chain.RegisterTool("new", "", nil, func(_ context.Context, in map[string]interface{}) (interface{}, error) {
+		input = in
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.RegisterTool("delete", "", nil, nop); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "do it")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Tool != "new" || results[0].Err != nil || input["edited"] != true {
+		t.Errorf("edited call result = %+v, handler input %v", results[0], input)
+	}
+
+	if results[1].Tool != "delete" || !errors.Is(results[1].Err, gochain.ErrToolVetoed) {
+		t.Errorf("vetoed call result = %+v", results[1])
+	}
+}
+
+// vetoAll rejects every turn.
+type vetoAll struct {
+	gochain.BaseCallbackHandler
+}
+
+func (vetoAll) OnToolSelected(context.Context, *gochain.ToolSelectedEvent) error {
+	return errors.New("read only")
+}
+
+func TestCallbackVetoesTurn(t *testing.T) {
+	called := false
+	chain := gochain.New(gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("write", nil)))
+	if err := chain.RegisterTool("write", "", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{}
+	err := chain.Invoke(context.Background(), "write it", gochain.WithCallbacks(vetoAll{}, rec))
+	if !errors.Is(err, gochain.ErrToolVetoed) || called {
+		t.Errorf("err = %v, handler called %v", err, called)
+	}
+
+	// Handlers after the one that vetoed are not asked.
+	for _, event := range rec.events {
+		if event == "tool selected" || event == "tool start write" {
+			t.Errorf("got %q after the veto", event)
+		}
+	}
+}
+
+func TestCallbackErrors(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Respond(gochaintest.Text("not json"), gochaintest.Error(errors.New("down")))
+
+	chain := gochain.New(llm)
+	rec := &recorder{}
+	chain.AddCallbackHandler(rec)
+
+	for i := 0; i < 2; i++ {
+		if err := chain.Invoke(context.Background(), "hi"); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	want := []string{
+		"chain start", "llm start", "llm end", "parse error", "chain end",
+		"chain start", "llm start", "llm error", "chain end",
+	}
+	if !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("events = %q, want %q", rec.events, want)
+	}
+}
+
+type runKey struct{}
+
+// contextRecorder puts the run ID in the context under runKey.
+type contextRecorder struct {
+	recorder
+}
+
+func (*contextRecorder) RunContext(ctx context.Context, info gochain.RunInfo) context.Context {
+	return context.WithValue(ctx, runKey{}, info.RunID)
+}
+
+// runLLM answers with the run in its context.
+type runLLM struct{}
+
+func (runLLM) Name() string {
+	return "run"
+}
+
+func (runLLM) Chat(ctx context.Context, _ []gochain.Message, _ ...map[string]interface{}) (string, error) {
+	return `{"tool":"conversationalResponse","toolInput":{"response":"` + ctx.Value(runKey{}).(string) + `"}}`, nil
+}
+
+func TestRunContextHandler(t *testing.T) {
+	chain := gochain.New(runLLM{})
+	rec := &contextRecorder{}
+	chain.AddCallbackHandler(rec)
+
+	// The fallback handler answers with the run of its own context.
+	if err := chain.SetFallbackFunction(&gochain.Function{
+		Name: "conversationalResponse",
+		Handler: func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			return input["response"].(string) + " " + ctx.Value(runKey{}).(string), nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := rec.runs["llm start"].RunID + " " + rec.runs["tool start conversationalResponse"].RunID
+	if len(results) != 1 || results[0].Output != want {
+		t.Errorf("results = %+v, want the LLM and tool runs %q", results, want)
+	}
+}
@@ -0,0 +1,145 @@
+// Package cassette records HTTP exchanges and LLM calls to fixture files
+// and replays them, so tests run offline and deterministically.
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("cassette: no recorded interaction matches the request")
+	ErrNoLLM               = errors.New("cassette: recording needs an LLM")
+)
+
+const redacted = "[REDACTED]"
+
+// Mode selects between recording and replaying.
+type Mode int
+
+const (
+	// ModeAuto replays recorded interactions and records the requests that
+	// have none.
+	ModeAuto Mode = iota
+	// ModeReplay only replays and fails requests that were not recorded.
+	ModeReplay
+	// ModeRecord sends every request and records it, replacing the file.
+	ModeRecord
+)
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response holds the whole body, including streamed NDJSON or SSE bodies.
+// A replayed body is available at once, so streaming readers get the same
+// lines as were received, without the original timing.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Option func(*options)
+
+type options struct {
+	mode      Mode
+	matchers  []Matcher
+	headers   []string
+	scrubbers []func(*Interaction)
+}
+
+func newOptions(opts []Option) options {
+	o := options{headers: []string{"Authorization"}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.matchers) == 0 {
+		o.matchers = []Matcher{MatchMethod, MatchURL, MatchBody}
+	}
+
+	return o
+}
+
+func WithMode(mode Mode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithMatchers replaces the default rules, which compare the method, URL
+// and body.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(o *options) {
+		o.matchers = matchers
+	}
+}
+
+// WithScrubHeaders redacts more headers besides Authorization in recorded
+// requests and responses.
+func WithScrubHeaders(names ...string) Option {
+	return func(o *options) {
+		o.headers = append(o.headers, names...)
+	}
+}
+
+// WithScrubber rewrites every interaction before it is saved, for example
+// to remove secrets from bodies.
+func WithScrubber(scrub func(*Interaction)) Option {
+	return func(o *options) {
+		o.scrubbers = append(o.scrubbers, scrub)
+	}
+}
+
+func (o options) scrub(i *Interaction) {
+	for _, name := range o.headers {
+		for _, h := range []http.Header{i.Request.Header, i.Response.Header} {
+			if h.Get(name) != "" {
+				h.Set(name, redacted)
+			}
+		}
+	}
+
+	for _, scrub := range o.scrubbers {
+		scrub(i)
+	}
+}
+
+// readFile decodes a fixture file. A missing file is an empty cassette.
+func readFile(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, v)
+}
+
+func writeFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
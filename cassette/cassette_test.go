@@ -0,0 +1,216 @@
+package cassette_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/cassette"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/ollama"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const streamBody = `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":"lo"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":"!"},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":3}
+`
+
+var messages = []gochain.Message{{Role: "user", Content: "Say hello"}}
+
+func stream(t *testing.T, client *http.Client, baseURL string) ([]string, *gochain.Generation) {
+	t.Helper()
+
+	llm, err := ollama.New(baseURL, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.SetModel("llama3")
+
+	var chunks []string
+	g, err := gochain.Stream(context.Background(), llm, messages, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chunks, g
+}
+
+func TestReplayOllamaStream(t *testing.T) {
+	fixture, err := json.Marshal(map[string]interface{}{"interactions": []cassette.Interaction{{
+		Request: cassette.Request{Method: http.MethodPost, URL: "http://ollama.test/api/chat"},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/x-ndjson"}},
+			Body:       streamBody,
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "stream.json")
+	if err := os.WriteFile(path, fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := cassette.New(path, failingTransport{t}, cassette.WithMode(cassette.ModeReplay),
+		cassette.WithMatchers(cassette.MatchMethod, cassette.MatchURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, g := stream(t, recorder.Client(), "http://ollama.test")
+
+	if want := []string{"Hel", "lo", "!"}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q, want %q", chunks, want)
+	}
+
+	if g.Content != "Hello!" || g.FinishReason != "stop" {
+		t.Errorf("generation = %+v", g)
+	}
+
+	if g.Usage.PromptTokens != 5 || g.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v", g.Usage)
+	}
+}
+
+func TestRecordThenReplayOllamaStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range strings.SplitAfter(streamBody, "\n") {
+			_, _ = w.Write([]byte(line))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	path := filepath.Join(t.TempDir(), "stream.json")
+
+	recorder, err := cassette.New(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, _ := stream(t, recorder.Client(), server.URL)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	replayer, err := cassette.New(path, failingTransport{t}, cassette.WithMode(cassette.ModeReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, g := stream(t, replayer.Client(), server.URL)
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed chunks %q, recorded %q", replayed, recorded)
+	}
+
+	if g.Content != "Hello!" {
+		t.Errorf("content = %q", g.Content)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	recorder, err := cassette.New(filepath.Join(t.TempDir(), "empty.json"), failingTransport{t}, cassette.WithMode(cassette.ModeReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	llm, err := ollama.New("http://ollama.test", recorder.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := llm.Chat(context.Background(), messages); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Errorf("err = %v, want ErrInteractionNotFound", err)
+	}
+}
+
+func TestLLMRejectsIncompleteFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.json")
+	fixture := `{"calls": [{"messages": [{"role": "user", "content": "hi"}]}]}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cassette.NewLLM(nil, path, cassette.WithMode(cassette.ModeReplay)); err == nil {
+		t.Fatal("expected an error for a call without generation or error")
+	}
+}
+
+func TestLLMReplaysProviderError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.json")
+	limited := &gochain.ProviderError{Provider: "ollama", StatusCode: 429, Body: "slow down", RetryAfter: 2 * time.Second}
+	missing := &gochain.ProviderError{Provider: "ollama", StatusCode: 404, Cause: errors.New("model not found")}
+
+	fake := gochaintest.NewFakeLLM().Respond(
+		gochaintest.Error(limited),
+		gochaintest.Error(fmt.Errorf("attempt 2: %w", missing)),
+		gochaintest.Error(errors.New("boom")),
+	)
+
+	recorder, err := cassette.NewLLM(fake, path, cassette.WithMode(cassette.ModeRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recorded []error
+	for _, content := range []string{"a", "b", "c"} {
+		_, err := recorder.Chat(context.Background(), []gochain.Message{{Role: "user", Content: content}})
+		recorded = append(recorded, err)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	player, err := cassette.NewLLM(nil, path, cassette.WithMode(cassette.ModeReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, content := range []string{"a", "b", "c"} {
+		_, err := player.Chat(context.Background(), []gochain.Message{{Role: "user", Content: content}})
+		if err == nil || err.Error() != recorded[i].Error() {
+			t.Fatalf("replayed %v, recorded %v", err, recorded[i])
+		}
+
+		var want, got *gochain.ProviderError
+		if errors.As(recorded[i], &want) != errors.As(err, &got) {
+			t.Fatalf("replayed %T, recorded %T", err, recorded[i])
+		}
+
+		if want != nil && (got.Provider != want.Provider || got.StatusCode != want.StatusCode || got.Body != want.Body || got.RetryAfter != want.RetryAfter) {
+			t.Errorf("replayed %+v, recorded %+v", got, want)
+		}
+	}
+}
+
+func TestLLMRecordNeedsLLM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.json")
+	if _, err := cassette.NewLLM(nil, path, cassette.WithMode(cassette.ModeRecord)); !errors.Is(err, cassette.ErrNoLLM) {
+		t.Errorf("err = %v, want ErrNoLLM", err)
+	}
+}
+
+// failingTransport fails the test when a request reaches the network.
+type failingTransport struct {
+	t *testing.T
+}
+
+func (f failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.t.Errorf("unexpected request to %s", req.URL)
+	return nil, errors.New("network disabled")
+}
@@ -0,0 +1,232 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Call is a recorded LLM request and its outcome. ProviderError is set
+// when Error came from a gochain.ProviderError, so the replayed error
+// keeps its type.
+type Call struct {
+	Messages      []gochain.Message        `json:"messages"`
+	Options       []map[string]interface{} `json:"options,omitempty"`
+	Generation    *gochain.Generation      `json:"generation,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+	ProviderError *ProviderError           `json:"providerError,omitempty"`
+}
+
+// ProviderError is the recorded form of a gochain.ProviderError. Cause
+// holds the message of its cause.
+type ProviderError struct {
+	Provider   string        `json:"provider,omitempty"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Body       string        `json:"body,omitempty"`
+	RetryAfter time.Duration `json:"retryAfter,omitempty"`
+	Cause      string        `json:"cause,omitempty"`
+}
+
+func recordError(call *Call, err error) {
+	call.Error = err.Error()
+
+	var pe *gochain.ProviderError
+	if errors.As(err, &pe) {
+		call.ProviderError = &ProviderError{
+			Provider:   pe.Provider,
+			StatusCode: pe.StatusCode,
+			Body:       pe.Body,
+			RetryAfter: pe.RetryAfter,
+		}
+		if pe.Cause != nil {
+			call.ProviderError.Cause = pe.Cause.Error()
+		}
+	}
+}
+
+// replayError rebuilds a recorded error. A provider error comes back as a
+// *gochain.ProviderError, wrapped when the recorded message had more
+// context around it.
+func replayError(call *Call) error {
+	if call.ProviderError == nil {
+		return errors.New(call.Error)
+	}
+
+	pe := &gochain.ProviderError{
+		Provider:   call.ProviderError.Provider,
+		StatusCode: call.ProviderError.StatusCode,
+		Body:       call.ProviderError.Body,
+		RetryAfter: call.ProviderError.RetryAfter,
+	}
+	if call.ProviderError.Cause != "" {
+		pe.Cause = errors.New(call.ProviderError.Cause)
+	}
+
+	if pe.Error() == call.Error {
+		return pe
+	}
+
+	return &wrappedError{msg: call.Error, err: pe}
+}
+
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+type llmFile struct {
+	Calls []*Call `json:"calls"`
+}
+
+// LLM records and replays calls at the gochain.LLM level, for tests that
+// do not care about the HTTP exchange. Only WithMode applies to it.
+type LLM struct {
+	llm  gochain.LLM
+	path string
+	mode Mode
+
+	mu    sync.Mutex
+	calls []*Call
+	used  map[*Call]bool
+	dirty bool
+}
+
+// NewLLM opens the cassette at path. llm may be nil, except in ModeRecord,
+// in which case requests without a recorded call fail with
+// ErrInteractionNotFound.
+func NewLLM(llm gochain.LLM, path string, opts ...Option) (*LLM, error) {
+	o := newOptions(opts)
+	if llm == nil && o.mode == ModeRecord {
+		return nil, ErrNoLLM
+	}
+
+	l := &LLM{llm: llm, path: path, mode: o.mode, used: map[*Call]bool{}}
+
+	if o.mode != ModeRecord {
+		var f llmFile
+		if _, err := readFile(path, &f); err != nil {
+			return nil, err
+		}
+
+		for i, c := range f.Calls {
+			if c == nil || (c.Generation == nil && c.Error == "") {
+				return nil, fmt.Errorf("cassette: %s: call %d has neither a generation nor an error", path, i)
+			}
+		}
+		l.calls = f.Calls
+	}
+
+	return l, nil
+}
+
+func (l *LLM) Name() string {
+	if l.llm == nil {
+		return "cassette"
+	}
+
+	return l.llm.Name()
+}
+
+// JSONMode forwards to the recorded LLM. Without one, as in ModeReplay,
+// calls are replayed with the options the chain passes.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	if l.llm != nil {
+		gochain.EnableJSONMode(l.llm, options)
+	}
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	// Round trip through JSON so the call compares equal to one read back
+	// from a fixture.
+	call := &Call{}
+	data, err := json.Marshal(Call{Messages: messages, Options: options})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, call); err != nil {
+		return nil, err
+	}
+
+	if l.mode != ModeRecord {
+		if recorded := l.match(call); recorded != nil {
+			if recorded.Error != "" {
+				return nil, replayError(recorded)
+			}
+
+			g := *recorded.Generation
+			return &g, nil
+		}
+
+		if l.mode == ModeReplay || l.llm == nil {
+			return nil, fmt.Errorf("%w: %d messages", ErrInteractionNotFound, len(messages))
+		}
+	}
+
+	g, err := gochain.Generate(ctx, l.llm, messages, options...)
+	if err != nil {
+		recordError(call, err)
+	} else {
+		call.Generation = g
+	}
+
+	l.mu.Lock()
+	l.calls = append(l.calls, call)
+	l.used[call] = true
+	l.dirty = true
+	l.mu.Unlock()
+
+	return g, err
+}
+
+func (l *LLM) match(call *Call) *Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, c := range l.calls {
+		if l.used[c] || !reflect.DeepEqual(c.Messages, call.Messages) || !reflect.DeepEqual(c.Options, call.Options) {
+			continue
+		}
+
+		l.used[c] = true
+
+		return c
+	}
+
+	return nil
+}
+
+// Close saves the cassette when new calls were recorded.
+func (l *LLM) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+
+	l.dirty = false
+
+	return writeFile(l.path, llmFile{Calls: l.calls})
+}
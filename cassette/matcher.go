@@ -0,0 +1,54 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// Matcher reports whether a recorded request matches an incoming one.
+type Matcher func(r *http.Request, body []byte, recorded Request) bool
+
+func MatchMethod(r *http.Request, _ []byte, recorded Request) bool {
+	return r.Method == recorded.Method
+}
+
+func MatchURL(r *http.Request, _ []byte, recorded Request) bool {
+	return r.URL.String() == recorded.URL
+}
+
+// MatchPath ignores the host and query, for servers started on random
+// ports.
+func MatchPath(r *http.Request, _ []byte, recorded Request) bool {
+	req, err := http.NewRequest(recorded.Method, recorded.URL, nil)
+	return err == nil && req.URL.Path == r.URL.Path
+}
+
+func MatchBody(_ *http.Request, body []byte, recorded Request) bool {
+	return bytes.Equal(body, []byte(recorded.Body))
+}
+
+// MatchJSONBody compares bodies as JSON values, ignoring key order and
+// formatting.
+func MatchJSONBody(_ *http.Request, body []byte, recorded Request) bool {
+	var a, b interface{}
+	if json.Unmarshal(body, &a) != nil || json.Unmarshal([]byte(recorded.Body), &b) != nil {
+		return bytes.Equal(body, []byte(recorded.Body))
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// MatchHeader compares the given headers.
+func MatchHeader(names ...string) Matcher {
+	return func(r *http.Request, _ []byte, recorded Request) bool {
+		for _, name := range names {
+			if r.Header.Get(name) != recorded.Header.Get(name) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
@@ -0,0 +1,192 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type file struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper recording to and replaying from a
+// fixture file. Use it as the Transport of the client given to a backend
+// and call Close to save new recordings.
+type Recorder struct {
+	path      string
+	transport http.RoundTripper
+	opts      options
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         map[*Interaction]bool
+	dirty        bool
+}
+
+// New opens the cassette at path. Requests that are not replayed go
+// through transport, http.DefaultTransport when nil.
+func New(path string, transport http.RoundTripper, opts ...Option) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	r := &Recorder{path: path, transport: transport, opts: newOptions(opts), used: map[*Interaction]bool{}}
+
+	if r.opts.mode != ModeRecord {
+		var f file
+		if _, err := readFile(path, &f); err != nil {
+			return nil, err
+		}
+		r.interactions = f.Interactions
+	}
+
+	return r, nil
+}
+
+// Client returns an http.Client using the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.opts.mode != ModeRecord {
+		if i := r.match(req, body); i != nil {
+			return replay(req, i), nil
+		}
+
+		if r.opts.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   string(body),
+		},
+		Response: Response{StatusCode: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+
+	// Tee the body so streamed responses still reach the caller as they
+	// arrive; the interaction is kept once the body is closed.
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(data []byte) {
+		i.Response.Body = string(data)
+		r.add(i)
+	}}
+
+	return resp, nil
+}
+
+func (r *Recorder) match(req *http.Request, body []byte) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.interactions {
+		if r.used[i] || !r.matches(req, body, i.Request) {
+			continue
+		}
+
+		r.used[i] = true
+
+		return i
+	}
+
+	return nil
+}
+
+func (r *Recorder) matches(req *http.Request, body []byte, recorded Request) bool {
+	for _, m := range r.opts.matchers {
+		if !m(req, body, recorded) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) add(i *Interaction) {
+	r.opts.scrub(i)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = append(r.interactions, i)
+	r.used[i] = true
+	r.dirty = true
+}
+
+func replay(req *http.Request, i *Interaction) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}
+}
+
+// Close saves the cassette when new interactions were recorded.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+
+	r.dirty = false
+
+	return writeFile(r.path, file{Interactions: r.interactions})
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+
+	return n, err
+}
+
+// Close drains what the caller left unread so the recording is complete.
+func (b *recordingBody) Close() error {
+	io.Copy(&b.buf, b.ReadCloser)
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+
+	return err
+}
@@ -1,126 +1,671 @@
 package gochain
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"github.com/ryanbekhen/gochain/internal/prompt"
-	"sort"
-	"strings"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
+const defaultMaxSteps = 5
+
 type Chain struct {
+	mu sync.RWMutex
+	settings
+}
+
+// settings is the configuration of a Chain. Invocations work on a copy so
+// the Chain can be reconfigured while they run.
+type settings struct {
 	llm         LLM
 	fnPrompt    string
 	fn          []*Function
+	fallback    *Function
 	convHandler ConversationalFunctionHandler
+	memory      Memory
+	toolPrompt  PromptFormatter
+	examples    ExampleSelector
+	retriever   ToolRetriever
+	callbacks   callbacks
+	logger      *logHandler
+
+	tokenizer      Tokenizer
+	contextLength  int
+	responseTokens int
+
+	nativeTools    bool
+	maxConcurrency int
+	maxSteps       int
 }
 
 func New(llm LLM) *Chain {
-	return &Chain{
-		llm: llm,
-		fn: []*Function{
-			{
-				Name:        "conversationalResponse",
-				Description: "Respond conversationally if no other tools should be called for a given query.",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"response": map[string]interface{}{
-							"type":        "string",
-							"description": "Conversational response to the user with using same language.",
-						},
-					},
-					"required": []string{"response"},
-				},
-			},
-		},
-	}
-}
-
-func (a *Chain) RegisterFunction(name string, description string, parameters interface{}, fn FunctionHandler) {
-	sort.Slice(a.fn, func(i, j int) bool {
-		n1 := a.fn[i].Name
-		n2 := a.fn[j].Name
-		return n1 != "conversationalResponse" && (n2 == "conversationalResponse" || n1 < n2)
-	})
-
-	a.fn = append(a.fn, &Function{
-		Name:        name,
-		Description: description,
-		Parameters:  parameters,
-		Function:    fn,
-	})
+	return &Chain{settings: settings{
+		llm:      llm,
+		maxSteps: defaultMaxSteps,
+		fallback: DefaultFallbackFunction(),
+	}}
 }
 
+// RegisterConversationalFunction sets a handler notified with the output
+// of the fallback function when it is a string.
+//
+// Deprecated: the output is the result of the fallback call returned by
+// Run.
 func (a *Chain) RegisterConversationalFunction(h ConversationalFunctionHandler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.convHandler = h
 }
 
-func (a *Chain) Invoke(ctx context.Context, message string) error {
-	functions, err := json.Marshal(a.fn)
-	if err != nil {
+func (a *Chain) SetMemory(m Memory) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.memory = m
+}
+
+// SetToolSelectionPrompt replaces the system prompt that asks the model to
+// pick a tool. It is rendered with "functions", the JSON encoded function
+// list, and "tools", the []*Function themselves.
+func (a *Chain) SetToolSelectionPrompt(p PromptFormatter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.toolPrompt = p
+}
+
+// SetExampleSelector chooses which registered examples are shown to the
+// model. Without one every example of the offered functions is used.
+func (a *Chain) SetExampleSelector(s ExampleSelector) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.examples = s
+}
+
+// SetToolRetriever narrows the functions offered to the model to the ones
+// r retrieves for each message. The fallback function is always offered.
+func (a *Chain) SetToolRetriever(r ToolRetriever) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.retriever = r
+}
+
+func (a *Chain) SetTokenizer(t Tokenizer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tokenizer = t
+}
+
+// SetContextWindow trims registered functions and history so each prompt
+// leaves responseTokens free in a context of contextLength tokens. Zero
+// disables trimming.
+func (a *Chain) SetContextWindow(contextLength, responseTokens int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.contextLength = contextLength
+	a.responseTokens = responseTokens
+}
+
+// SetNativeToolCalling makes the chain use the backend tool calling API
+// instead of the tool selection prompt when the LLM is a ToolCallingLLM.
+func (a *Chain) SetNativeToolCalling(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.nativeTools = enabled
+}
+
+// SetMaxConcurrency limits how many tool calls of a single model turn run
+// at once. Zero runs them all concurrently.
+func (a *Chain) SetMaxConcurrency(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maxConcurrency = n
+}
+
+// SetMaxSteps limits how many times tool outputs are sent back to the
+// model before the chain gives up with ErrMaxSteps.
+func (a *Chain) SetMaxSteps(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maxSteps = n
+}
+
+// Invoke answers message and returns the errors of the tool calls. Use Run
+// to get their outputs, including the reply of the fallback function.
+func (a *Chain) Invoke(ctx context.Context, message string, opts ...InvokeOption) error {
+	results, err := a.Run(ctx, message, opts...)
+	if len(results) == 0 {
 		return err
 	}
 
-	promptContent := strings.Replace(prompt.FunctionsToCall, "{functionsToCall}", string(functions), -1)
+	errs := make([]error, 0, len(results)+1)
+	errs = append(errs, err)
+	for _, r := range results {
+		errs = append(errs, r.Err)
+	}
+
+	return errors.Join(errs...)
+}
 
-	opts := map[string]interface{}{}
+// Run answers message and returns the results of the last tool calls made
+// by the model, in the order the model made them. When the model answers
+// through the fallback function, its reply is the Output of the last
+// result. It is safe to call concurrently and with concurrent
+// registrations.
+//
+// The tools have already run when saving the turn to memory fails, so Run
+// then returns the results along with an error wrapping ErrMemorySave. The
+// same goes for ErrMaxSteps, returned with the last results when the model
+// still had tool outputs to read after the last step.
+func (a *Chain) Run(ctx context.Context, message string, opts ...InvokeOption) ([]ToolResult, error) {
+	a.mu.RLock()
+	s := a.settings
+	a.mu.RUnlock()
 
-	if a.llm.Name() == "ollama" {
-		opts["format"] = "json"
+	inv := newInvocation(s, opts)
+	if inv.logger != nil {
+		inv.callbacks = append(append(callbacks{}, inv.callbacks...), inv.logger)
 	}
+	inv.parentRunID = RunIDFromContext(ctx)
+	inv.runID = newRunID()
+	ctx = ContextWithRunID(ctx, inv.runID)
+
+	start := time.Now()
+	inv.callbacks.chainStart(ctx, &ChainStartEvent{RunInfo: inv.runInfo(), Input: message})
+	ctx = inv.callbacks.runContext(ctx, inv.runInfo())
+
+	results, err := inv.run(ctx, message)
+
+	inv.callbacks.chainEnd(ctx, &ChainEndEvent{
+		RunInfo:  inv.runInfo(),
+		Input:    message,
+		Results:  results,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+
+	return results, err
+}
 
-	response, err := a.llm.Chat(ctx, []Message{
-		{Role: "system", Content: promptContent},
-		{Role: "user", Content: message},
-	}, opts)
+func (inv *invocation) runInfo() RunInfo {
+	return RunInfo{RunID: inv.runID, ParentRunID: inv.parentRunID, SessionID: inv.sessionID}
+}
+
+// childRunInfo identifies a new LLM or tool run started by the invocation.
+func (inv *invocation) childRunInfo() RunInfo {
+	return RunInfo{RunID: newRunID(), ParentRunID: inv.runID, SessionID: inv.sessionID}
+}
+
+func (inv *invocation) run(ctx context.Context, message string) ([]ToolResult, error) {
+	userMessage := Message{Role: "user", Content: message}
+
+	fn, err := inv.offeredFunctions(ctx, message, userMessage)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	history := inv.history
+	useMemory := inv.memory != nil && !inv.hasHistory
+	if useMemory {
+		history, err = inv.memory.Load(ctx, inv.sessionID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	fr, err := a.parseResponse(response)
+	examples, err := inv.selectExamples(ctx, message, fn)
 	if err != nil {
-		return ErrInvalidResponse
+		return nil, err
 	}
 
-	if fr.Tool == "conversationalResponse" {
-		resp, ok := fr.ToolInput["response"].(string)
-		if !ok {
-			return ErrInvalidResponse
+	var messages []Message
+	if !inv.useNativeTools() {
+		promptContent, err := inv.renderToolPrompt(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, Message{Role: "system", Content: promptContent})
+	}
+
+	messages = append(messages, examples...)
+	messages = append(messages, history...)
+
+	// turn holds the messages added by this invocation, which are the ones
+	// saved to memory.
+	turn := []Message{userMessage}
+
+	var results []ToolResult
+	var stepErr error
+	for step := 0; ; step++ {
+		prompt := append(messages[:len(messages):len(messages)], turn...)
+		if inv.contextLength > 0 {
+			prompt = TrimMessages(inv.getTokenizer(), prompt, inv.contextLength-inv.responseTokens)
+		}
+
+		reply, calls, err := inv.complete(ctx, prompt, fn)
+		if err != nil {
+			return nil, err
 		}
 
-		if a.convHandler == nil {
-			return ErrConversationalHandlerNotSet
+		turn = append(turn, reply)
+		results = inv.execute(ctx, fn, calls)
+
+		if !inv.needsFollowUp(results) {
+			break
 		}
 
-		a.convHandler(resp)
+		if step+1 >= inv.maxSteps {
+			stepErr = ErrMaxSteps
+			break
+		}
 
-		return nil
+		turn = append(turn, toolResultMessage(results))
 	}
 
-	handler, err := a.getHandler(fr.Tool)
+	if useMemory {
+		if err := inv.memory.Save(ctx, inv.sessionID, turn...); err != nil {
+			return results, errors.Join(stepErr, fmt.Errorf("%w: %w", ErrMemorySave, err))
+		}
+	}
+
+	return results, stepErr
+}
+
+// offeredFunctions returns the functions to show the model for message:
+// the registered ones narrowed by the tool filters, the retriever and the
+// context window, followed by the fallback.
+func (inv *invocation) offeredFunctions(ctx context.Context, message string, userMessage Message) ([]*Function, error) {
+	fn := inv.tools.apply(inv.fn)
+
+	if inv.retriever != nil {
+		var err error
+		fn, err = inv.retriever.RetrieveTools(ctx, message, fn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fallbackName string
+	if inv.fallback != nil {
+		fallbackName = inv.fallback.Name
+		for _, f := range inv.fn {
+			if f.Name == fallbackName {
+				return nil, fmt.Errorf("%w: %q", ErrFallbackFunctionName, fallbackName)
+			}
+		}
+
+		fn = append(fn[:len(fn):len(fn)], inv.fallback)
+	}
+
+	if inv.contextLength > 0 {
+		emptyPrompt, err := inv.renderToolPrompt(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		budget := inv.contextLength - inv.responseTokens - CountMessage(inv.getTokenizer(), userMessage) -
+			CountMessage(inv.getTokenizer(), Message{Role: "system", Content: emptyPrompt})
+		fn = FitFunctions(inv.getTokenizer(), fn, budget, fallbackName)
+	}
+
+	return fn, nil
+}
+
+func (inv *invocation) isFallback(tool string) bool {
+	return inv.fallback != nil && inv.fallback.Name == tool
+}
+
+func (inv *invocation) useNativeTools() bool {
+	_, ok := inv.llm.(ToolCallingLLM)
+	return inv.nativeTools && ok
+}
+
+// complete asks the model for the next tool calls and returns the
+// assistant message to keep in the conversation.
+func (inv *invocation) complete(ctx context.Context, messages []Message, fn []*Function) (Message, []FunctionResponse, error) {
+	if inv.useNativeTools() {
+		tools := make([]*Function, 0, len(fn))
+		for _, f := range fn {
+			if !inv.isFallback(f.Name) {
+				tools = append(tools, f)
+			}
+		}
+
+		var resp *ToolCallResponse
+		info := inv.childRunInfo()
+		llmOpts := inv.chatOptions()
+		err := inv.observeLLM(ctx, info, messages, llmOpts, func(ctx context.Context) (*Generation, error) {
+			var err error
+			resp, err = inv.llm.(ToolCallingLLM).ChatWithTools(ctx, messages, tools, llmOpts)
+			if err != nil {
+				return nil, err
+			}
+
+			return &Generation{Content: resp.Content, Provider: inv.llm.Name()}, nil
+		})
+		if err != nil {
+			return Message{}, nil, err
+		}
+
+		calls := resp.Calls
+		if len(calls) == 0 && inv.fallback != nil {
+			calls = []FunctionResponse{{
+				Tool:      inv.fallback.Name,
+				ToolInput: map[string]interface{}{"response": resp.Content},
+			}}
+		}
+
+		content, err := json.Marshal(calls)
+		if err != nil {
+			return Message{}, nil, err
+		}
+
+		return Message{Role: "assistant", Content: string(content)}, calls, nil
+	}
+
+	llmOpts := inv.chatOptions()
+	EnableJSONMode(inv.llm, llmOpts)
+
+	var response string
+	info := inv.childRunInfo()
+	err := inv.observeLLM(ctx, info, messages, llmOpts, func(ctx context.Context) (*Generation, error) {
+		generation, err := Generate(ctx, inv.llm, messages, llmOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		response = generation.Content
+
+		return generation, nil
+	})
+	if err != nil {
+		return Message{}, nil, err
+	}
+
+	calls, err := parseResponse(response)
+	if err != nil {
+		inv.callbacks.parseError(ctx, &ParseErrorEvent{RunInfo: info, Raw: response, Err: err})
+		return Message{}, nil, &ParseError{Raw: response, Cause: err}
+	}
+
+	return Message{Role: "assistant", Content: response}, calls, nil
+}
+
+// observeLLM runs an LLM request between the LLM callbacks, with the
+// context of the LLM run.
+func (inv *invocation) observeLLM(ctx context.Context, info RunInfo, messages []Message, options map[string]interface{}, fn func(ctx context.Context) (*Generation, error)) error {
+	inv.callbacks.llmStart(ctx, &LLMStartEvent{
+		RunInfo:  info,
+		Provider: inv.llm.Name(),
+		Model:    modelOf(inv.llm),
+		Messages: messages,
+		Options:  options,
+	})
+	ctx = inv.callbacks.runContext(ctx, info)
+
+	start := time.Now()
+	generation, err := fn(ctx)
 	if err != nil {
+		inv.callbacks.llmError(ctx, &LLMErrorEvent{
+			RunInfo:  info,
+			Provider: inv.llm.Name(),
+			Err:      err,
+			Duration: time.Since(start),
+		})
+
 		return err
 	}
 
-	return handler(fr.ToolInput)
+	inv.callbacks.llmEnd(ctx, &LLMEndEvent{
+		RunInfo:    info,
+		Generation: generation,
+		Duration:   time.Since(start),
+	})
+
+	return nil
+}
+
+// chatOptions copies the per-call options, backends may delete the keys
+// they consume.
+func (inv *invocation) chatOptions() map[string]interface{} {
+	opts := make(map[string]interface{}, len(inv.llmOptions)+1)
+	for k, v := range inv.llmOptions {
+		opts[k] = v
+	}
+
+	return opts
+}
+
+// execute runs the calls of one model turn concurrently and returns their
+// results in call order.
+func (inv *invocation) execute(ctx context.Context, fn []*Function, calls []FunctionResponse) []ToolResult {
+	selected := &ToolSelectedEvent{RunInfo: inv.runInfo(), Calls: calls}
+	if err := inv.callbacks.toolSelected(ctx, selected); err != nil {
+		results := make([]ToolResult, len(calls))
+		for i, call := range calls {
+			results[i] = ToolResult{Tool: call.Tool, Input: call.ToolInput, Err: fmt.Errorf("%w: %w", ErrToolVetoed, err)}
+		}
+
+		return results
+	}
+
+	calls = selected.Calls
+	results := make([]ToolResult, len(calls))
+
+	limit := inv.maxConcurrency
+	if limit <= 0 || limit > len(calls) {
+		limit = len(calls)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(calls); j++ {
+				results[j] = ToolResult{Tool: calls[j].Tool, Input: calls[j].ToolInput, Err: ctx.Err()}
+			}
+
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func(i int, call FunctionResponse) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = inv.call(ctx, fn, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (inv *invocation) call(ctx context.Context, fn []*Function, call FunctionResponse) ToolResult {
+	info := inv.childRunInfo()
+	start := time.Now()
+
+	event := &ToolStartEvent{RunInfo: info, Call: call}
+	vetoErr := inv.callbacks.toolStart(ctx, event)
+	call = event.Call
+
+	ctx = inv.callbacks.runContext(ctx, info)
+
+	result := ToolResult{Tool: call.Tool, Input: call.ToolInput}
+	if vetoErr != nil {
+		result.Err = fmt.Errorf("%w: %w", ErrToolVetoed, vetoErr)
+		inv.callbacks.toolError(ctx, &ToolErrorEvent{RunInfo: info, Result: result, Duration: time.Since(start)})
+		return result
+	}
+
+	handler, err := inv.getHandler(fn, call.Tool)
+	if err != nil {
+		result.Err = err
+		inv.callbacks.toolError(ctx, &ToolErrorEvent{RunInfo: info, Result: result, Duration: time.Since(start)})
+		return result
+	}
+
+	result.Output, err = handler(ContextWithRunID(ctx, info.RunID), call.ToolInput)
+	if err != nil {
+		result.Err = &ToolExecutionError{Tool: call.Tool, Input: call.ToolInput, Cause: err}
+	}
+
+	if result.Err != nil {
+		inv.callbacks.toolError(ctx, &ToolErrorEvent{RunInfo: info, Result: result, Duration: time.Since(start)})
+	} else {
+		inv.callbacks.toolEnd(ctx, &ToolEndEvent{RunInfo: info, Result: result, Duration: time.Since(start)})
+	}
+
+	if inv.isFallback(call.Tool) && result.Err == nil && inv.convHandler != nil {
+		if resp, ok := result.Output.(string); ok {
+			inv.convHandler(resp)
+		}
+	}
+
+	return result
+}
+
+// needsFollowUp reports whether a tool produced output the model has not
+// seen yet. The fallback output is final.
+func (inv *invocation) needsFollowUp(results []ToolResult) bool {
+	for _, r := range results {
+		if !inv.isFallback(r.Tool) && r.Output != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toolResultMessage(results []ToolResult) Message {
+	type toolResult struct {
+		ToolResult
+		Error string `json:"error,omitempty"`
+	}
+
+	payload := make([]toolResult, len(results))
+	for i, r := range results {
+		payload[i] = toolResult{ToolResult: r}
+		if r.Err != nil {
+			payload[i].Error = r.Err.Error()
+		}
+	}
+
+	content, _ := json.Marshal(payload)
+
+	return Message{Role: "tool", Content: string(content)}
 }
 
-func (a *Chain) getHandler(tool string) (FunctionHandler, error) {
-	for _, f := range a.fn {
+func (inv *invocation) renderToolPrompt(fn []*Function) (string, error) {
+	if fn == nil {
+		fn = []*Function{}
+	}
+
+	functions, err := json.Marshal(fn)
+	if err != nil {
+		return "", err
+	}
+
+	p := inv.toolPrompt
+	if p == nil {
+		p = defaultToolSelectionPrompt
+	}
+
+	return p.Format(map[string]interface{}{
+		"functions": string(functions),
+		"tools":     fn,
+	})
+}
+
+func (inv *invocation) selectExamples(ctx context.Context, message string, fn []*Function) ([]Message, error) {
+	var examples []Example
+	for _, f := range fn {
+		examples = append(examples, f.Examples...)
+	}
+
+	if len(examples) == 0 {
+		return nil, nil
+	}
+
+	if inv.examples != nil {
+		var err error
+		examples, err = inv.examples.SelectExamples(ctx, message, examples)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messages := make([]Message, 0, len(examples)*2)
+	for _, e := range examples {
+		messages = append(messages, exampleMessages(e)...)
+	}
+
+	return messages, nil
+}
+
+func (inv *invocation) getTokenizer() Tokenizer {
+	if inv.tokenizer == nil {
+		return defaultTokenizer()
+	}
+
+	return inv.tokenizer
+}
+
+func (inv *invocation) getHandler(fn []*Function, tool string) (ToolHandler, error) {
+	available := make([]string, 0, len(fn))
+	for _, f := range fn {
+		if f.Handler == nil {
+			continue
+		}
+
 		if f.Name == tool {
-			return f.Function, nil
+			return f.Handler, nil
 		}
+
+		available = append(available, f.Name)
 	}
 
-	return nil, ErrFunctionNotFound
+	return nil, &UnknownToolError{Name: tool, Available: available}
 }
 
-func (a *Chain) parseResponse(response string) (*FunctionResponse, error) {
+var errNoToolCalls = errors.New("empty tool call list")
+
+// parseResponse accepts a single tool call object or an array of them.
+func parseResponse(response string) ([]FunctionResponse, error) {
+	trimmed := bytes.TrimSpace([]byte(response))
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var calls []FunctionResponse
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return nil, err
+		}
+
+		if len(calls) == 0 {
+			return nil, errNoToolCalls
+		}
+
+		return calls, nil
+	}
+
 	var fr FunctionResponse
-	if err := json.Unmarshal([]byte(response), &fr); err != nil {
+	if err := json.Unmarshal(trimmed, &fr); err != nil {
 		return nil, err
 	}
 
-	return &fr, nil
+	return []FunctionResponse{fr}, nil
 }
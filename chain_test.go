@@ -0,0 +1,223 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChainConcurrentInvoke(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().
+		On(gochaintest.LastMessageRole("tool"), gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "done"})).
+		Default(gochaintest.ToolCall("echo", map[string]interface{}{"text": "hi"}))
+
+	chain := gochain.New(llm)
+	var replies atomic.Int32
+	chain.RegisterConversationalFunction(func(string) {
+		replies.Add(1)
+	})
+
+	echo := func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		return input["text"], nil
+	}
+
+	if err := chain.RegisterTool("echo", "Echo the text", nil, echo); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			if err := chain.Invoke(ctx, "say hi"); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			results, err := chain.Run(ctx, "say hi")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if len(results) != 1 || results[0].Output != "done" {
+				t.Errorf("results = %+v", results)
+			}
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("tool_%d", i)
+			if err := chain.RegisterTool(name, "Temporary tool", nil, echo); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := chain.UnregisterFunction(name); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if got := len(chain.Functions()); got != 1 {
+		t.Errorf("functions = %d, want 1", got)
+	}
+
+	if got := replies.Load(); got != 16 {
+		t.Errorf("conversational replies = %d, want 16", got)
+	}
+}
+
+// failingMemory loads an empty history and fails every save.
+type failingMemory struct{}
+
+func (failingMemory) Load(context.Context, string) ([]gochain.Message, error) {
+	return nil, nil
+}
+
+func (failingMemory) Save(context.Context, string, ...gochain.Message) error {
+	return errors.New("summarizer unavailable")
+}
+
+func (failingMemory) Clear(context.Context, string) error {
+	return nil
+}
+
+func TestChainMemorySaveFailureKeepsResults(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Respond(
+		gochaintest.ToolCall("book", map[string]interface{}{"room": "A"}),
+		gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "Room A is booked."}),
+	)
+
+	chain := gochain.New(llm)
+	chain.SetMemory(failingMemory{})
+
+	booked := 0
+	err := chain.RegisterTool("book", "Book a room", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		booked++
+		return "booked", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "book room A")
+	if !errors.Is(err, gochain.ErrMemorySave) {
+		t.Errorf("err = %v, want ErrMemorySave", err)
+	}
+
+	if booked != 1 || len(results) != 1 || results[0].Output != "Room A is booked." {
+		t.Errorf("booked %d times, results = %+v", booked, results)
+	}
+}
+
+// fourCalls asks for four calls of the tool in one turn.
+var fourCalls = gochaintest.Text(`[{"tool":"count","toolInput":{"n":1}},{"tool":"count","toolInput":{"n":2}},` +
+	`{"tool":"count","toolInput":{"n":3}},{"tool":"count","toolInput":{"n":4}}]`)
+
+func TestChainParallelToolCalls(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM().Default(fourCalls))
+
+	// Every call waits for the others, so the calls only finish when they
+	// all run at once.
+	var started sync.WaitGroup
+	started.Add(4)
+	err := chain.RegisterTool("count", "Count", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		started.Done()
+		started.Wait()
+		return input["n"], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "count to four", gochain.WithMaxSteps(1))
+	if !errors.Is(err, gochain.ErrMaxSteps) {
+		t.Errorf("err = %v, want ErrMaxSteps", err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("results = %+v", results)
+	}
+
+	for i, r := range results {
+		if r.Output != float64(i+1) {
+			t.Errorf("result %d = %v, want the calls in the order the model made them", i, r.Output)
+		}
+	}
+}
+
+func TestChainMaxConcurrency(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM().Default(fourCalls))
+
+	var running, peak atomic.Int32
+	err := chain.RegisterTool("count", "Count", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return input["n"], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limit := range []int{1, 2} {
+		peak.Store(0)
+
+		results, _ := chain.Run(context.Background(), "count to four", gochain.WithMaxConcurrency(limit), gochain.WithMaxSteps(1))
+		if len(results) != 4 {
+			t.Fatalf("results = %+v", results)
+		}
+
+		if got := peak.Load(); got > int32(limit) {
+			t.Errorf("limit %d: %d calls ran at once", limit, got)
+		}
+	}
+}
+
+func TestChainMaxSteps(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("count", map[string]interface{}{"n": 1}))
+
+	chain := gochain.New(llm)
+	chain.SetMaxSteps(2)
+	chain.RegisterConversationalFunction(func(string) {})
+	err := chain.RegisterTool("count", "Count", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		return input["n"], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "count forever")
+	if !errors.Is(err, gochain.ErrMaxSteps) {
+		t.Errorf("err = %v, want ErrMaxSteps", err)
+	}
+
+	if llm.CallCount() != 2 || len(results) != 1 || results[0].Output != float64(1) {
+		t.Errorf("%d model calls, results = %+v", llm.CallCount(), results)
+	}
+
+	if err := chain.Invoke(context.Background(), "count forever"); !errors.Is(err, gochain.ErrMaxSteps) {
+		t.Errorf("Invoke() err = %v, want ErrMaxSteps", err)
+	}
+}
@@ -0,0 +1,103 @@
+package gochain
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxImageSize is the largest image ImageFromReader accepts, in bytes.
+const MaxImageSize = 20 << 20
+
+type ContentPartType string
+
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+)
+
+type ContentPart struct {
+	Type     ContentPartType `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     []byte          `json:"data,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	MIMEType string          `json:"mimeType,omitempty"`
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+func ImagePart(data []byte, mimeType string) ContentPart {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return ContentPart{Type: ContentPartImage, Data: data, MIMEType: mimeType}
+}
+
+func ImageURLPart(url, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartImage, URL: url, MIMEType: mimeType}
+}
+
+func ImageFromReader(r io.Reader, mimeType string) (ContentPart, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize+1))
+	if err != nil {
+		return ContentPart{}, err
+	}
+
+	if len(data) > MaxImageSize {
+		return ContentPart{}, ErrImageTooLarge
+	}
+
+	if len(data) == 0 {
+		return ContentPart{}, ErrEmptyImage
+	}
+
+	part := ImagePart(data, mimeType)
+	if !strings.HasPrefix(part.MIMEType, "image/") {
+		return ContentPart{}, ErrUnsupportedImage
+	}
+
+	return part, nil
+}
+
+func ImageFromFile(path string) (ContentPart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ContentPart{}, err
+	}
+	defer f.Close()
+
+	// Prefer the extension, it is more reliable than sniffing for formats
+	// like SVG that http.DetectContentType reports as text.
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	return ImageFromReader(f, mimeType)
+}
+
+func (p ContentPart) IsImage() bool {
+	return p.Type == ContentPartImage
+}
+
+// Base64 returns the image bytes encoded without the data URL prefix.
+func (p ContentPart) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Data)
+}
+
+// DataURL returns the image as a data URL, or URL when the part only
+// references a remote image.
+func (p ContentPart) DataURL() string {
+	if len(p.Data) == 0 {
+		return p.URL
+	}
+
+	return "data:" + p.MIMEType + ";base64," + p.Base64()
+}
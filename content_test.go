@@ -0,0 +1,77 @@
+package gochain_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageFromReader(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		mimeType string
+		want     string
+		err      error
+	}{
+		{name: "sniffed", data: png, want: "image/png"},
+		{name: "given type", data: png, mimeType: "image/webp", want: "image/webp"},
+		{name: "empty", err: gochain.ErrEmptyImage},
+		{name: "not an image", data: []byte("hello"), err: gochain.ErrUnsupportedImage},
+		{name: "at the limit", data: append(png, make([]byte, gochain.MaxImageSize-len(png))...), want: "image/png"},
+		{name: "too large", data: append(png, make([]byte, gochain.MaxImageSize)...), err: gochain.ErrImageTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			part, err := gochain.ImageFromReader(bytes.NewReader(tt.data), tt.mimeType)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && (part.MIMEType != tt.want || !part.IsImage() || len(part.Data) != len(tt.data)) {
+				t.Errorf("part = %s with %d bytes", part.MIMEType, len(part.Data))
+			}
+		})
+	}
+}
+
+func TestImageFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logo.svg")
+	if err := os.WriteFile(path, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	part, err := gochain.ImageFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if part.MIMEType != "image/svg+xml" {
+		t.Errorf("MIME type = %q, want image/svg+xml from the extension", part.MIMEType)
+	}
+
+	if !strings.HasPrefix(part.DataURL(), "data:image/svg+xml;base64,") {
+		t.Errorf("DataURL() = %q", part.DataURL())
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	m := gochain.Message{Role: "user", Parts: []gochain.ContentPart{
+		gochain.TextPart("What is"),
+		gochain.ImagePart(png, ""),
+		gochain.TextPart("in this image?"),
+	}}
+
+	if got := len(m.Images()); got != 1 {
+		t.Errorf("Images() = %d parts, want 1", got)
+	}
+
+	if got := m.Text(); !strings.Contains(got, "What is") || !strings.Contains(got, "in this image?") {
+		t.Errorf("Text() = %q", got)
+	}
+}
@@ -0,0 +1,9 @@
+package gochain
+
+// Document is a piece of text with metadata about where it came from. It
+// is produced by document loaders and consumed by text splitters and
+// vector stores.
+type Document struct {
+	PageContent string                 `json:"pageContent"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
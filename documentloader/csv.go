@@ -0,0 +1,117 @@
+package documentloader
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"io"
+	"strings"
+)
+
+type CSVOption func(*CSV)
+
+// WithContentColumns limits the page content to the given columns. By
+// default every column is included.
+func WithContentColumns(columns ...string) CSVOption {
+	return func(l *CSV) {
+		l.content = columns
+	}
+}
+
+// WithMetadataColumns copies the given columns into the metadata.
+func WithMetadataColumns(columns ...string) CSVOption {
+	return func(l *CSV) {
+		l.metadata = columns
+	}
+}
+
+func WithSeparator(comma rune) CSVOption {
+	return func(l *CSV) {
+		l.comma = comma
+	}
+}
+
+// CSV loads one document per row, written as "column: value" lines. The
+// first row holds the column names.
+type CSV struct {
+	source   Source
+	content  []string
+	metadata []string
+	comma    rune
+}
+
+func NewCSV(source Source, opts ...CSVOption) *CSV {
+	l := &CSV{source: source, comma: ','}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *CSV) Load(ctx context.Context) ([]gochain.Document, error) {
+	f, err := l.source.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = l.comma
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+
+	content := l.content
+	if len(content) == 0 {
+		content = header
+	}
+
+	for _, name := range append(append([]string{}, content...), l.metadata...) {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("documentloader: %s has no column %q", l.source.Name, name)
+		}
+	}
+
+	var docs []gochain.Document
+	for row := 1; ; row++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lines := make([]string, len(content))
+		for i, name := range content {
+			lines[i] = name + ": " + record[index[name]]
+		}
+
+		metadata := l.source.Metadata()
+		metadata[MetadataRow] = row
+		for _, name := range l.metadata {
+			metadata[name] = record[index[name]]
+		}
+
+		docs = append(docs, gochain.Document{PageContent: strings.Join(lines, "\n"), Metadata: metadata})
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,168 @@
+package documentloader
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type DirectoryOption func(*Directory)
+
+// WithPatterns only loads files whose path relative to the directory
+// matches one of the globs. "**" matches any number of directories.
+func WithPatterns(patterns ...string) DirectoryOption {
+	return func(l *Directory) {
+		l.patterns = patterns
+	}
+}
+
+// WithExcludes skips files and directories matching one of the globs.
+func WithExcludes(patterns ...string) DirectoryOption {
+	return func(l *Directory) {
+		l.excludes = patterns
+	}
+}
+
+// WithLoader sets the loader used for files with the given extension,
+// such as ".txt".
+func WithLoader(ext string, newLoader func(Source) Loader) DirectoryOption {
+	return func(l *Directory) {
+		l.loaders[strings.ToLower(ext)] = newLoader
+	}
+}
+
+// WithSkipErrors skips files that fail to load instead of failing the
+// whole directory.
+func WithSkipErrors() DirectoryOption {
+	return func(l *Directory) {
+		l.skipErrors = true
+	}
+}
+
+// Directory walks a directory tree and loads each file with the loader
+// registered for its extension. Files without one are skipped. PDF files
+// need the loader of the documentloader/pdf module, see WithLoader.
+type Directory struct {
+	root       string
+	patterns   []string
+	excludes   []string
+	loaders    map[string]func(Source) Loader
+	skipErrors bool
+}
+
+func NewDirectory(root string, opts ...DirectoryOption) *Directory {
+	text := func(s Source) Loader { return NewText(s) }
+	markdown := func(s Source) Loader { return NewMarkdown(s) }
+	page := func(s Source) Loader { return NewHTML(s) }
+
+	l := &Directory{
+		root: root,
+		loaders: map[string]func(Source) Loader{
+			".txt":      text,
+			".md":       markdown,
+			".markdown": markdown,
+			".html":     page,
+			".htm":      page,
+			".csv":      func(s Source) Loader { return NewCSV(s) },
+			".json":     func(s Source) Loader { return NewJSON(s, "$") },
+		},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *Directory) Load(ctx context.Context) ([]gochain.Document, error) {
+	var docs []gochain.Document
+	err := filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && matchAny(l.excludes, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() || (len(l.patterns) > 0 && !matchAny(l.patterns, rel)) {
+			return nil
+		}
+
+		newLoader, ok := l.loaders[strings.ToLower(filepath.Ext(p))]
+		if !ok {
+			return nil
+		}
+
+		loaded, err := newLoader(File(p)).Load(ctx)
+		if err != nil {
+			if l.skipErrors {
+				return nil
+			}
+			return err
+		}
+
+		docs = append(docs, loaded...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob matches path segments, with "**" standing for zero or more
+// segments.
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,111 @@
+package documentloader
+
+import (
+	"bytes"
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// ExtractText writes the text of n and its children to buf, leaving out
+// scripts and styles.
+func ExtractText(n *html.Node, buf *bytes.Buffer) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "style", "script", "noscript", "template":
+			return
+		}
+	}
+
+	if n.Type == html.TextNode {
+		buf.WriteString(n.Data)
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ExtractText(c, buf)
+	}
+}
+
+// CleanHTML returns the text content of an HTML document.
+func CleanHTML(htmlString string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlString))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	ExtractText(doc, &buf)
+
+	return buf.String(), nil
+}
+
+// HTML loads the text of an HTML page as a single document, with blank
+// lines and indentation removed. The page title is kept in the metadata.
+type HTML struct {
+	source Source
+}
+
+func NewHTML(source Source) *HTML {
+	return &HTML{source: source}
+}
+
+func (l *HTML) Load(_ context.Context) ([]gochain.Document, error) {
+	data, err := l.source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := l.source.Metadata()
+	if title := findTitle(doc); title != "" {
+		metadata[MetadataTitle] = title
+	}
+
+	var body *html.Node
+	if body = findElement(doc, "body"); body == nil {
+		body = doc
+	}
+
+	var buf bytes.Buffer
+	ExtractText(body, &buf)
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return []gochain.Document{{PageContent: strings.Join(lines, "\n"), Metadata: metadata}}, nil
+}
+
+func findElement(n *html.Node, name string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == name {
+		return n
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, name); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func findTitle(doc *html.Node) string {
+	title := findElement(doc, "title")
+	if title == nil {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	ExtractText(title, &buf)
+
+	return strings.TrimSpace(buf.String())
+}
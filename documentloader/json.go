@@ -0,0 +1,164 @@
+package documentloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON loads one document per value selected by a path such as
+// "$.items[*].body". Paths support fields (.name or ['name']), indexes
+// ([0]) and wildcards (.* or [*]). Strings are used as they are; other
+// values are written as JSON.
+type JSON struct {
+	source Source
+	path   string
+}
+
+func NewJSON(source Source, path string) *JSON {
+	return &JSON{source: source, path: path}
+}
+
+func (l *JSON) Load(_ context.Context) ([]gochain.Document, error) {
+	steps, err := parseJSONPath(l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := l.source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var docs []gochain.Document
+	for _, m := range selectJSON(root, "$", steps) {
+		content, ok := m.value.(string)
+		if !ok {
+			b, err := json.Marshal(m.value)
+			if err != nil {
+				return nil, err
+			}
+			content = string(b)
+		}
+
+		metadata := l.source.Metadata()
+		metadata[MetadataPath] = m.path
+
+		docs = append(docs, gochain.Document{PageContent: content, Metadata: metadata})
+	}
+
+	return docs, nil
+}
+
+const jsonWildcard = "*"
+
+// jsonStep is a field name, an array index or the wildcard.
+type jsonStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+func parseJSONPath(path string) ([]jsonStep, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	var steps []jsonStep
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("documentloader: invalid JSON path %q", path)
+			}
+			steps = append(steps, jsonStep{key: rest[:end], isKey: true})
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("documentloader: invalid JSON path %q", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			switch {
+			case inner == jsonWildcard:
+				steps = append(steps, jsonStep{key: jsonWildcard, isKey: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonStep{key: inner[1 : len(inner)-1], isKey: true})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("documentloader: invalid JSON path %q", path)
+				}
+				steps = append(steps, jsonStep{index: i})
+			}
+		default:
+			return nil, fmt.Errorf("documentloader: invalid JSON path %q", path)
+		}
+	}
+
+	return steps, nil
+}
+
+type jsonMatch struct {
+	path  string
+	value interface{}
+}
+
+func selectJSON(v interface{}, path string, steps []jsonStep) []jsonMatch {
+	if len(steps) == 0 {
+		return []jsonMatch{{path: path, value: v}}
+	}
+
+	step, rest := steps[0], steps[1:]
+
+	var matches []jsonMatch
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if !step.isKey {
+			return nil
+		}
+
+		if step.key != jsonWildcard {
+			if child, ok := v[step.key]; ok {
+				matches = selectJSON(child, path+"."+step.key, rest)
+			}
+			return matches
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			matches = append(matches, selectJSON(v[k], path+"."+k, rest)...)
+		}
+	case []interface{}:
+		if step.isKey && step.key != jsonWildcard {
+			return nil
+		}
+
+		for i, child := range v {
+			if step.isKey || i == step.index || (step.index < 0 && i == len(v)+step.index) {
+				matches = append(matches, selectJSON(child, path+"["+strconv.Itoa(i)+"]", rest)...)
+			}
+		}
+	}
+
+	return matches
+}
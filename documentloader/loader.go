@@ -0,0 +1,95 @@
+// Package documentloader reads files and other sources into
+// gochain.Document values.
+package documentloader
+
+import (
+	"bytes"
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"io"
+	"os"
+)
+
+// Metadata keys set by the loaders.
+const (
+	MetadataSource = "source"
+	MetadataPage   = "page"
+	MetadataRow    = "row"
+	MetadataPath   = "path"
+	MetadataTitle  = "title"
+)
+
+type Loader interface {
+	Load(ctx context.Context) ([]gochain.Document, error)
+}
+
+// Source is something a loader reads from. Name is recorded as the source
+// of the loaded documents.
+type Source struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+func File(path string) Source {
+	return Source{
+		Name: path,
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// Reader reads from r, which can only be loaded once.
+func Reader(r io.Reader, name string) Source {
+	return Source{
+		Name: name,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	}
+}
+
+// Bytes reads from data.
+func Bytes(data []byte, name string) Source {
+	return Source{
+		Name: name,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+// Read returns the whole content of the source.
+func (s Source) Read() ([]byte, error) {
+	r, err := s.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// Metadata returns the metadata shared by the documents loaded from the
+// source.
+func (s Source) Metadata() map[string]interface{} {
+	return map[string]interface{}{MetadataSource: s.Name}
+}
+
+// Text loads a source as a single document.
+type Text struct {
+	source Source
+}
+
+func NewText(source Source) *Text {
+	return &Text{source: source}
+}
+
+func (l *Text) Load(_ context.Context) ([]gochain.Document, error) {
+	data, err := l.source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return []gochain.Document{{PageContent: string(data), Metadata: l.source.Metadata()}}, nil
+}
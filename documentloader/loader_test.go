@@ -0,0 +1,233 @@
+package documentloader_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/documentloader"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, l documentloader.Loader) []gochain.Document {
+	t.Helper()
+
+	docs, err := l.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return docs
+}
+
+func TestText(t *testing.T) {
+	docs := load(t, documentloader.NewText(documentloader.Bytes([]byte("hello"), "a.txt")))
+
+	want := []gochain.Document{{PageContent: "hello", Metadata: map[string]interface{}{"source": "a.txt"}}}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs = %+v, want %+v", docs, want)
+	}
+}
+
+func TestMarkdownFrontMatter(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		text     string
+		content  string
+		metadata map[string]interface{}
+	}{
+		{"none", "# Title\n", "# Title\n", map[string]interface{}{}},
+		{
+			"scalars",
+			"---\ntitle: \"Hello: world\"\ndraft: false\nweight: 3\nratio: 0.5\nauthor: 'O''Brien'\nempty:\n# a comment\n---\n\n# Body\n",
+			"# Body\n",
+			map[string]interface{}{"title": "Hello: world", "draft": false, "weight": 3, "ratio": 0.5, "author": "O'Brien", "empty": nil},
+		},
+		{"dots close the block", "---\r\ntags: go\r\n...\r\nbody", "body", map[string]interface{}{"tags": "go"}},
+		{"byte order mark", "\xef\xbb\xbf---\nk: v\n---\nbody", "body", map[string]interface{}{"k": "v"}},
+		{"unclosed block", "---\nk: v\nbody", "---\nk: v\nbody", map[string]interface{}{}},
+		// The source is recorded as the loader found it.
+		{"source is kept", "---\nsource: other\n---\nbody", "body", map[string]interface{}{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := load(t, documentloader.NewMarkdown(documentloader.Bytes([]byte(tt.text), "a.md")))
+
+			want := map[string]interface{}{"source": "a.md"}
+			for k, v := range tt.metadata {
+				want[k] = v
+			}
+
+			if len(docs) != 1 || docs[0].PageContent != tt.content || !reflect.DeepEqual(docs[0].Metadata, want) {
+				t.Errorf("docs = %+v, want content %q and metadata %v", docs, tt.content, want)
+			}
+		})
+	}
+}
+
+func TestMarkdownNestedFrontMatter(t *testing.T) {
+	for _, front := range []string{"tags:\n  - go", "tags: [go, ai]", "author:\n  name: Ann", "just text"} {
+		source := documentloader.Bytes([]byte("---\n"+front+"\n---\nbody"), "a.md")
+		if _, err := documentloader.NewMarkdown(source).Load(context.Background()); err == nil {
+			t.Errorf("%q: expected an error", front)
+		}
+	}
+
+	// A parser of their own reads what the default one cannot.
+	parse := func(data []byte) (map[string]interface{}, error) {
+		return map[string]interface{}{"raw": string(data)}, nil
+	}
+
+	source := documentloader.Bytes([]byte("---\ntags: [go, ai]\n---\nbody"), "a.md")
+	docs := load(t, documentloader.NewMarkdown(source, documentloader.WithFrontMatterParser(parse)))
+	if docs[0].Metadata["raw"] != "tags: [go, ai]\n" {
+		t.Errorf("metadata = %v", docs[0].Metadata)
+	}
+
+	parse = func([]byte) (map[string]interface{}, error) {
+		return nil, errors.New("bad")
+	}
+	if _, err := documentloader.NewMarkdown(source, documentloader.WithFrontMatterParser(parse)).Load(context.Background()); err == nil || !strings.Contains(err.Error(), "a.md") {
+		t.Errorf("err = %v, want the parser error with the source", err)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	page := `<html><head><title> Weather </title><style>p {}</style></head>
+<body>
+  <h1>Paris</h1>
+  <p>Sunny,   <b>25°C</b></p>
+  <script>alert(1)</script>
+</body></html>`
+
+	docs := load(t, documentloader.NewHTML(documentloader.Bytes([]byte(page), "a.html")))
+
+	if len(docs) != 1 || docs[0].PageContent != "Paris\nSunny, 25°C" || docs[0].Metadata["title"] != "Weather" {
+		t.Errorf("docs = %+v", docs)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	data := []byte("id;name;bio\n1;Ann;Likes Go\n2;Bob;\"Likes C; and Go\"\n")
+
+	docs := load(t, documentloader.NewCSV(documentloader.Bytes(data, "people.csv"),
+		documentloader.WithSeparator(';'),
+		documentloader.WithContentColumns("name", "bio"),
+		documentloader.WithMetadataColumns("id")))
+
+	want := []gochain.Document{
+		{PageContent: "name: Ann\nbio: Likes Go", Metadata: map[string]interface{}{"source": "people.csv", "row": 1, "id": "1"}},
+		{PageContent: "name: Bob\nbio: Likes C; and Go", Metadata: map[string]interface{}{"source": "people.csv", "row": 2, "id": "2"}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs = %+v, want %+v", docs, want)
+	}
+
+	if _, err := documentloader.NewCSV(documentloader.Bytes(data, "people.csv"), documentloader.WithContentColumns("age")).Load(context.Background()); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+
+	if docs := load(t, documentloader.NewCSV(documentloader.Bytes(nil, "empty.csv"))); docs != nil {
+		t.Errorf("docs = %+v for an empty file", docs)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data := []byte(`{"items": [{"body": "first", "n": 1}, {"body": {"x": 1}}, {"body": "last"}], "meta": {"b": 2, "a": 1}}`)
+
+	for _, tt := range []struct {
+		path    string
+		content []string
+		paths   []string
+	}{
+		{"$.items[*].body", []string{"first", `{"x":1}`, "last"}, []string{"$.items[0].body", "$.items[1].body", "$.items[2].body"}},
+		{"$.items[-1].body", []string{"last"}, []string{"$.items[2].body"}},
+		{"$['meta'].*", []string{"1", "2"}, []string{"$.meta.a", "$.meta.b"}},
+		{"$.missing", nil, nil},
+	} {
+		docs := load(t, documentloader.NewJSON(documentloader.Bytes(data, "a.json"), tt.path))
+
+		var content, paths []string
+		for _, d := range docs {
+			content = append(content, d.PageContent)
+			paths = append(paths, d.Metadata["path"].(string))
+		}
+
+		if !reflect.DeepEqual(content, tt.content) || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("%s: content %q at %q, want %q at %q", tt.path, content, paths, tt.content, tt.paths)
+		}
+	}
+
+	for _, path := range []string{"$.", "$[0", "$[x]", "items"} {
+		if _, err := documentloader.NewJSON(documentloader.Bytes(data, "a.json"), path).Load(context.Background()); err == nil {
+			t.Errorf("%q: expected an error", path)
+		}
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":             "a",
+		"b.md":              "# b",
+		"notes/c.TXT":       "c",
+		"notes/deep/d.txt":  "d",
+		"vendor/e.txt":      "e",
+		"f.unknown":         "f",
+		"notes/deep/g.json": `"g"`,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents := func(docs []gochain.Document) []string {
+		var c []string
+		for _, d := range docs {
+			c = append(c, d.PageContent)
+		}
+		sort.Strings(c)
+
+		return c
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []documentloader.DirectoryOption
+		want []string
+	}{
+		{"all known extensions", nil, []string{"# b", "a", "c", "d", "e", "g"}},
+		{"patterns", []documentloader.DirectoryOption{documentloader.WithPatterns("**/*.txt")}, []string{"a", "d", "e"}},
+		{"nested pattern", []documentloader.DirectoryOption{documentloader.WithPatterns("notes/**")}, []string{"c", "d", "g"}},
+		{"excludes", []documentloader.DirectoryOption{documentloader.WithExcludes("vendor", "**/deep")}, []string{"# b", "a", "c"}},
+		{"custom loader", []documentloader.DirectoryOption{
+			documentloader.WithPatterns("*.unknown"),
+			documentloader.WithLoader(".UNKNOWN", func(s documentloader.Source) documentloader.Loader { return documentloader.NewText(s) }),
+		}, []string{"f"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contents(load(t, documentloader.NewDirectory(root, tt.opts...))); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loaded %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := documentloader.NewDirectory(root).Load(context.Background()); err == nil {
+		t.Error("expected an error for a broken file")
+	}
+
+	if got := contents(load(t, documentloader.NewDirectory(root, documentloader.WithSkipErrors()))); len(got) != 6 {
+		t.Errorf("loaded %q, want the broken file skipped", got)
+	}
+}
@@ -0,0 +1,152 @@
+package documentloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"strconv"
+	"strings"
+)
+
+type MarkdownOption func(*Markdown)
+
+// WithFrontMatterParser parses front-matter blocks with parse, such as
+// the YAML parser of the documentloader/yaml module. The default parser
+// only reads flat "key: value" lines.
+func WithFrontMatterParser(parse func([]byte) (map[string]interface{}, error)) MarkdownOption {
+	return func(l *Markdown) {
+		l.parse = parse
+	}
+}
+
+// Markdown loads a Markdown source as a single document. Fields of a
+// front-matter block are moved into the metadata.
+type Markdown struct {
+	source Source
+	parse  func([]byte) (map[string]interface{}, error)
+}
+
+func NewMarkdown(source Source, opts ...MarkdownOption) *Markdown {
+	l := &Markdown{source: source, parse: parseFrontMatter}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *Markdown) Load(_ context.Context) ([]gochain.Document, error) {
+	data, err := l.source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := l.source.Metadata()
+
+	front, body, ok := splitFrontMatter(data)
+	if ok {
+		fields, err := l.parse(front)
+		if err != nil {
+			return nil, fmt.Errorf("documentloader: front matter of %s: %w", l.source.Name, err)
+		}
+
+		for k, v := range fields {
+			if _, exists := metadata[k]; !exists {
+				metadata[k] = v
+			}
+		}
+
+		data = body
+	}
+
+	return []gochain.Document{{PageContent: string(data), Metadata: metadata}}, nil
+}
+
+// splitFrontMatter separates a leading block fenced by "---" lines.
+func splitFrontMatter(data []byte) ([]byte, []byte, bool) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	first, rest, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || string(bytes.TrimRight(first, "\r ")) != "---" {
+		return nil, data, false
+	}
+
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		end := offset + len(line) + 1
+
+		if trimmed := string(bytes.TrimRight(line, "\r ")); trimmed == "---" || trimmed == "..." {
+			if end > len(rest) {
+				end = len(rest)
+			}
+			return rest[:offset], bytes.TrimLeft(rest[end:], "\r\n"), true
+		}
+
+		offset = end
+	}
+
+	return nil, data, false
+}
+
+var errFrontMatter = errors.New("not a flat key: value line, use WithFrontMatterParser for nested front matter")
+
+// parseFrontMatter reads "key: value" lines. Quoted values are strings,
+// others become booleans, integers or floats when they parse as one.
+// Nested values and lists need a YAML parser.
+func parseFrontMatter(data []byte) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || key == "" || key != strings.TrimSpace(key) {
+			return nil, fmt.Errorf("line %d: %w", n+1, errFrontMatter)
+		}
+
+		value = strings.TrimSpace(value)
+		if value != "" && strings.ContainsAny(value[:1], "[{|>&*!") {
+			return nil, fmt.Errorf("line %d: %w", n+1, errFrontMatter)
+		}
+
+		fields[key] = scalar(value)
+	}
+
+	return fields, nil
+}
+
+func scalar(value string) interface{} {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if s, err := strconv.Unquote(value); err == nil {
+				return s
+			}
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+
+	switch value {
+	case "", "~", "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+
+	return value
+}
@@ -0,0 +1,12 @@
+module github.com/ryanbekhen/gochain/documentloader/pdf
+
+go 1.22.4
+
+require (
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
+	github.com/ryanbekhen/gochain v0.0.0
+)
+
+require golang.org/x/net v0.28.0 // indirect
+
+replace github.com/ryanbekhen/gochain => ../..
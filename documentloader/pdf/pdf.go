@@ -0,0 +1,80 @@
+// Package pdf loads the text layer of PDF files. It is a module of its own
+// so the PDF parser is only pulled in by programs that use it; register it
+// with a directory loader as
+//
+//	documentloader.WithLoader(".pdf", func(s documentloader.Source) documentloader.Loader { return pdf.New(s) })
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/documentloader"
+	"strings"
+)
+
+// Loader loads a PDF, one document per page. Scanned pages without text
+// are skipped.
+type Loader struct {
+	source documentloader.Source
+}
+
+func New(source documentloader.Source) *Loader {
+	return &Loader{source: source}
+}
+
+func (l *Loader) Load(ctx context.Context) (docs []gochain.Document, err error) {
+	data, err := l.source.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// The parser panics on some malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			docs, err = nil, fmt.Errorf("pdf: reading %s: %v", l.source.Name, r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= r.NumPage(); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// Font names are only unique within a page: /F1 of one page can be
+		// another font than /F1 of the next.
+		fonts := make(map[string]*pdf.Font)
+		for _, name := range page.Fonts() {
+			f := page.Font(name)
+			fonts[name] = &f
+		}
+
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		metadata := l.source.Metadata()
+		metadata[documentloader.MetadataPage] = i
+
+		docs = append(docs, gochain.Document{PageContent: text, Metadata: metadata})
+	}
+
+	return docs, nil
+}
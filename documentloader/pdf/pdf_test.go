@@ -0,0 +1,97 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ryanbekhen/gochain/documentloader"
+	"github.com/ryanbekhen/gochain/documentloader/pdf"
+	"strings"
+	"testing"
+)
+
+// build writes a PDF whose objects are numbered from 1 in order.
+func build(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+func stream(content string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+}
+
+func page(font, contents int) string {
+	return fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", font, contents)
+}
+
+func TestLoader(t *testing.T) {
+	show := "BT /F1 12 Tf 72 700 Td (abc) Tj ET"
+
+	// Both text pages name their font /F1, but the second one maps a, b and
+	// c to x, y and z. The middle page has no text.
+	data := build(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 5 0 R 7 0 R] /Count 3 >>",
+		page(9, 4),
+		stream(show),
+		page(9, 6),
+		stream(""),
+		page(10, 8),
+		stream(show),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Type /Encoding /Differences [97 /x /y /z] >> >>",
+	)
+
+	docs, err := pdf.New(documentloader.Bytes(data, "a.pdf")).Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("%d documents, want 2: %+v", len(docs), docs)
+	}
+
+	for i, want := range []struct {
+		content string
+		page    int
+	}{
+		{"abc", 1},
+		{"xyz", 3},
+	} {
+		if got := strings.TrimSpace(docs[i].PageContent); got != want.content || docs[i].Metadata[documentloader.MetadataPage] != want.page || docs[i].Metadata[documentloader.MetadataSource] != "a.pdf" {
+			t.Errorf("document %d = %q %v, want %q on page %d", i, got, docs[i].Metadata, want.content, want.page)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pdf.New(documentloader.Bytes(data, "a.pdf")).Load(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("not a pdf"),
+		build("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [3 0 R] /Count 1 >>", "<< /Type /Page /Contents 9 0 R >>"),
+	} {
+		if _, err := pdf.New(documentloader.Bytes(data, "bad.pdf")).Load(context.Background()); err == nil {
+			t.Errorf("no error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/ryanbekhen/gochain/documentloader/yaml
+
+go 1.22.4
+
+require (
+	github.com/ryanbekhen/gochain v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/net v0.28.0 // indirect
+
+replace github.com/ryanbekhen/gochain => ../..
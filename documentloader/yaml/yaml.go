@@ -0,0 +1,21 @@
+// Package yaml parses YAML front matter for the Markdown loader. It is a
+// module of its own so the YAML parser is only pulled in by programs that
+// use it:
+//
+//	documentloader.NewMarkdown(source, documentloader.WithFrontMatterParser(yaml.Parse))
+package yaml
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Parse decodes a YAML mapping. Nested mappings and lists are kept as
+// map[string]interface{} and []interface{}.
+func Parse(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
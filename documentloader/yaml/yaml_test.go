@@ -0,0 +1,34 @@
+package yaml_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain/documentloader"
+	"github.com/ryanbekhen/gochain/documentloader/yaml"
+	"reflect"
+	"testing"
+)
+
+func TestMarkdownFrontMatter(t *testing.T) {
+	text := "---\ntitle: Hello\ntags: [go, ai]\nauthor:\n  name: Ann\n---\nbody"
+
+	docs, err := documentloader.NewMarkdown(documentloader.Bytes([]byte(text), "a.md"), documentloader.WithFrontMatterParser(yaml.Parse)).Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"source": "a.md",
+		"title":  "Hello",
+		"tags":   []interface{}{"go", "ai"},
+		"author": map[string]interface{}{"name": "Ann"},
+	}
+	if len(docs) != 1 || docs[0].PageContent != "body" || !reflect.DeepEqual(docs[0].Metadata, want) {
+		t.Errorf("docs = %+v, want metadata %v", docs, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if _, err := yaml.Parse([]byte("a: [")); err == nil {
+		t.Error("expected an error")
+	}
+}
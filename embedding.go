@@ -0,0 +1,146 @@
+package gochain
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// maxEmbeddingCacheSize bounds the embeddings a ranker keeps. The cache is
+// emptied when it would grow past it.
+const maxEmbeddingCacheSize = 4096
+
+// embeddingRanker ranks texts by their similarity to a query. The
+// embeddings of the texts are computed once and cached by text.
+type embeddingRanker struct {
+	mu    sync.Mutex
+	cache map[string][]float64
+}
+
+// rank returns the indexes of the texts at least threshold similar to
+// query, most similar first and at most k of them when k is positive.
+func (r *embeddingRanker) rank(ctx context.Context, embedder Embedder, query string, texts []string, k int, threshold float64) ([]int, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := r.embed(ctx, embedder, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) != 1 {
+		return nil, ErrEmbeddingCount
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+
+	candidates := make([]scored, 0, len(texts))
+	for i, embedding := range embeddings {
+		score := CosineSimilarity(vectors[0], embedding)
+		if score >= threshold {
+			candidates = append(candidates, scored{index: i, score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if k > 0 && len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	ranked := make([]int, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.index
+	}
+
+	return ranked, nil
+}
+
+// embed returns the embeddings of texts, embedding only the ones missing
+// from the cache.
+func (r *embeddingRanker) embed(ctx context.Context, embedder Embedder, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+
+	r.mu.Lock()
+	var missing []string
+	seen := map[string]bool{}
+	for i, text := range texts {
+		if e, ok := r.cache[text]; ok {
+			embeddings[i] = e
+		} else if !seen[text] {
+			seen[text] = true
+			missing = append(missing, text)
+		}
+	}
+	r.mu.Unlock()
+
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	vectors, err := embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) != len(missing) {
+		return nil, ErrEmbeddingCount
+	}
+
+	embedded := make(map[string][]float64, len(missing))
+	for i, text := range missing {
+		embedded[text] = vectors[i]
+	}
+
+	for i, text := range texts {
+		if embeddings[i] == nil {
+			embeddings[i] = embedded[text]
+		}
+	}
+
+	r.mu.Lock()
+	if r.cache == nil || len(r.cache)+len(embedded) > maxEmbeddingCacheSize {
+		r.cache = make(map[string][]float64, len(embedded))
+	}
+	for text, vector := range embedded {
+		r.cache[text] = vector
+	}
+	r.mu.Unlock()
+
+	return embeddings, nil
+}
@@ -1,9 +1,120 @@
 package gochain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var (
-	ErrFunctionNotFound            = errors.New("function not found")
-	ErrInvalidResponse             = errors.New("invalid response")
+	ErrFunctionNotFound     = errors.New("function not found")
+	ErrDuplicateFunction    = errors.New("function already registered")
+	ErrFallbackFunctionName = errors.New("function name is used by the fallback function")
+	ErrInvalidFunctionName  = errors.New("invalid function name")
+	ErrToolVetoed           = errors.New("tool call vetoed")
+	ErrInvalidResponse      = errors.New("invalid response")
+	// Deprecated: no longer returned, the fallback output is a result
+	// returned by Run.
 	ErrConversationalHandlerNotSet = errors.New("conversational handler not set")
+	ErrEmptyImage                  = errors.New("empty image")
+	ErrUnsupportedImage            = errors.New("unsupported image type")
+	ErrImageTooLarge               = errors.New("image too large")
+	ErrProvider                    = errors.New("provider request failed")
+	ErrEmbeddingCount              = errors.New("embedder returned the wrong number of vectors")
+	ErrMemorySave                  = errors.New("save conversation memory")
+	ErrMaxSteps                    = errors.New("maximum number of steps reached")
 )
+
+// ParseError is returned when the model output is not a valid tool call.
+// Raw holds the output as the model produced it.
+type ParseError struct {
+	Raw   string
+	Cause error
+}
+
+func (e *ParseError) Error() string {
+	if e.Cause == nil {
+		return ErrInvalidResponse.Error()
+	}
+
+	return ErrInvalidResponse.Error() + ": " + e.Cause.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrInvalidResponse
+}
+
+// UnknownToolError is returned when the model calls a tool that was not
+// offered. Available lists the tools it could have called.
+type UnknownToolError struct {
+	Name      string
+	Available []string
+}
+
+func (e *UnknownToolError) Error() string {
+	return fmt.Sprintf("%s: %q (available: %s)", ErrFunctionNotFound, e.Name, strings.Join(e.Available, ", "))
+}
+
+func (e *UnknownToolError) Is(target error) bool {
+	return target == ErrFunctionNotFound
+}
+
+// ToolExecutionError wraps an error returned by a tool handler.
+type ToolExecutionError struct {
+	Tool  string
+	Input map[string]interface{}
+	Cause error
+}
+
+func (e *ToolExecutionError) Error() string {
+	return fmt.Sprintf("tool %q: %v", e.Tool, e.Cause)
+}
+
+func (e *ToolExecutionError) Unwrap() error {
+	return e.Cause
+}
+
+// ProviderError is returned by LLM backends when the provider rejects a
+// request. Body holds the response body and Cause the backend specific
+// error, if any. RetryAfter is set when the provider asked the client to
+// wait before retrying.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+	Cause      error
+}
+
+func (e *ProviderError) Error() string {
+	msg := ErrProvider.Error()
+	if e.Provider != "" {
+		msg = e.Provider + ": " + msg
+	}
+
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" with status %d", e.StatusCode)
+	}
+
+	switch {
+	case e.Cause != nil:
+		msg += ": " + e.Cause.Error()
+	case e.Body != "":
+		msg += ": " + e.Body
+	}
+
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *ProviderError) Is(target error) bool {
+	return target == ErrProvider
+}
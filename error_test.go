@@ -0,0 +1,95 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderError(t *testing.T) {
+	cause := errors.New("model not found")
+
+	for _, tt := range []struct {
+		err  *gochain.ProviderError
+		want string
+	}{
+		{&gochain.ProviderError{}, "provider request failed"},
+		{&gochain.ProviderError{Provider: "ollama", StatusCode: 404, Body: `{"error":"model not found"}`, Cause: cause},
+			"ollama: provider request failed with status 404: model not found"},
+		{&gochain.ProviderError{Provider: "cf-worker-ai", StatusCode: 429, Body: "slow down", RetryAfter: time.Second},
+			"cf-worker-ai: provider request failed with status 429: slow down"},
+	} {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+
+		if !errors.Is(tt.err, gochain.ErrProvider) {
+			t.Errorf("%v does not match ErrProvider", tt.err)
+		}
+	}
+
+	var err error = &gochain.ProviderError{StatusCode: 404, Cause: cause}
+	if !errors.Is(err, cause) {
+		t.Error("ProviderError does not unwrap to its cause")
+	}
+
+	var pe *gochain.ProviderError
+	if !errors.As(errors.Join(errors.New("other"), err), &pe) || pe.StatusCode != 404 {
+		t.Errorf("errors.As() = %+v", pe)
+	}
+}
+
+func TestToolErrors(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().
+		Default(gochaintest.Text(`[{"tool":"missing","toolInput":{}},{"tool":"fail","toolInput":{"id":1}}]`))
+
+	chain := gochain.New(llm)
+	boom := errors.New("boom")
+	if err := chain.RegisterTool("fail", "", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		return nil, boom
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var unknown *gochain.UnknownToolError
+	if !errors.As(results[0].Err, &unknown) || unknown.Name != "missing" || !errors.Is(results[0].Err, gochain.ErrFunctionNotFound) {
+		t.Fatalf("missing tool err = %v", results[0].Err)
+	}
+
+	if want := []string{"fail", "conversationalResponse"}; !reflect.DeepEqual(unknown.Available, want) {
+		t.Errorf("Available = %q, want %q", unknown.Available, want)
+	}
+
+	var execution *gochain.ToolExecutionError
+	if !errors.As(results[1].Err, &execution) || execution.Tool != "fail" || execution.Input["id"] != float64(1) {
+		t.Fatalf("failing tool err = %v", results[1].Err)
+	}
+
+	if !errors.Is(results[1].Err, boom) || results[1].Err.Error() != `tool "fail": boom` {
+		t.Errorf("err = %q, want the handler error wrapped", results[1].Err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM("Sure! Here is the weather."))
+
+	_, err := chain.Run(context.Background(), "weather?")
+
+	var parse *gochain.ParseError
+	if !errors.As(err, &parse) || parse.Raw != "Sure! Here is the weather." {
+		t.Fatalf("err = %v, want a ParseError with the raw output", err)
+	}
+
+	if !errors.Is(err, gochain.ErrInvalidResponse) || parse.Cause == nil {
+		t.Errorf("err = %v, want ErrInvalidResponse with its cause", err)
+	}
+}
@@ -1,51 +1,15 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/documentloader"
 	"github.com/ryanbekhen/gochain/llm/ollama"
-	"golang.org/x/net/html"
 	"io"
 	"net/http"
 )
 
-func ExtractText(n *html.Node, buf *bytes.Buffer) {
-	// Remove style tags
-	if n.Type == html.ElementNode && n.Data == "style" {
-		return
-	}
-	if n.Type == html.TextNode {
-		buf.WriteString(n.Data)
-	}
-	// Remove style attribute
-	if n.Type == html.ElementNode {
-		var i int
-		for _, attr := range n.Attr {
-			if attr.Key == "style" {
-				continue
-			}
-			n.Attr[i] = attr
-			i++
-		}
-		n.Attr = n.Attr[:i]
-	}
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		ExtractText(c, buf)
-	}
-}
-
-func CleanHTML(htmlString string) (string, error) {
-	doc, err := html.Parse(bytes.NewBufferString(htmlString))
-	if err != nil {
-		return "", err
-	}
-	var buf bytes.Buffer
-	ExtractText(doc, &buf)
-	return buf.String(), nil
-}
-
 func getWeather(location string, unit ...string) (string, error) {
 	urlApi := fmt.Sprintf("https://wttr.in/%s?0", location)
 	resp, err := http.Get(urlApi)
@@ -106,7 +70,7 @@ func main() {
 			return err
 		}
 
-		weather, err = CleanHTML(weather)
+		weather, err = documentloader.CleanHTML(weather)
 		if err != nil {
 			return err
 		}
@@ -147,12 +111,15 @@ func main() {
 		return nil
 	})
 
-	chain.RegisterConversationalFunction(func(response string) {
-		fmt.Println("test conversational function called")
-		fmt.Println(response)
-	})
-
-	if err := chain.Invoke(context.Background(), message); err != nil {
+	results, err := chain.Run(context.Background(), message)
+	if err != nil {
 		fmt.Println(err)
 	}
+
+	// A conversational reply is the output of the fallback function.
+	for _, r := range results {
+		if response, ok := r.Output.(string); ok {
+			fmt.Println(response)
+		}
+	}
 }
@@ -0,0 +1,86 @@
+package gochain
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type ExampleSelector interface {
+	SelectExamples(ctx context.Context, query string, examples []Example) ([]Example, error)
+}
+
+// LengthExampleSelector keeps examples in registration order while their
+// rendered turns fit in MaxTokens.
+type LengthExampleSelector struct {
+	MaxTokens int
+	Tokenizer Tokenizer
+}
+
+func NewLengthExampleSelector(maxTokens int, tokenizer Tokenizer) *LengthExampleSelector {
+	return &LengthExampleSelector{MaxTokens: maxTokens, Tokenizer: tokenizer}
+}
+
+func (s *LengthExampleSelector) SelectExamples(_ context.Context, _ string, examples []Example) ([]Example, error) {
+	t := s.Tokenizer
+	if t == nil {
+		t = defaultTokenizer()
+	}
+
+	budget := s.MaxTokens
+	var selected []Example
+	for _, e := range examples {
+		cost := CountMessages(t, exampleMessages(e))
+		if cost > budget {
+			continue
+		}
+
+		budget -= cost
+		selected = append(selected, e)
+	}
+
+	return selected, nil
+}
+
+// SemanticExampleSelector picks the K examples whose input is most similar
+// to the user message. Example embeddings are computed once and cached.
+type SemanticExampleSelector struct {
+	Embedder  Embedder
+	K         int
+	Threshold float64
+
+	ranker embeddingRanker
+}
+
+func NewSemanticExampleSelector(embedder Embedder, k int) *SemanticExampleSelector {
+	return &SemanticExampleSelector{Embedder: embedder, K: k}
+}
+
+func (s *SemanticExampleSelector) SelectExamples(ctx context.Context, query string, examples []Example) ([]Example, error) {
+	inputs := make([]string, len(examples))
+	for i, e := range examples {
+		inputs[i] = e.Input
+	}
+
+	ranked, err := s.ranker.rank(ctx, s.Embedder, query, inputs, s.K, s.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]Example, len(ranked))
+	for i, index := range ranked {
+		selected[i] = examples[index]
+	}
+
+	return selected, nil
+}
+
+// exampleMessages renders an example as the user turn and the assistant
+// tool call that answers it.
+func exampleMessages(e Example) []Message {
+	call, _ := json.Marshal(FunctionResponse{Tool: e.Tool, ToolInput: e.ToolInput})
+
+	return []Message{
+		{Role: "user", Content: e.Input},
+		{Role: "assistant", Content: string(call)},
+	}
+}
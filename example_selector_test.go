@@ -0,0 +1,118 @@
+package gochain_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var examples = []gochain.Example{
+	{Tool: "get_weather", Input: "what is the weather in Paris", ToolInput: map[string]interface{}{"city": "Paris"}},
+	{Tool: "get_weather", Input: strings.Repeat("a very long question about the weather ", 20), ToolInput: map[string]interface{}{"city": "Oslo"}},
+	{Tool: "book_flight", Input: "book a flight to Rome", ToolInput: map[string]interface{}{"to": "Rome"}},
+}
+
+func inputs(examples []gochain.Example) []string {
+	in := make([]string, len(examples))
+	for i, e := range examples {
+		in[i] = e.Input
+	}
+
+	return in
+}
+
+// words counts whitespace separated words.
+type words struct{}
+
+func (words) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestLengthExampleSelector(t *testing.T) {
+	// Budget for the two short examples only, the long one is skipped but
+	// does not stop the selection. Every rendered turn is one word of role
+	// and one word of tool call on top of the question.
+	budget := 4*(gochain.MessageOverhead+1) + 2 + words{}.Count(examples[0].Input+" "+examples[2].Input)
+	selector := gochain.NewLengthExampleSelector(budget, words{})
+
+	selected, err := selector.SelectExamples(context.Background(), "", examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{examples[0].Input, examples[2].Input}; !reflect.DeepEqual(inputs(selected), want) {
+		t.Errorf("selected %q, want %q", inputs(selected), want)
+	}
+
+	selector.MaxTokens = budget - 1
+	if selected, _ := selector.SelectExamples(context.Background(), "", examples); len(selected) != 1 {
+		t.Errorf("selected %d examples, want 1", len(selected))
+	}
+}
+
+func TestSemanticExampleSelector(t *testing.T) {
+	fake := gochaintest.NewFakeLLM()
+	selector := gochain.NewSemanticExampleSelector(fake, 1)
+
+	selected, err := selector.SelectExamples(context.Background(), "book a flight to Rome tomorrow", examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"book a flight to Rome"}; !reflect.DeepEqual(inputs(selected), want) {
+		t.Errorf("selected %q, want %q", inputs(selected), want)
+	}
+
+	if _, err := selector.SelectExamples(context.Background(), "weather in Paris", examples); err != nil {
+		t.Fatal(err)
+	}
+
+	// The examples are embedded once, later calls embed the query only.
+	calls := fake.EmbedCalls()
+	if len(calls) != 3 || len(calls[0]) != len(examples) || len(calls[2]) != 1 {
+		t.Errorf("embed calls = %q", calls)
+	}
+
+	selector.Threshold = 1.1
+	if selected, _ := selector.SelectExamples(context.Background(), "weather in Paris", examples); len(selected) != 0 {
+		t.Errorf("selected %q above an impossible threshold", inputs(selected))
+	}
+}
+
+func TestExamplesInPrompt(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "ok"}))
+
+	chain := gochain.New(fake)
+	chain.RegisterConversationalFunction(func(string) {})
+	err := chain.RegisterTool("get_weather", "Get the weather", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		return "sunny", nil
+	}, gochain.WithExamples(gochain.Example{Input: "weather in Paris?", ToolInput: map[string]interface{}{"city": "Paris"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Invoke(context.Background(), "weather in Rome?"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := fake.LastCall().Messages
+	if len(messages) != 4 {
+		t.Fatalf("prompt has %d messages, want system, example turn and question", len(messages))
+	}
+
+	if messages[1].Role != "user" || messages[1].Content != "weather in Paris?" {
+		t.Errorf("example question = %+v", messages[1])
+	}
+
+	// The example is filled in with the tool it belongs to.
+	if messages[2].Role != "assistant" || messages[2].Content != `{"tool":"get_weather","toolInput":{"city":"Paris"}}` {
+		t.Errorf("example answer = %+v", messages[2])
+	}
+
+	if messages[3].Content != "weather in Rome?" {
+		t.Errorf("question = %+v", messages[3])
+	}
+}
@@ -0,0 +1,91 @@
+package gochain
+
+import (
+	"context"
+	"fmt"
+)
+
+const conversationalResponse = "conversationalResponse"
+
+// DefaultFallbackFunction returns the function the model picks when no
+// other tool applies. Its handler returns the conversational response as
+// the tool output.
+func DefaultFallbackFunction() *Function {
+	return &Function{
+		Name:        conversationalResponse,
+		Description: "Respond conversationally if no other tools should be called for a given query.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"response": map[string]interface{}{
+					"type":        "string",
+					"description": "Conversational response to the user with using same language.",
+				},
+			},
+			"required": []string{"response"},
+		},
+		Handler: func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+			resp, ok := input["response"].(string)
+			if !ok {
+				return nil, ErrInvalidResponse
+			}
+
+			return resp, nil
+		},
+	}
+}
+
+// SetFallbackFunction replaces the function offered to the model when no
+// other tool applies, for example to escalate to a human. It is always
+// offered regardless of filters and retrieval, and its result is final:
+// its output is returned instead of being sent back to the model. With
+// native tool calling the fallback is called with the model text as
+// "response" when the model calls no tool. A nil f disables the fallback.
+// SetFallbackFunction returns ErrFallbackFunctionName when a registered
+// function already has the name of f.
+func (a *Chain) SetFallbackFunction(f *Function) error {
+	if f != nil && !validFunctionName(f.Name) {
+		return ErrInvalidFunctionName
+	}
+
+	f = withHandler(f)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if f != nil {
+		for _, existing := range a.fn {
+			if existing.Name == f.Name {
+				return fmt.Errorf("%w: %q", ErrFallbackFunctionName, f.Name)
+			}
+		}
+	}
+
+	a.fallback = f
+
+	return nil
+}
+
+// WithFallbackFunction replaces the fallback function for one call. The
+// call fails with ErrFallbackFunctionName when a registered function has
+// the name of f.
+func WithFallbackFunction(f *Function) InvokeOption {
+	f = withHandler(f)
+
+	return func(inv *invocation) {
+		inv.fallback = f
+	}
+}
+
+// withHandler returns a copy of f with Handler set from Function when only
+// the latter is given.
+func withHandler(f *Function) *Function {
+	if f == nil || f.Handler != nil || f.Function == nil {
+		return f
+	}
+
+	c := *f
+	c.Handler = wrapFunctionHandler(f.Function)
+
+	return &c
+}
@@ -0,0 +1,133 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"testing"
+)
+
+func TestFallbackReplyIsAResult(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "Hello!"}))
+	chain := gochain.New(llm)
+
+	results, err := chain.Run(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Tool != "conversationalResponse" || results[0].Output != "Hello!" {
+		t.Errorf("results = %+v", results)
+	}
+
+	// Without a conversational handler the reply is not an error.
+	if err := chain.Invoke(context.Background(), "hi"); err != nil {
+		t.Errorf("Invoke() = %v", err)
+	}
+}
+
+func TestConversationalHandlerStillNotified(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "Hello!"}))
+	chain := gochain.New(llm)
+
+	var replies []string
+	chain.RegisterConversationalFunction(func(response string) {
+		replies = append(replies, response)
+	})
+
+	if err := chain.Invoke(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	var perCall string
+	if err := chain.Invoke(context.Background(), "hi", gochain.WithConversationalHandler(func(response string) {
+		perCall = response
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replies) != 1 || replies[0] != "Hello!" || perCall != "Hello!" {
+		t.Errorf("replies = %q, per call handler got %q", replies, perCall)
+	}
+}
+
+func TestCustomFallbackFunction(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("escalate", map[string]interface{}{"reason": "angry"}))
+	chain := gochain.New(llm)
+
+	escalate := &gochain.Function{
+		Name: "escalate",
+		Handler: func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+			return "ticket for " + input["reason"].(string), nil
+		},
+	}
+	if err := chain.SetFallbackFunction(escalate); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fallback output is final, it is not sent back to the model.
+	results, err := chain.Run(context.Background(), "this is broken!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if llm.CallCount() != 1 || len(results) != 1 || results[0].Output != "ticket for angry" {
+		t.Errorf("%d model calls, results = %+v", llm.CallCount(), results)
+	}
+
+	if got := offered(llm); len(got) != 1 || got[0] != "escalate" {
+		t.Errorf("offered %q", got)
+	}
+
+	if err := chain.RegisterTool("escalate", "", nil, nop); !errors.Is(err, gochain.ErrFallbackFunctionName) {
+		t.Errorf("err = %v, want ErrFallbackFunctionName", err)
+	}
+}
+
+func TestFallbackFunctionNameClash(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("search", map[string]interface{}{}))
+	chain := gochain.New(llm)
+	if err := chain.RegisterTool("search", "", nil, nop); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.SetFallbackFunction(&gochain.Function{Name: "search", Handler: nop}); !errors.Is(err, gochain.ErrFallbackFunctionName) {
+		t.Errorf("SetFallbackFunction() err = %v, want ErrFallbackFunctionName", err)
+	}
+
+	if err := chain.SetFallbackFunction(&gochain.Function{Name: "not valid", Handler: nop}); !errors.Is(err, gochain.ErrInvalidFunctionName) {
+		t.Errorf("SetFallbackFunction() err = %v, want ErrInvalidFunctionName", err)
+	}
+
+	_, err := chain.Run(context.Background(), "hi", gochain.WithFallbackFunction(&gochain.Function{Name: "search", Handler: nop}))
+	if !errors.Is(err, gochain.ErrFallbackFunctionName) {
+		t.Errorf("Run() err = %v, want ErrFallbackFunctionName", err)
+	}
+
+	if llm.CallCount() != 0 {
+		t.Errorf("model called %d times", llm.CallCount())
+	}
+}
+
+func TestNoFallbackFunction(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "Hello!"}))
+	chain := gochain.New(llm)
+	if err := chain.SetFallbackFunction(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := chain.Run(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || !errors.Is(results[0].Err, gochain.ErrFunctionNotFound) {
+		t.Errorf("results = %+v, want the unknown tool", results)
+	}
+
+	// The name is free for a regular function.
+	if err := chain.RegisterTool("conversationalResponse", "", nil, nop); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,10 +1,39 @@
 package gochain
 
+import "context"
+
 type Function struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	Parameters  interface{}     `json:"parameters"`
 	Function    FunctionHandler `json:"-"`
+	Handler     ToolHandler     `json:"-"`
+	Examples    []Example       `json:"-"`
+	Groups      []string        `json:"-"`
+}
+
+// Example is a user utterance and the input the model should answer it
+// with. Tool is filled in with the function name on registration.
+type Example struct {
+	Tool      string                 `json:"tool"`
+	Input     string                 `json:"input"`
+	ToolInput map[string]interface{} `json:"toolInput"`
+}
+
+type FunctionOption func(*Function)
+
+func WithExamples(examples ...Example) FunctionOption {
+	return func(f *Function) {
+		f.Examples = append(f.Examples, examples...)
+	}
+}
+
+// WithGroups tags a function so invocations can enable it by group, for
+// example to offer admin tools only to admins.
+func WithGroups(groups ...string) FunctionOption {
+	return func(f *Function) {
+		f.Groups = append(f.Groups, groups...)
+	}
 }
 
 type FunctionResponse struct {
@@ -12,9 +41,19 @@ type FunctionResponse struct {
 	ToolInput map[string]interface{} `json:"toolInput"`
 }
 
+// ToolResult is the outcome of one tool call. A non-nil Output from any
+// tool is sent back to the model so it can use it in its answer.
+type ToolResult struct {
+	Tool   string                 `json:"tool"`
+	Input  map[string]interface{} `json:"toolInput"`
+	Output interface{}            `json:"output,omitempty"`
+	Err    error                  `json:"-"`
+}
+
 type ConversationalFunctionResponse struct {
 	Response string `json:"response"`
 }
 
 type FunctionHandler func(params interface{}) error
+type ToolHandler func(ctx context.Context, input map[string]interface{}) (interface{}, error)
 type ConversationalFunctionHandler func(response string)
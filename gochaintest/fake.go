@@ -0,0 +1,316 @@
+// Package gochaintest provides a scriptable LLM for testing code built on
+// gochain without a model.
+package gochaintest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoResponse = errors.New("gochaintest: no scripted response left")
+
+// Response is what the fake answers to one call. Err makes the call fail
+// and Delay holds the answer back, honouring the context.
+type Response struct {
+	Content    string
+	Generation *gochain.Generation
+	Chunks     []string
+	Err        error
+	Delay      time.Duration
+}
+
+func Text(content string) Response {
+	return Response{Content: content}
+}
+
+func Error(err error) Response {
+	return Response{Err: err}
+}
+
+// ToolCall answers with a tool call in the format Chain expects from a
+// model.
+func ToolCall(tool string, input map[string]interface{}) Response {
+	data, _ := json.Marshal(gochain.FunctionResponse{Tool: tool, ToolInput: input})
+	return Response{Content: string(data)}
+}
+
+// Matcher selects the calls a rule answers.
+type Matcher func(messages []gochain.Message) bool
+
+// LastMessageContains matches calls whose last message contains text.
+func LastMessageContains(text string) Matcher {
+	return func(messages []gochain.Message) bool {
+		return len(messages) > 0 && strings.Contains(messages[len(messages)-1].Text(), text)
+	}
+}
+
+// LastMessageRole matches calls whose last message has the given role,
+// such as "tool" for the follow-up after tool calls.
+func LastMessageRole(role string) Matcher {
+	return func(messages []gochain.Message) bool {
+		return len(messages) > 0 && messages[len(messages)-1].Role == role
+	}
+}
+
+// Call is a recorded request.
+type Call struct {
+	Messages []gochain.Message
+	Options  []map[string]interface{}
+}
+
+type rule struct {
+	match    Matcher
+	response Response
+}
+
+// FakeLLM implements gochain.LLM, gochain.GenerationLLM,
+// gochain.StreamingLLM and gochain.Embedder. Calls are answered by the
+// first matching rule, then by the scripted responses in order, then by
+// the default response.
+type FakeLLM struct {
+	mu         sync.Mutex
+	name       string
+	model      string
+	rules      []rule
+	script     []Response
+	fallback   *Response
+	calls      []Call
+	embedCalls [][]string
+	embed      func(text string) []float64
+}
+
+// NewFakeLLM scripts a text response per call.
+func NewFakeLLM(responses ...string) *FakeLLM {
+	f := &FakeLLM{name: "fake", model: "fake"}
+	for _, r := range responses {
+		f.script = append(f.script, Text(r))
+	}
+
+	return f
+}
+
+func (f *FakeLLM) Name() string {
+	return f.name
+}
+
+func (f *FakeLLM) SetName(name string) {
+	f.name = name
+}
+
+func (f *FakeLLM) Model() string {
+	return f.model
+}
+
+func (f *FakeLLM) SetModel(model string) {
+	f.model = model
+}
+
+// Respond appends responses to the script.
+func (f *FakeLLM) Respond(responses ...Response) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.script = append(f.script, responses...)
+
+	return f
+}
+
+// On answers every call matching match with response.
+func (f *FakeLLM) On(match Matcher, response Response) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = append(f.rules, rule{match: match, response: response})
+
+	return f
+}
+
+// Default answers calls once the script is exhausted.
+func (f *FakeLLM) Default(response Response) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fallback = &response
+
+	return f
+}
+
+// SetEmbedding replaces the default embedding, a hashed bag of words.
+func (f *FakeLLM) SetEmbedding(embed func(text string) []float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.embed = embed
+}
+
+func (f *FakeLLM) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call{}, f.calls...)
+}
+
+func (f *FakeLLM) CallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.calls)
+}
+
+// LastCall returns the most recent call, or the zero Call if none.
+func (f *FakeLLM) LastCall() Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.calls) == 0 {
+		return Call{}
+	}
+
+	return f.calls[len(f.calls)-1]
+}
+
+// EmbedCalls returns the texts of every Embed call.
+func (f *FakeLLM) EmbedCalls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([][]string{}, f.embedCalls...)
+}
+
+func (f *FakeLLM) next(messages []gochain.Message, options []map[string]interface{}) (Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{
+		Messages: append([]gochain.Message{}, messages...),
+		Options:  append([]map[string]interface{}{}, options...),
+	})
+
+	for _, r := range f.rules {
+		if r.match(messages) {
+			return r.response, nil
+		}
+	}
+
+	if len(f.script) > 0 {
+		r := f.script[0]
+		f.script = f.script[1:]
+		return r, nil
+	}
+
+	if f.fallback != nil {
+		return *f.fallback, nil
+	}
+
+	return Response{}, ErrNoResponse
+}
+
+func (f *FakeLLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := f.ChatStream(ctx, messages, nil, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (f *FakeLLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	return f.ChatStream(ctx, messages, nil, options...)
+}
+
+// ChatStream sends the response Chunks to fn, or its content split after
+// each space when no chunks are set.
+func (f *FakeLLM) ChatStream(ctx context.Context, messages []gochain.Message, fn func(chunk string) error, options ...map[string]interface{}) (*gochain.Generation, error) {
+	r, err := f.next(messages, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Delay > 0 {
+		select {
+		case <-time.After(r.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	g := gochain.Generation{Content: r.Content, Provider: f.name, Model: f.model, FinishReason: "stop"}
+	if r.Generation != nil {
+		g = *r.Generation
+	}
+
+	if g.Content == "" && len(r.Chunks) > 0 {
+		g.Content = strings.Join(r.Chunks, "")
+	}
+
+	if fn != nil {
+		chunks := r.Chunks
+		if len(chunks) == 0 {
+			chunks = strings.SplitAfter(g.Content, " ")
+		}
+
+		for _, chunk := range chunks {
+			if err := fn(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &g, nil
+}
+
+const embeddingSize = 64
+
+func (f *FakeLLM) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	f.mu.Lock()
+	f.embedCalls = append(f.embedCalls, append([]string{}, texts...))
+	embed := f.embed
+	f.mu.Unlock()
+
+	if embed == nil {
+		embed = bagOfWords
+	}
+
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = embed(text)
+	}
+
+	return vectors, nil
+}
+
+// bagOfWords hashes words into a normalized vector, so texts sharing words
+// come out similar.
+func bagOfWords(text string) []float64 {
+	v := make([]float64, embeddingSize)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,!?;:\"'()")))
+		v[h.Sum32()%embeddingSize]++
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+
+	return v
+}
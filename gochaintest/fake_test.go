@@ -0,0 +1,180 @@
+package gochaintest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"testing"
+	"time"
+)
+
+func chat(t *testing.T, llm gochain.LLM, content string) string {
+	t.Helper()
+
+	got, err := llm.Chat(context.Background(), []gochain.Message{{Role: "user", Content: content}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestPrecedence(t *testing.T) {
+	fake := gochaintest.NewFakeLLM("first", "second").
+		On(gochaintest.LastMessageContains("weather"), gochaintest.Text("sunny")).
+		Default(gochaintest.Text("default"))
+
+	for _, tt := range []struct {
+		message string
+		want    string
+	}{
+		// Rules win over the script and do not consume it.
+		{"what is the weather", "sunny"},
+		{"hello", "first"},
+		{"weather again", "sunny"},
+		{"hello", "second"},
+		// The default answers once the script is used up.
+		{"hello", "default"},
+		{"hello", "default"},
+	} {
+		if got := chat(t, fake, tt.message); got != tt.want {
+			t.Errorf("Chat(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+
+	if got := fake.CallCount(); got != 6 {
+		t.Errorf("CallCount() = %d, want 6", got)
+	}
+
+	if got := fake.LastCall().Messages[0].Content; got != "hello" {
+		t.Errorf("LastCall() message = %q", got)
+	}
+}
+
+func TestNoResponse(t *testing.T) {
+	fake := gochaintest.NewFakeLLM("only")
+	chat(t, fake, "hi")
+
+	_, err := fake.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, gochaintest.ErrNoResponse) {
+		t.Errorf("err = %v, want ErrNoResponse", err)
+	}
+}
+
+func TestScriptedError(t *testing.T) {
+	boom := errors.New("boom")
+	fake := gochaintest.NewFakeLLM().Respond(gochaintest.Error(boom), gochaintest.Text("ok"))
+
+	if _, err := fake.Chat(context.Background(), nil); err != boom {
+		t.Errorf("err = %v, want %v", err, boom)
+	}
+
+	if got := chat(t, fake, "again"); got != "ok" {
+		t.Errorf("Chat() = %q, want ok", got)
+	}
+}
+
+func TestDelayHonoursContext(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().Respond(gochaintest.Response{Content: "late", Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fake.Chat(ctx, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Chat() returned after %v, the delay ignored the context", elapsed)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().Respond(gochaintest.Response{Content: "late", Delay: 20 * time.Millisecond})
+
+	start := time.Now()
+	if got := chat(t, fake, "hi"); got != "late" {
+		t.Errorf("Chat() = %q", got)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Chat() returned after %v, before the delay", elapsed)
+	}
+}
+
+func TestStreamChunks(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().Respond(gochaintest.Response{Chunks: []string{"Hel", "lo"}})
+
+	var chunks []string
+	g, err := gochain.Stream(context.Background(), fake, nil, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chunks) != 2 || g.Content != "Hello" {
+		t.Errorf("chunks = %q, content = %q", chunks, g.Content)
+	}
+}
+
+func TestToolCallThroughChain(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().
+		On(gochaintest.LastMessageRole("tool"), gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "It is sunny in Paris."})).
+		On(gochaintest.LastMessageContains("weather"), gochaintest.ToolCall("get_weather", map[string]interface{}{"city": "Paris"}))
+
+	chain := gochain.New(fake)
+
+	var city interface{}
+	err := chain.RegisterTool("get_weather", "Get the weather of a city", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		city = input["city"]
+		return "sunny", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reply string
+	chain.RegisterConversationalFunction(func(response string) {
+		reply = response
+	})
+
+	if err := chain.Invoke(context.Background(), "What is the weather in Paris?"); err != nil {
+		t.Fatal(err)
+	}
+
+	if city != "Paris" {
+		t.Errorf("tool called with city %v, want Paris", city)
+	}
+
+	if reply != "It is sunny in Paris." {
+		t.Errorf("reply = %q", reply)
+	}
+
+	if got := fake.CallCount(); got != 2 {
+		t.Errorf("CallCount() = %d, want the tool call and the follow-up", got)
+	}
+}
+
+func TestEmbedSimilarity(t *testing.T) {
+	fake := gochaintest.NewFakeLLM()
+
+	vectors, err := fake.Embed(context.Background(), []string{"the weather in Paris", "weather in Paris today", "bake a cake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	related := gochain.CosineSimilarity(vectors[0], vectors[1])
+	unrelated := gochain.CosineSimilarity(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("similarity of related texts %v, unrelated %v", related, unrelated)
+	}
+
+	if got := len(fake.EmbedCalls()); got != 1 {
+		t.Errorf("EmbedCalls() = %d, want 1", got)
+	}
+}
@@ -0,0 +1,16 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// Discard is a logger that drops every record, used when none is set.
+var Discard = slog.New(discardHandler{})
@@ -3,7 +3,7 @@ package prompt
 var FunctionsToCall = `
 You have access to the following tools:
 
-{functionsToCall}
+{{.functions}}
 
 You must always select one of the above tools and respond with only a JSON object matching the following schema:
 
@@ -12,4 +12,6 @@ You must always select one of the above tools and respond with only a JSON objec
 	"toolInput": <parameters for the selected tool, matching the tool's JSON schema>
 }
 
+When the request needs several independent tool calls, respond instead with a JSON array of such objects, one per call.
+
 `
@@ -1,13 +1,152 @@
 package gochain
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 type Message struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role    string        `json:"role,omitempty"`
+	Content string        `json:"content,omitempty"`
+	Parts   []ContentPart `json:"parts,omitempty"`
+}
+
+func NewMessage(role string, parts ...ContentPart) Message {
+	return Message{Role: role, Parts: parts}
+}
+
+// Text returns Content followed by every text part, joined by newlines.
+func (m Message) Text() string {
+	texts := make([]string, 0, len(m.Parts)+1)
+	if m.Content != "" {
+		texts = append(texts, m.Content)
+	}
+
+	for _, p := range m.Parts {
+		if p.Type == ContentPartText && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+func (m Message) Images() []ContentPart {
+	var images []ContentPart
+	for _, p := range m.Parts {
+		if p.IsImage() {
+			images = append(images, p)
+		}
+	}
+
+	return images
 }
 
 type LLM interface {
 	Name() string
 	Chat(ctx context.Context, messages []Message, options ...map[string]interface{}) (string, error)
 }
+
+type ToolCallResponse struct {
+	Content string
+	Calls   []FunctionResponse
+}
+
+// ToolCallingLLM is implemented by backends with a native tool calling
+// API. The chain only uses it when enabled with SetNativeToolCalling.
+type ToolCallingLLM interface {
+	LLM
+	ChatWithTools(ctx context.Context, messages []Message, tools []*Function, options ...map[string]interface{}) (*ToolCallResponse, error)
+}
+
+// Generation is a chat completion with what is known about how it was
+// produced.
+type Generation struct {
+	Content      string
+	Provider     string
+	Model        string
+	FinishReason string
+	Usage        Usage
+	Metadata     map[string]interface{}
+}
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// GenerationLLM is implemented by backends that report token usage and
+// other details along with the completion.
+type GenerationLLM interface {
+	LLM
+	ChatGeneration(ctx context.Context, messages []Message, options ...map[string]interface{}) (*Generation, error)
+}
+
+// Generate runs a chat completion, with details when llm is a
+// GenerationLLM and the content and provider only otherwise.
+func Generate(ctx context.Context, llm LLM, messages []Message, options ...map[string]interface{}) (*Generation, error) {
+	if g, ok := llm.(GenerationLLM); ok {
+		return g.ChatGeneration(ctx, messages, options...)
+	}
+
+	content, err := llm.Chat(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Generation{Content: content, Provider: llm.Name()}, nil
+}
+
+// StreamingLLM is implemented by backends that deliver the completion in
+// chunks as it is generated.
+type StreamingLLM interface {
+	LLM
+	ChatStream(ctx context.Context, messages []Message, fn func(chunk string) error, options ...map[string]interface{}) (*Generation, error)
+}
+
+// Stream runs a chat completion passing chunks to fn as they arrive. When
+// llm does not stream, fn receives the whole completion at once.
+func Stream(ctx context.Context, llm LLM, messages []Message, fn func(chunk string) error, options ...map[string]interface{}) (*Generation, error) {
+	if s, ok := llm.(StreamingLLM); ok {
+		return s.ChatStream(ctx, messages, fn, options...)
+	}
+
+	g, err := Generate(ctx, llm, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if fn != nil {
+		if err := fn(g.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// JSONModeLLM is implemented by backends that can be told to answer with
+// JSON only. JSONMode adds the options enabling it to the call options.
+// Wrappers implement it by forwarding to the LLM they wrap.
+type JSONModeLLM interface {
+	LLM
+	JSONMode(options map[string]interface{})
+}
+
+// EnableJSONMode adds the options that make llm answer with JSON only, and
+// leaves options as they are when llm has no JSON mode.
+func EnableJSONMode(llm LLM, options map[string]interface{}) {
+	if j, ok := llm.(JSONModeLLM); ok {
+		j.JSONMode(options)
+	}
+}
+
+// modelOf returns the model of backends that expose one.
+func modelOf(llm LLM) string {
+	if m, ok := llm.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
@@ -0,0 +1,74 @@
+// Package bolt is a cache.Store keeping every entry in a single bbolt file.
+package bolt
+
+import (
+	"context"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+var bucketName = []byte("gochain_cache")
+
+type Store struct {
+	db *bbolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func New(db *bbolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Get(_ context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		// Values are only valid inside the transaction.
+		if v := tx.Bucket(bucketName).Get([]byte(key)); v != nil {
+			value = append([]byte{}, v...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, value != nil, nil
+}
+
+func (s *Store) Set(_ context.Context, key string, value []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), value)
+	})
+}
+
+func (s *Store) Delete(_ context.Context, key string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(key))
+	})
+}
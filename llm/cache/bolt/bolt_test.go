@@ -0,0 +1,107 @@
+package bolt_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/cache"
+	"github.com/ryanbekhen/gochain/llm/cache/bolt"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	s, err := bolt.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := s.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+
+	if err := s.Set(ctx, "a", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok, err := s.Get(ctx, "a")
+	if err != nil || !ok || string(v) != "value" {
+		t.Fatalf("Get(a) = %q, %v, %v", v, ok, err)
+	}
+
+	// Entries survive reopening the file.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = bolt.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if v, ok, _ := s.Get(ctx, "a"); !ok || string(v) != "value" {
+		t.Errorf("Get(a) after reopening = %q, %v", v, ok)
+	}
+
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("entry kept after Delete")
+	}
+}
+
+func TestCorruptEntryIsMiss(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := bolt.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("hello"))
+	l := cache.New(llm, s)
+	messages := []gochain.Message{{Role: "user", Content: "hi"}}
+	options := map[string]interface{}{"temperature": 0}
+
+	if _, err := l.Chat(context.Background(), messages, options); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite every entry behind the store's back.
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("gochain_cache"))
+
+		var keys [][]byte
+		if err := b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := b.Put(k, []byte("not json")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := l.Chat(context.Background(), messages, options); err != nil || got != "hello" || llm.CallCount() != 2 {
+		t.Errorf("Chat() = %q, %v after %d calls, want a miss", got, err, llm.CallCount())
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/ryanbekhen/gochain/llm/cache/bolt
+
+go 1.22.4
+
+require (
+	github.com/ryanbekhen/gochain v0.0.0
+	go.etcd.io/bbolt v1.3.11
+)
+
+require golang.org/x/sys v0.26.0 // indirect
+
+replace github.com/ryanbekhen/gochain => ../../..
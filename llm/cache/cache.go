@@ -0,0 +1,224 @@
+// Package cache stores LLM responses and embeddings so repeated requests
+// are answered without calling the provider.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ryanbekhen/gochain"
+	"time"
+)
+
+// MetadataHit is the Generation.Metadata key set on responses served from
+// the cache.
+const MetadataHit = "cache.hit"
+
+// Store holds cached values by key. Get reports false when the key is
+// missing.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+type Option func(*options)
+
+type options struct {
+	ttl   time.Duration
+	force bool
+	now   func() time.Time
+}
+
+func newOptions(opts []Option) options {
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithTTL expires entries older than ttl. Zero keeps them forever.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithForce caches responses whatever the temperature of the request.
+func WithForce() Option {
+	return func(o *options) {
+		o.force = true
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// entry is the stored form of a cached value.
+type entry struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+func (o options) get(ctx context.Context, store Store, key string, value interface{}) (bool, error) {
+	data, ok, err := store.Get(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// Expired and unreadable entries are misses, and are dropped so the
+	// next response replaces them.
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false, store.Delete(ctx, key)
+	}
+
+	if o.ttl > 0 && o.now().Sub(e.CreatedAt) > o.ttl {
+		return false, store.Delete(ctx, key)
+	}
+
+	if err := json.Unmarshal(e.Value, value); err != nil {
+		return false, store.Delete(ctx, key)
+	}
+
+	return true, nil
+}
+
+func (o options) set(ctx context.Context, store Store, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	data, err = json.Marshal(entry{CreatedAt: o.now(), Value: data})
+	if err != nil {
+		return err
+	}
+
+	return store.Set(ctx, key, data)
+}
+
+// Key hashes the parts of a request that determine its response.
+func Key(parts ...interface{}) (string, error) {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func modelOf(v interface{}) string {
+	if m, ok := v.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+// LLM answers repeated requests to the wrapped LLM from a Store. Only
+// requests setting a temperature of 0 are cached, see Deterministic.
+type LLM struct {
+	llm   gochain.LLM
+	store Store
+	opts  options
+}
+
+func New(llm gochain.LLM, store Store, opts ...Option) *LLM {
+	return &LLM{llm: llm, store: store, opts: newOptions(opts)}
+}
+
+func (l *LLM) Name() string {
+	return l.llm.Name()
+}
+
+// Model reports the model of the wrapped LLM, if it has one.
+func (l *LLM) Model() string {
+	return modelOf(l.llm)
+}
+
+// JSONMode forwards to the wrapped LLM.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	gochain.EnableJSONMode(l.llm, options)
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	if !l.opts.force && !Deterministic(options) {
+		return gochain.Generate(ctx, l.llm, messages, options...)
+	}
+
+	key, err := Key(l.llm.Name(), l.Model(), messages, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var g gochain.Generation
+	ok, err := l.opts.get(ctx, l.store, key, &g)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		if g.Metadata == nil {
+			g.Metadata = map[string]interface{}{}
+		}
+		g.Metadata[MetadataHit] = true
+
+		return &g, nil
+	}
+
+	generation, err := gochain.Generate(ctx, l.llm, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.opts.set(ctx, l.store, key, generation); err != nil {
+		return nil, err
+	}
+
+	return generation, nil
+}
+
+// Deterministic reports whether the options set a temperature of 0, the
+// only requests expected to get the same answer every time. Providers
+// sample when no temperature is set (Ollama defaults to 0.8), so those
+// requests are not deterministic.
+func Deterministic(options []map[string]interface{}) bool {
+	var temperature interface{}
+	for _, o := range options {
+		if t, ok := o["temperature"]; ok {
+			temperature = t
+		}
+	}
+
+	switch t := temperature.(type) {
+	case float64:
+		return t == 0
+	case float32:
+		return t == 0
+	case int:
+		return t == 0
+	case json.Number:
+		f, err := t.Float64()
+		return err == nil && f == 0
+	default:
+		return false
+	}
+}
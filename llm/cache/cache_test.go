@@ -0,0 +1,265 @@
+package cache_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/cache"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var messages = []gochain.Message{{Role: "user", Content: "hi"}}
+
+// mapStore is a Store whose entries the tests can reach.
+type mapStore map[string][]byte
+
+func (s mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	v, ok := s[key]
+	return v, ok, nil
+}
+
+func (s mapStore) Set(_ context.Context, key string, value []byte) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapStore) Delete(_ context.Context, key string) error {
+	delete(s, key)
+	return nil
+}
+
+// chat sends messages twice and returns the calls that reached llm.
+func chat(t *testing.T, l *cache.LLM, llm *gochaintest.FakeLLM, options ...map[string]interface{}) int {
+	t.Helper()
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Chat(context.Background(), messages, options...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return llm.CallCount()
+}
+
+func TestLLMCachesDeterministicRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options []map[string]interface{}
+		force   bool
+		want    int
+	}{
+		{"no temperature", nil, false, 2},
+		{"zero temperature", []map[string]interface{}{{"temperature": 0}}, false, 1},
+		{"zero float temperature", []map[string]interface{}{{"temperature": 0.0}}, false, 1},
+		{"sampled", []map[string]interface{}{{"temperature": 0.7}}, false, 2},
+		{"last temperature wins", []map[string]interface{}{{"temperature": 0}, {"temperature": 1}}, false, 2},
+		{"forced without temperature", nil, true, 1},
+		{"forced sampled", []map[string]interface{}{{"temperature": 0.7}}, true, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("hello"))
+
+			var opts []cache.Option
+			if tt.force {
+				opts = append(opts, cache.WithForce())
+			}
+
+			if got := chat(t, cache.New(llm, cache.NewLRU(0), opts...), llm, tt.options...); got != tt.want {
+				t.Errorf("%d calls, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLLMHit(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("hello"))
+	l := cache.New(llm, cache.NewLRU(0))
+	options := map[string]interface{}{"temperature": 0}
+
+	first, err := l.ChatGeneration(context.Background(), messages, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Metadata[cache.MetadataHit] != nil {
+		t.Errorf("first response marked as a hit: %v", first.Metadata)
+	}
+
+	second, err := l.ChatGeneration(context.Background(), messages, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Content != "hello" || second.Metadata[cache.MetadataHit] != true {
+		t.Errorf("second response = %+v, want a hit", second)
+	}
+
+	// Other messages and other models are other entries.
+	if _, err := l.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "bye"}}, options); err != nil {
+		t.Fatal(err)
+	}
+
+	llm.SetModel("other")
+	if _, err := l.Chat(context.Background(), messages, options); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := llm.CallCount(); got != 3 {
+		t.Errorf("%d calls, want 3", got)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("hello"))
+	l := cache.New(llm, cache.NewLRU(0), cache.WithTTL(time.Minute), cache.WithClock(func() time.Time { return now }))
+	options := map[string]interface{}{"temperature": 0}
+
+	for _, advance := range []time.Duration{0, time.Minute, time.Second} {
+		now = now.Add(advance)
+		if _, err := l.Chat(context.Background(), messages, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The entry is fresh after a minute, expired a second later.
+	if got := llm.CallCount(); got != 2 {
+		t.Errorf("%d calls, want 2", got)
+	}
+}
+
+func TestCorruptEntryIsMiss(t *testing.T) {
+	for _, value := range []string{"not json", `{"createdAt":"2024-01-01T00:00:00Z","value":"not a generation"}`} {
+		llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("hello"))
+		store := mapStore{}
+		l := cache.New(llm, store)
+		options := map[string]interface{}{"temperature": 0}
+
+		if _, err := l.Chat(context.Background(), messages, options); err != nil {
+			t.Fatal(err)
+		}
+
+		for key := range store {
+			store[key] = []byte(value)
+		}
+
+		got, err := l.Chat(context.Background(), messages, options)
+		if err != nil || got != "hello" || llm.CallCount() != 2 {
+			t.Fatalf("Chat() = %q, %v after %d calls, want a miss", got, err, llm.CallCount())
+		}
+
+		// The corrupt entry was replaced.
+		if _, err := l.Chat(context.Background(), messages, options); err != nil || llm.CallCount() != 2 {
+			t.Errorf("err = %v after %d calls, want a hit", err, llm.CallCount())
+		}
+	}
+}
+
+func TestEmbedder(t *testing.T) {
+	llm := gochaintest.NewFakeLLM()
+	e := cache.NewEmbedder(llm, cache.NewLRU(0))
+
+	first, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := e.Embed(context.Background(), []string{"b", "c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(second[0], first[1]) || !reflect.DeepEqual(second[2], first[0]) {
+		t.Error("cached vectors differ from the first batch")
+	}
+
+	// Only the new text is sent in the second batch.
+	if got := llm.EmbedCalls(); !reflect.DeepEqual(got, [][]string{{"a", "b"}, {"c"}}) {
+		t.Errorf("embed calls = %q", got)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	for _, key := range []string{"a", "b"} {
+		if err := c.Set(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reading a makes b the least recently used.
+	if v, ok, err := c.Get(ctx, "a"); err != nil || !ok || string(v) != "a" {
+		t.Fatalf("Get(a) = %q, %v, %v", v, ok, err)
+	}
+
+	if err := c.Set(ctx, "c", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := c.Get(ctx, "b"); ok || c.Len() != 2 {
+		t.Errorf("b kept, len %d, want it evicted", c.Len())
+	}
+
+	// Overwriting updates in place.
+	if err := c.Set(ctx, "a", []byte("A")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _, _ := c.Get(ctx, "a"); string(v) != "A" || c.Len() != 2 {
+		t.Errorf("Get(a) = %q, len %d", v, c.Len())
+	}
+
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := c.Get(ctx, "a"); ok || c.Len() != 1 {
+		t.Errorf("a kept after Delete, len %d", c.Len())
+	}
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	d, err := cache.NewDir(filepath.Join(root, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := d.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+
+	// Keys that are not plain file names stay inside the directory.
+	for _, key := range []string{"plain", "../escape", ".."} {
+		if err := d.Set(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, ok, err := d.Get(ctx, key); err != nil || !ok || string(v) != key {
+			t.Errorf("Get(%q) = %q, %v, %v", key, v, ok, err)
+		}
+	}
+
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Errorf("%d entries next to the cache directory", len(entries))
+	}
+
+	if err := d.Delete(ctx, "plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := d.Get(ctx, "plain"); ok {
+		t.Error("entry kept after Delete")
+	}
+
+	if err := d.Delete(ctx, "plain"); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+}
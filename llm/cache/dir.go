@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Dir is a Store keeping one file per entry in a directory.
+type Dir struct {
+	dir string
+}
+
+func NewDir(dir string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Dir{dir: dir}, nil
+}
+
+// path maps a key to a file name. Keys made by Key are hex digests and
+// used as is; anything else goes through Key first.
+func (d *Dir) path(key string) string {
+	name := key
+	if filepath.Base(key) != key || key == "." || key == ".." {
+		name, _ = Key(key)
+	}
+
+	return filepath.Join(d.dir, name+".json")
+}
+
+func (d *Dir) Get(_ context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Set writes through a temporary file so readers never see partial
+// entries.
+func (d *Dir) Set(_ context.Context, key string, value []byte) error {
+	f, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), d.path(key))
+}
+
+func (d *Dir) Delete(_ context.Context, key string) error {
+	err := os.Remove(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
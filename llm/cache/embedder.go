@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+)
+
+// Embedder caches the embedding of each text separately, so a batch only
+// sends the texts it has not seen before.
+type Embedder struct {
+	embedder gochain.Embedder
+	store    Store
+	opts     options
+}
+
+func NewEmbedder(embedder gochain.Embedder, store Store, opts ...Option) *Embedder {
+	return &Embedder{embedder: embedder, store: store, opts: newOptions(opts)}
+}
+
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	model := modelOf(e.embedder)
+	if m, ok := e.embedder.(interface{ EmbeddingModel() string }); ok {
+		model = m.EmbeddingModel()
+	}
+
+	vectors := make([][]float64, len(texts))
+	keys := make([]string, len(texts))
+
+	var missing []string
+	var missingIdx []int
+	for i, text := range texts {
+		key, err := Key("embedding", model, text)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+
+		ok, err := e.opts.get(ctx, e.store, key, &vectors[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			missing = append(missing, text)
+			missingIdx = append(missingIdx, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := e.embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(embedded) != len(missing) {
+		return nil, gochain.ErrEmbeddingCount
+	}
+
+	for j, i := range missingIdx {
+		vectors[i] = embedded[j]
+		if err := e.opts.set(ctx, e.store, keys[i], embedded[j]); err != nil {
+			return nil, err
+		}
+	}
+
+	return vectors, nil
+}
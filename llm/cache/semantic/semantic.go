@@ -0,0 +1,316 @@
+// Package semantic answers questions that are paraphrases of earlier ones
+// from a cache, matching them by embedding similarity.
+//
+// Only requests ending with a user message and setting a temperature of 0
+// are cached, see cache.Deterministic and WithForce. A stored answer is
+// reused only when every earlier message and the options match too, so the
+// system prompt and conversation history of a Chain keep entries apart.
+package semantic
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/llm/cache"
+	"sync"
+	"time"
+)
+
+const defaultThreshold = 0.95
+
+// MetadataSimilarity is the Generation.Metadata key holding the similarity
+// of a cache hit to the stored question.
+const MetadataSimilarity = "cache.similarity"
+
+// Eviction chooses the entry dropped when a namespace is full.
+type Eviction int
+
+const (
+	// EvictLRU drops the least recently used entry.
+	EvictLRU Eviction = iota
+	// EvictLFU drops the least frequently used entry.
+	EvictLFU
+	// EvictFIFO drops the oldest entry.
+	EvictFIFO
+)
+
+type Option func(*LLM)
+
+// WithThreshold sets the cosine similarity a question needs to reuse a
+// stored answer. Defaults to 0.95.
+func WithThreshold(threshold float64) Option {
+	return func(l *LLM) {
+		l.threshold = threshold
+	}
+}
+
+// WithMaxEntries caps the entries kept per namespace. Zero is unlimited.
+func WithMaxEntries(n int) Option {
+	return func(l *LLM) {
+		l.maxEntries = n
+	}
+}
+
+// WithEviction sets the entry dropped when a namespace reaches the
+// WithMaxEntries limit. Defaults to EvictLRU.
+func WithEviction(policy Eviction) Option {
+	return func(l *LLM) {
+		l.eviction = policy
+	}
+}
+
+// WithTTL expires entries older than ttl.
+func WithTTL(ttl time.Duration) Option {
+	return func(l *LLM) {
+		l.ttl = ttl
+	}
+}
+
+// WithForce caches answers whatever the temperature of the request.
+func WithForce() Option {
+	return func(l *LLM) {
+		l.force = true
+	}
+}
+
+// WithClock sets the time source used for the TTL and the eviction
+// order.
+func WithClock(now func() time.Time) Option {
+	return func(l *LLM) {
+		l.now = now
+	}
+}
+
+type namespaceKey struct{}
+
+// WithNamespace isolates the cache entries used by requests made with ctx,
+// for example per tenant.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+func namespaceFrom(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+type entry struct {
+	vector     []float64
+	context    string
+	generation gochain.Generation
+	createdAt  time.Time
+	usedAt     time.Time
+	hits       int
+}
+
+// LLM wraps a gochain.LLM, and so any Chain built on it, with a semantic
+// cache.
+type LLM struct {
+	llm        gochain.LLM
+	embedder   gochain.Embedder
+	threshold  float64
+	maxEntries int
+	eviction   Eviction
+	ttl        time.Duration
+	force      bool
+	now        func() time.Time
+
+	mu         sync.Mutex
+	namespaces map[string][]*entry
+}
+
+// New caches the answers of llm, embedding the last user message of each
+// request with embedder.
+func New(llm gochain.LLM, embedder gochain.Embedder, opts ...Option) *LLM {
+	l := &LLM{
+		llm:        llm,
+		embedder:   embedder,
+		threshold:  defaultThreshold,
+		now:        time.Now,
+		namespaces: map[string][]*entry{},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *LLM) Name() string {
+	return l.llm.Name()
+}
+
+// Model reports the model of the wrapped LLM, if it has one.
+func (l *LLM) Model() string {
+	if m, ok := l.llm.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+// JSONMode forwards to the wrapped LLM.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	gochain.EnableJSONMode(l.llm, options)
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" || (!l.force && !cache.Deterministic(options)) {
+		return gochain.Generate(ctx, l.llm, messages, options...)
+	}
+
+	last := len(messages) - 1
+	contextKey, err := cache.Key(l.llm.Name(), l.Model(), messages[:last], options)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors, err := l.embedder.Embed(ctx, []string{messages[last].Text()})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) == 0 {
+		return gochain.Generate(ctx, l.llm, messages, options...)
+	}
+
+	namespace := namespaceFrom(ctx)
+	if g, ok := l.lookup(namespace, contextKey, vectors[0]); ok {
+		return g, nil
+	}
+
+	g, err := gochain.Generate(ctx, l.llm, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *g
+	stored.Metadata = copyMetadata(g.Metadata)
+	l.store(namespace, &entry{vector: vectors[0], context: contextKey, generation: stored})
+
+	return g, nil
+}
+
+func (l *LLM) lookup(namespace, contextKey string, vector []float64) (*gochain.Generation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.expire(namespace, now)
+
+	var best *entry
+	bestScore := l.threshold
+	for _, e := range l.namespaces[namespace] {
+		if e.context != contextKey {
+			continue
+		}
+
+		if score := gochain.CosineSimilarity(vector, e.vector); score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+
+	if best == nil {
+		return nil, false
+	}
+
+	best.usedAt = now
+	best.hits++
+
+	g := best.generation
+	g.Metadata = copyMetadata(best.generation.Metadata)
+	g.Metadata[cache.MetadataHit] = true
+	g.Metadata[MetadataSimilarity] = bestScore
+
+	return &g, true
+}
+
+func (l *LLM) store(namespace string, e *entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.createdAt = l.now()
+	e.usedAt = e.createdAt
+
+	entries := append(l.namespaces[namespace], e)
+	if l.maxEntries > 0 && len(entries) > l.maxEntries {
+		entries = l.evict(entries)
+	}
+
+	l.namespaces[namespace] = entries
+}
+
+// evict removes the single entry the eviction policy picks. The last entry,
+// just stored, is never picked: it has no hits yet and would always lose
+// under EvictLFU.
+func (l *LLM) evict(entries []*entry) []*entry {
+	victim := 0
+	for i, e := range entries[:len(entries)-1] {
+		v := entries[victim]
+		switch l.eviction {
+		case EvictLFU:
+			if e.hits < v.hits || (e.hits == v.hits && e.usedAt.Before(v.usedAt)) {
+				victim = i
+			}
+		case EvictFIFO:
+			if e.createdAt.Before(v.createdAt) {
+				victim = i
+			}
+		default:
+			if e.usedAt.Before(v.usedAt) {
+				victim = i
+			}
+		}
+	}
+
+	return append(entries[:victim], entries[victim+1:]...)
+}
+
+func (l *LLM) expire(namespace string, now time.Time) {
+	if l.ttl <= 0 {
+		return
+	}
+
+	entries := l.namespaces[namespace][:0]
+	for _, e := range l.namespaces[namespace] {
+		if now.Sub(e.createdAt) <= l.ttl {
+			entries = append(entries, e)
+		}
+	}
+
+	l.namespaces[namespace] = entries
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+
+	return c
+}
+
+// Clear drops every entry of a namespace.
+func (l *LLM) Clear(namespace string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.namespaces, namespace)
+}
+
+// Len returns the number of entries kept for a namespace.
+func (l *LLM) Len(namespace string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.namespaces[namespace])
+}
@@ -0,0 +1,214 @@
+package semantic_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/cache"
+	"github.com/ryanbekhen/gochain/llm/cache/semantic"
+	"testing"
+	"time"
+)
+
+var deterministic = map[string]interface{}{"temperature": 0}
+
+// vectors places each question on its own axis; paraphrases sit close to
+// the question they rephrase.
+var vectors = map[string][]float64{
+	"weather in paris?":     {1, 0, 0, 0},
+	"paris weather?":        {0.99, 0.1, 0, 0},
+	"capital of france?":    {0, 1, 0, 0},
+	"largest ocean?":        {0, 0, 1, 0},
+	"tallest mountain?":     {0, 0, 0, 1},
+	"roughly paris weather": {0.8, 0.6, 0, 0},
+}
+
+func newFake() *gochaintest.FakeLLM {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.Text("answer"))
+	llm.SetEmbedding(func(text string) []float64 {
+		return vectors[text]
+	})
+
+	return llm
+}
+
+func ask(t *testing.T, l *semantic.LLM, question string, options ...map[string]interface{}) *gochain.Generation {
+	t.Helper()
+
+	return askIn(t, context.Background(), l, []gochain.Message{{Role: "user", Content: question}}, options...)
+}
+
+func askIn(t *testing.T, ctx context.Context, l *semantic.LLM, messages []gochain.Message, options ...map[string]interface{}) *gochain.Generation {
+	t.Helper()
+
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestParaphraseHit(t *testing.T) {
+	llm := newFake()
+	l := semantic.New(llm, llm)
+
+	if g := ask(t, l, "weather in paris?", deterministic); g.Metadata[cache.MetadataHit] != nil {
+		t.Errorf("first answer marked as a hit: %v", g.Metadata)
+	}
+
+	g := ask(t, l, "paris weather?", deterministic)
+	if g.Content != "answer" || g.Metadata[cache.MetadataHit] != true || llm.CallCount() != 1 {
+		t.Fatalf("paraphrase = %+v after %d calls, want a hit", g, llm.CallCount())
+	}
+
+	if score, _ := g.Metadata[semantic.MetadataSimilarity].(float64); score < 0.95 || score > 1 {
+		t.Errorf("similarity = %v", g.Metadata[semantic.MetadataSimilarity])
+	}
+
+	// Below the threshold is a miss.
+	ask(t, l, "roughly paris weather", deterministic)
+	if llm.CallCount() != 2 {
+		t.Errorf("%d calls, want a miss below the threshold", llm.CallCount())
+	}
+
+	l = semantic.New(llm, llm, semantic.WithThreshold(0.7))
+	ask(t, l, "weather in paris?", deterministic)
+	if g := ask(t, l, "roughly paris weather", deterministic); g.Metadata[cache.MetadataHit] != true {
+		t.Error("miss above a lowered threshold")
+	}
+}
+
+func TestOnlyDeterministicRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options []map[string]interface{}
+		opts    []semantic.Option
+		want    int
+	}{
+		{"no temperature", nil, nil, 2},
+		{"sampled", []map[string]interface{}{{"temperature": 0.7}}, nil, 2},
+		{"zero temperature", []map[string]interface{}{deterministic}, nil, 1},
+		{"forced", nil, []semantic.Option{semantic.WithForce()}, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFake()
+			l := semantic.New(llm, llm, tt.opts...)
+
+			ask(t, l, "weather in paris?", tt.options...)
+			ask(t, l, "paris weather?", tt.options...)
+
+			if got := llm.CallCount(); got != tt.want {
+				t.Errorf("%d calls, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextAndNamespaces(t *testing.T) {
+	llm := newFake()
+	l := semantic.New(llm, llm)
+	ctx := context.Background()
+
+	question := gochain.Message{Role: "user", Content: "weather in paris?"}
+	askIn(t, ctx, l, []gochain.Message{{Role: "system", Content: "be brief"}, question}, deterministic)
+
+	// Another system prompt or another namespace is another cache.
+	askIn(t, ctx, l, []gochain.Message{{Role: "system", Content: "be verbose"}, question}, deterministic)
+	askIn(t, semantic.WithNamespace(ctx, "tenant"), l, []gochain.Message{{Role: "system", Content: "be brief"}, question}, deterministic)
+	if llm.CallCount() != 3 {
+		t.Fatalf("%d calls, want 3", llm.CallCount())
+	}
+
+	if l.Len("") != 2 || l.Len("tenant") != 1 {
+		t.Errorf("Len = %d and %d", l.Len(""), l.Len("tenant"))
+	}
+
+	l.Clear("tenant")
+	if l.Len("tenant") != 0 || l.Len("") != 2 {
+		t.Errorf("Len after Clear = %d and %d", l.Len(""), l.Len("tenant"))
+	}
+
+	// Requests not ending with a user message are not cached.
+	askIn(t, ctx, l, []gochain.Message{question, {Role: "assistant", Content: "sunny"}}, deterministic)
+	if l.Len("") != 2 {
+		t.Errorf("Len = %d, want the assistant turn skipped", l.Len(""))
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	llm := newFake()
+	l := semantic.New(llm, llm, semantic.WithTTL(time.Minute), semantic.WithClock(func() time.Time { return now }))
+
+	ask(t, l, "weather in paris?", deterministic)
+	now = now.Add(time.Minute)
+	ask(t, l, "paris weather?", deterministic)
+	now = now.Add(time.Second)
+	ask(t, l, "paris weather?", deterministic)
+
+	if llm.CallCount() != 2 {
+		t.Errorf("%d calls, want the entry expired after a minute", llm.CallCount())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		policy   semantic.Eviction
+		evicted  string
+		retained []string
+	}{
+		// weather is hit once before mountain is stored: capital becomes
+		// the least recently and least frequently used entry, weather
+		// stays the oldest.
+		{"LRU", semantic.EvictLRU, "capital of france?", []string{"weather in paris?", "largest ocean?", "tallest mountain?"}},
+		{"LFU", semantic.EvictLFU, "capital of france?", []string{"weather in paris?", "largest ocean?", "tallest mountain?"}},
+		{"FIFO", semantic.EvictFIFO, "weather in paris?", []string{"capital of france?", "largest ocean?", "tallest mountain?"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			llm := newFake()
+			l := semantic.New(llm, llm, semantic.WithMaxEntries(3), semantic.WithEviction(tt.policy),
+				semantic.WithClock(func() time.Time { return now }))
+
+			for _, q := range []string{"weather in paris?", "capital of france?", "largest ocean?", "paris weather?", "tallest mountain?"} {
+				now = now.Add(time.Second)
+				ask(t, l, q, deterministic)
+			}
+
+			if l.Len("") != 3 {
+				t.Fatalf("Len = %d, want 3", l.Len(""))
+			}
+
+			calls := llm.CallCount()
+			for _, q := range tt.retained {
+				if g := ask(t, l, q, deterministic); g.Metadata[cache.MetadataHit] != true {
+					t.Errorf("%q evicted", q)
+				}
+			}
+
+			if g := ask(t, l, tt.evicted, deterministic); g.Metadata[cache.MetadataHit] == true {
+				t.Errorf("%q kept", tt.evicted)
+			}
+
+			if got := llm.CallCount() - calls; got != 1 {
+				t.Errorf("%d calls, want only the evicted question sent", got)
+			}
+		})
+	}
+}
+
+func TestLFUKeepsNewEntry(t *testing.T) {
+	llm := newFake()
+	l := semantic.New(llm, llm, semantic.WithMaxEntries(2), semantic.WithEviction(semantic.EvictLFU))
+
+	for _, q := range []string{"weather in paris?", "capital of france?", "weather in paris?", "capital of france?", "largest ocean?"} {
+		ask(t, l, q, deterministic)
+	}
+
+	// Every older entry has a hit, the new one has none but is kept.
+	if g := ask(t, l, "largest ocean?", deterministic); g.Metadata[cache.MetadataHit] != true {
+		t.Error("the entry just stored was evicted")
+	}
+}
@@ -6,17 +6,26 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/internal/httputil"
+	"github.com/ryanbekhen/gochain/internal/logging"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
-	"strings"
+	"time"
 )
 
+const defaultEmbeddingModel = "@cf/baai/bge-base-en-v1.5"
+
 type CFWorkerAI struct {
-	base      *url.URL
-	model     string
-	accountId string
-	http      *http.Client
+	base           *url.URL
+	model          string
+	embeddingModel string
+	accountId      string
+	http           *http.Client
+	logger         *slog.Logger
+	logConfig      gochain.LogConfig
 }
 
 type tokenTransport struct {
@@ -97,20 +106,120 @@ func (c *CFWorkerAI) SetModel(model string) {
 	c.model = model
 }
 
-func (c *CFWorkerAI) Embedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
-	reqJSON, _ := json.Marshal(map[string]interface{}{
-		"text": req.Prompt,
-	})
+func (c *CFWorkerAI) SetEmbeddingModel(model string) {
+	c.embeddingModel = model
+}
+
+func (c *CFWorkerAI) EmbeddingModel() string {
+	if c.embeddingModel == "" {
+		return defaultEmbeddingModel
+	}
+
+	return c.embeddingModel
+}
+
+// SetLogger logs every request to Workers AI and its outcome to logger.
+func (c *CFWorkerAI) SetLogger(logger *slog.Logger, opts ...gochain.LogOption) {
+	c.logger = logger
+	c.logConfig = gochain.NewLogConfig(opts...)
+}
+
+func (c *CFWorkerAI) log() *slog.Logger {
+	if c.logger == nil {
+		return logging.Discard
+	}
+
+	return c.logger
+}
 
-	baseURL := c.base.String() + "/" + c.accountId + "/ai/run/" + req.Model
-	resp, err := c.http.Post(baseURL, "application/json", bytes.NewBuffer(reqJSON))
+func (c *CFWorkerAI) logFailure(ctx context.Context, model string, start time.Time, status int, err error) {
+	c.log().ErrorContext(ctx, "cf-worker-ai: request failed",
+		slog.String(gochain.LogKeyModel, model),
+		slog.Int(gochain.LogKeyStatus, status),
+		slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+		slog.Any(gochain.LogKeyError, err))
+}
+
+// run posts payload to a Workers AI model and decodes the response into out.
+// Rejected requests are returned as *gochain.ProviderError.
+func (c *CFWorkerAI) run(ctx context.Context, model string, payload, out interface{}) error {
+	reqJSON, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	baseURL := c.base.String() + "/" + c.accountId + "/ai/run/" + model
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL, bytes.NewReader(reqJSON))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	c.log().DebugContext(ctx, "cf-worker-ai: request",
+		slog.String(gochain.LogKeyModel, model),
+		slog.String(gochain.LogKeyContent, c.logConfig.Content(string(reqJSON))))
+
+	start := time.Now()
+	resp, err := c.http.Do(request)
+	if err != nil {
+		c.logFailure(ctx, model, start, 0, err)
+		return err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := &gochain.ProviderError{
+			Provider:   c.Name(),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: httputil.RetryAfter(resp.Header, time.Now()),
+		}
+		c.logFailure(ctx, model, start, resp.StatusCode, err)
+		return err
+	}
+
+	var envelope responseEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return err
+	}
+
+	if !envelope.Success {
+		err := &gochain.ProviderError{
+			Provider:   c.Name(),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Cause:      errors.New(envelope.message()),
+		}
+		c.logFailure(ctx, model, start, resp.StatusCode, err)
+		return err
+	}
+
+	c.log().DebugContext(ctx, "cf-worker-ai: response",
+		slog.String(gochain.LogKeyModel, model),
+		slog.Int(gochain.LogKeyStatus, resp.StatusCode),
+		slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+		slog.String(gochain.LogKeyContent, c.logConfig.Content(string(body))))
+
+	return json.Unmarshal(body, out)
+}
+
+func (c *CFWorkerAI) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	var response EmbedResponse
+	if err := c.run(ctx, c.EmbeddingModel(), map[string]interface{}{"text": texts}, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Result.Data, nil
+}
+
+func (c *CFWorkerAI) Embedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
 	var response EmbeddingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := c.run(ctx, req.Model, map[string]interface{}{"text": req.Prompt}, &response); err != nil {
 		return nil, err
 	}
 
@@ -118,29 +227,33 @@ func (c *CFWorkerAI) Embedding(ctx context.Context, req *EmbeddingRequest) (*Emb
 }
 
 func (c *CFWorkerAI) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
-	reqJSON, _ := json.Marshal(map[string]interface{}{
-		"messages": messages,
-	})
-
-	baseURL := c.base.String() + "/" + c.accountId + "/ai/run/" + c.model
-	resp, err := c.http.Post(baseURL, "application/json", bytes.NewBuffer(reqJSON))
+	generation, err := c.ChatGeneration(ctx, messages, options...)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("error with status code: " + resp.Status)
-	}
+	return generation.Content, nil
+}
 
+func (c *CFWorkerAI) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
 	var response ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
+	if err := c.run(ctx, c.model, map[string]interface{}{"messages": toChatMessages(messages)}, &response); err != nil {
+		return nil, err
+	}
+
+	generation := &gochain.Generation{
+		Content:  response.Result.Response,
+		Provider: c.Name(),
+		Model:    c.model,
 	}
 
-	if !response.Success {
-		return "", errors.New("chat failed: " + strings.Join(response.Error, ", "))
+	if u := response.Result.Usage; u != nil {
+		generation.Usage = gochain.Usage{
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			TotalTokens:      u.TotalTokens,
+		}
 	}
 
-	return response.Result.Response, nil
+	return generation, nil
 }
@@ -0,0 +1,72 @@
+package cfworkerai_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	cfworkerai "github.com/ryanbekhen/gochain/llm/cf-worker-ai"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to the test server.
+type redirect struct {
+	target *url.URL
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newLLM(t *testing.T, handler http.HandlerFunc) *cfworkerai.CFWorkerAI {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	llm, err := cfworkerai.New("account", "token", "", &http.Client{Transport: redirect{target: target}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return llm
+}
+
+func TestProviderError(t *testing.T) {
+	llm := newLLM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("rate limited"))
+	})
+
+	_, err := llm.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}})
+
+	var pe *gochain.ProviderError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, want a ProviderError", err)
+	}
+
+	if pe.Provider != "cf-worker-ai" || pe.StatusCode != http.StatusTooManyRequests || pe.RetryAfter != 5*time.Second || pe.Body != "rate limited" {
+		t.Errorf("ProviderError = %+v", pe)
+	}
+}
+
+func TestUnsuccessfulResponse(t *testing.T) {
+	llm := newLLM(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":5007,"message":"No such model"}]}`))
+	})
+
+	_, err := llm.Embed(context.Background(), []string{"hi"})
+
+	var pe *gochain.ProviderError
+	if !errors.As(err, &pe) || pe.StatusCode != http.StatusOK || pe.Cause == nil || pe.Cause.Error() != "No such model" {
+		t.Errorf("err = %v, want a ProviderError with the response message", err)
+	}
+}
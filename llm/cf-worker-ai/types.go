@@ -1,5 +1,55 @@
 package cfworkerai
 
+import (
+	"github.com/ryanbekhen/gochain"
+	"strings"
+)
+
+type chatMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+// toChatMessages keeps plain string content for text-only messages and
+// switches to content parts when a message carries images.
+func toChatMessages(messages []gochain.Message) []chatMessage {
+	result := make([]chatMessage, 0, len(messages))
+	for _, m := range messages {
+		if len(m.Images()) == 0 {
+			result = append(result, chatMessage{Role: m.Role, Content: m.Text()})
+			continue
+		}
+
+		var parts []chatContentPart
+		if m.Content != "" {
+			parts = append(parts, chatContentPart{Type: "text", Text: m.Content})
+		}
+
+		for _, p := range m.Parts {
+			switch p.Type {
+			case gochain.ContentPartText:
+				parts = append(parts, chatContentPart{Type: "text", Text: p.Text})
+			case gochain.ContentPartImage:
+				parts = append(parts, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: p.DataURL()}})
+			}
+		}
+
+		result = append(result, chatMessage{Role: m.Role, Content: parts})
+	}
+
+	return result
+}
+
 type ChatResponse struct {
 	Result  ChatResponseResult `json:"result"`
 	Success bool               `json:"success"`
@@ -8,7 +58,14 @@ type ChatResponse struct {
 }
 
 type ChatResponseResult struct {
-	Response string `json:"response"`
+	Response string     `json:"response"`
+	Usage    *ChatUsage `json:"usage,omitempty"`
+}
+
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type EmbeddingRequest struct {
@@ -20,3 +77,37 @@ type EmbeddingResponse struct {
 	Result  any  `json:"result"`
 	Success bool `json:"success"`
 }
+
+type EmbedResponse struct {
+	Result  EmbedResponseResult `json:"result"`
+	Success bool                `json:"success"`
+	Error   []string            `json:"error"`
+}
+
+type EmbedResponseResult struct {
+	Shape []int       `json:"shape"`
+	Data  [][]float64 `json:"data"`
+}
+
+// responseEnvelope holds the fields shared by every Workers AI response.
+type responseEnvelope struct {
+	Success bool     `json:"success"`
+	Error   []string `json:"error"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e responseEnvelope) message() string {
+	msgs := append([]string{}, e.Error...)
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Message)
+	}
+
+	if len(msgs) == 0 {
+		return "request failed"
+	}
+
+	return strings.Join(msgs, ", ")
+}
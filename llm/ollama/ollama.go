@@ -5,21 +5,36 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/internal/httputil"
+	"github.com/ryanbekhen/gochain/internal/logging"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
 type Ollama struct {
-	base  *url.URL
-	model string
-	http  *http.Client
+	base           *url.URL
+	model          string
+	embeddingModel string
+	http           *http.Client
+	imageHTTP      *http.Client
+	imageSchemes   []string
+	logger         *slog.Logger
+	logConfig      gochain.LogConfig
 }
 
+// defaultImageClient downloads image URLs. It is kept apart from the
+// client talking to the Ollama server, which may carry credentials or a
+// transport meant only for it.
+var defaultImageClient = &http.Client{Timeout: 30 * time.Second}
+
 func NewFromEnvironment() (*Ollama, error) {
 	ollamaEndpoint := "http://localhost:11434"
 
@@ -51,6 +66,11 @@ func (o *Ollama) Name() string {
 	return "ollama"
 }
 
+// JSONMode sets the format option, which constrains the output to JSON.
+func (o *Ollama) JSONMode(options map[string]interface{}) {
+	options["format"] = "json"
+}
+
 func (o *Ollama) SetModel(model string) {
 	o.model = model
 }
@@ -59,12 +79,71 @@ func (o *Ollama) Model() string {
 	return o.model
 }
 
+// SetEmbeddingModel sets the model used by Embed, which otherwise falls
+// back to the chat model.
+func (o *Ollama) SetEmbeddingModel(model string) {
+	o.embeddingModel = model
+}
+
+func (o *Ollama) EmbeddingModel() string {
+	if o.embeddingModel == "" {
+		return o.model
+	}
+
+	return o.embeddingModel
+}
+
+// SetImageClient sets the client used to download image URLs and the URL
+// schemes it may fetch, https only by default. The client talking to the
+// Ollama server is never used for images.
+func (o *Ollama) SetImageClient(client *http.Client, schemes ...string) {
+	o.imageHTTP = client
+	o.imageSchemes = schemes
+}
+
+func (o *Ollama) imageClient() *http.Client {
+	if o.imageHTTP == nil {
+		return defaultImageClient
+	}
+
+	return o.imageHTTP
+}
+
+func (o *Ollama) imageSchemeAllowed(u *url.URL) bool {
+	schemes := o.imageSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"https"}
+	}
+
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetLogger logs every HTTP exchange with the Ollama server to logger.
+func (o *Ollama) SetLogger(logger *slog.Logger, opts ...gochain.LogOption) {
+	o.logger = logger
+	o.logConfig = gochain.NewLogConfig(opts...)
+}
+
+func (o *Ollama) log() *slog.Logger {
+	if o.logger == nil {
+		return logging.Discard
+	}
+
+	return o.logger
+}
+
 func checkError(resp *http.Response, body []byte) error {
 	if resp.StatusCode < http.StatusBadRequest {
 		return nil
 	}
 
-	apiError := StatusError{StatusCode: resp.StatusCode}
+	apiError := StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 
 	err := json.Unmarshal(body, &apiError)
 	if err != nil {
@@ -72,7 +151,13 @@ func checkError(resp *http.Response, body []byte) error {
 		apiError.ErrorMessage = string(body)
 	}
 
-	return apiError
+	return &gochain.ProviderError{
+		Provider:   "ollama",
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: httputil.RetryAfter(resp.Header, time.Now()),
+		Cause:      apiError,
+	}
 }
 
 func (o *Ollama) do(ctx context.Context, method, path string, reqData, respData any) error {
@@ -105,8 +190,17 @@ func (o *Ollama) do(ctx context.Context, method, path string, reqData, respData
 	request.Header.Set("Accept", "application/json")
 	request.Header.Set("User-Agent", fmt.Sprintf("gochain"))
 
+	o.log().DebugContext(ctx, "ollama: request",
+		slog.String(gochain.LogKeyURL, requestURL.String()),
+		slog.String(gochain.LogKeyContent, o.logConfig.Content(string(data))))
+
+	start := time.Now()
 	respObj, err := o.http.Do(request)
 	if err != nil {
+		o.log().ErrorContext(ctx, "ollama: request failed",
+			slog.String(gochain.LogKeyURL, requestURL.String()),
+			slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+			slog.Any(gochain.LogKeyError, err))
 		return err
 	}
 	defer respObj.Body.Close()
@@ -117,9 +211,20 @@ func (o *Ollama) do(ctx context.Context, method, path string, reqData, respData
 	}
 
 	if err := checkError(respObj, respBody); err != nil {
+		o.log().ErrorContext(ctx, "ollama: request failed",
+			slog.String(gochain.LogKeyURL, requestURL.String()),
+			slog.Int(gochain.LogKeyStatus, respObj.StatusCode),
+			slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+			slog.Any(gochain.LogKeyError, err))
 		return err
 	}
 
+	o.log().DebugContext(ctx, "ollama: response",
+		slog.String(gochain.LogKeyURL, requestURL.String()),
+		slog.Int(gochain.LogKeyStatus, respObj.StatusCode),
+		slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+		slog.String(gochain.LogKeyContent, o.logConfig.Content(string(respBody))))
+
 	if len(respBody) > 0 && respData != nil {
 		if err := json.Unmarshal(respBody, respData); err != nil {
 			return err
@@ -138,43 +243,142 @@ func (o *Ollama) Embedding(ctx context.Context, req *EmbeddingRequest) (*Embeddi
 	return &resp, nil
 }
 
-func (o *Ollama) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
-	var opts map[string]interface{}
-	if len(options) > 0 {
-		opts = options[0]
-	}
+func (o *Ollama) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for _, text := range texts {
+		resp, err := o.Embedding(ctx, &EmbeddingRequest{Model: o.EmbeddingModel(), Prompt: text})
+		if err != nil {
+			return nil, err
+		}
 
-	var formatResponse string
-	if f, ok := opts["format"].(string); ok {
-		formatResponse = f
-		delete(opts, "format")
+		vectors = append(vectors, resp.Embedding)
 	}
 
-	var keepAlive time.Duration
-	if k, ok := opts["keep_alive"].(time.Duration); ok {
-		keepAlive = k
-		delete(opts, "keep_alive")
+	return vectors, nil
+}
+
+func (o *Ollama) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	generation, err := o.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
 	}
 
+	return generation.Content, nil
+}
+
+func (o *Ollama) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	return o.ChatStream(ctx, messages, nil, options...)
+}
+
+// ChatStream passes every chunk of the completion to fn as it arrives and
+// returns the whole generation once done. fn may be nil.
+func (o *Ollama) ChatStream(ctx context.Context, messages []gochain.Message, fn func(chunk string) error, options ...map[string]interface{}) (*gochain.Generation, error) {
 	stream := true
 	req := &ChatRequest{
-		Model:     o.model,
-		Messages:  messages,
-		Format:    formatResponse,
-		KeepAlive: keepAlive,
-		Stream:    &stream,
-		Options:   opts,
+		Model:    o.model,
+		Messages: messages,
+		Stream:   &stream,
 	}
+	req.setOptions(options)
 
-	var chatResponse string
+	generation := &gochain.Generation{Provider: o.Name(), Model: o.model}
+	var chatResponse strings.Builder
 	if err := o.SendChat(ctx, req, func(resp ChatResponse) error {
-		chatResponse += resp.Message.Content
+		chatResponse.WriteString(resp.Message.Content)
+
+		if fn != nil && resp.Message.Content != "" {
+			if err := fn(resp.Message.Content); err != nil {
+				return err
+			}
+		}
+
+		if resp.Done {
+			generation.Model = resp.Model
+			generation.FinishReason = resp.DoneReason
+			generation.Usage = gochain.Usage{
+				PromptTokens:     resp.PromptEvalCount,
+				CompletionTokens: resp.EvalCount,
+				TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+			}
+			generation.Metadata = map[string]interface{}{
+				"total_duration": resp.TotalDuration,
+				"load_duration":  resp.LoadDuration,
+				"eval_duration":  resp.EvalDuration,
+			}
+		}
+
 		return nil
 	}); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	generation.Content = chatResponse.String()
+
+	return generation, nil
+}
+
+func (o *Ollama) ChatWithTools(ctx context.Context, messages []gochain.Message, tools []*gochain.Function, options ...map[string]interface{}) (*gochain.ToolCallResponse, error) {
+	messages, err := o.loadImages(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := false
+	req := &ChatRequest{
+		Model:    o.model,
+		Messages: messages,
+		Stream:   &stream,
+	}
+	req.setOptions(options)
+
+	for _, t := range tools {
+		req.Tools = append(req.Tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	var resp toolChatResponse
+	if err := o.do(ctx, http.MethodPost, "/api/chat", req, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &gochain.ToolCallResponse{Content: resp.Message.Content}
+	for _, c := range resp.Message.ToolCalls {
+		result.Calls = append(result.Calls, gochain.FunctionResponse{
+			Tool:      c.Function.Name,
+			ToolInput: c.Function.Arguments,
+		})
 	}
 
-	return chatResponse, nil
+	return result, nil
+}
+
+// setOptions sets the model options of the request from a copy of the
+// caller's options, moving the request specific keys to their fields.
+func (r *ChatRequest) setOptions(options []map[string]interface{}) {
+	if len(options) == 0 || options[0] == nil {
+		return
+	}
+
+	r.Options = make(map[string]interface{}, len(options[0]))
+	for k, v := range options[0] {
+		r.Options[k] = v
+	}
+
+	if f, ok := r.Options["format"].(string); ok {
+		r.Format = f
+		delete(r.Options, "format")
+	}
+
+	if k, ok := r.Options["keep_alive"].(time.Duration); ok {
+		r.KeepAlive = k
+		delete(r.Options, "keep_alive")
+	}
 }
 
 // maxBufferSize is the maximum buffer size for the scanner (512 KB)
@@ -192,6 +396,30 @@ func (o *Ollama) stream(ctx context.Context, method, path string, data any, fn f
 	}
 
 	requestURL := o.base.JoinPath(path)
+	if buf != nil {
+		o.log().DebugContext(ctx, "ollama: request",
+			slog.String(gochain.LogKeyURL, requestURL.String()),
+			slog.String(gochain.LogKeyContent, o.logConfig.Content(buf.String())))
+	}
+
+	start := time.Now()
+	err := o.readStream(ctx, method, requestURL, buf, fn)
+	if err != nil {
+		o.log().ErrorContext(ctx, "ollama: stream failed",
+			slog.String(gochain.LogKeyURL, requestURL.String()),
+			slog.Duration(gochain.LogKeyDuration, time.Since(start)),
+			slog.Any(gochain.LogKeyError, err))
+		return err
+	}
+
+	o.log().DebugContext(ctx, "ollama: stream done",
+		slog.String(gochain.LogKeyURL, requestURL.String()),
+		slog.Duration(gochain.LogKeyDuration, time.Since(start)))
+
+	return nil
+}
+
+func (o *Ollama) readStream(ctx context.Context, method string, requestURL *url.URL, buf *bytes.Buffer, fn func([]byte) error) error {
 	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), buf)
 	if err != nil {
 		return err
@@ -207,12 +435,23 @@ func (o *Ollama) stream(ctx context.Context, method, path string, data any, fn f
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode >= http.StatusBadRequest {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+
+		return checkError(response, body)
+	}
+
 	scanner := bufio.NewScanner(response.Body)
 	scanBuf := make([]byte, 0, maxBufferSize)
 	scanner.Buffer(scanBuf, maxBufferSize)
+	done := false
 	for scanner.Scan() {
 		var errorResponse struct {
 			Error string `json:"error,omitempty"`
+			Done  bool   `json:"done"`
 		}
 
 		bts := scanner.Bytes()
@@ -221,27 +460,113 @@ func (o *Ollama) stream(ctx context.Context, method, path string, data any, fn f
 		}
 
 		if errorResponse.Error != "" {
-			return fmt.Errorf(errorResponse.Error)
-		}
-
-		if response.StatusCode >= http.StatusBadRequest {
-			return StatusError{
-				StatusCode:   response.StatusCode,
-				Status:       response.Status,
-				ErrorMessage: errorResponse.Error,
+			return &gochain.ProviderError{
+				Provider:   "ollama",
+				StatusCode: response.StatusCode,
+				Body:       string(bts),
+				Cause:      errors.New(errorResponse.Error),
 			}
 		}
 
 		if err := fn(bts); err != nil {
 			return err
 		}
+
+		done = errorResponse.Done
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// A stream cut before the final message would otherwise look like a
+	// complete, shorter answer.
+	if !done {
+		return io.ErrUnexpectedEOF
 	}
 
 	return nil
 }
 
+// loadImages downloads image parts that only carry a URL, since Ollama
+// accepts inline base64 images only. Downloads go through the image client
+// and are limited to the allowed schemes, see SetImageClient.
+func (o *Ollama) loadImages(ctx context.Context, messages []gochain.Message) ([]gochain.Message, error) {
+	loaded := make([]gochain.Message, len(messages))
+	for i, m := range messages {
+		loaded[i] = m
+		if len(m.Parts) == 0 {
+			continue
+		}
+
+		parts := make([]gochain.ContentPart, len(m.Parts))
+		copy(parts, m.Parts)
+		for j, p := range parts {
+			if !p.IsImage() || len(p.Data) > 0 || p.URL == "" {
+				continue
+			}
+
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			if !o.imageSchemeAllowed(request.URL) {
+				return nil, fmt.Errorf("ollama: fetch image %s: scheme not allowed: %w", p.URL, gochain.ErrUnsupportedImage)
+			}
+
+			resp, err := o.imageClient().Do(request)
+			if err != nil {
+				return nil, err
+			}
+
+			// Redirects may lead to another scheme.
+			if !o.imageSchemeAllowed(resp.Request.URL) {
+				resp.Body.Close()
+				return nil, fmt.Errorf("ollama: fetch image %s: redirected to a scheme not allowed: %w", p.URL, gochain.ErrUnsupportedImage)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return nil, fmt.Errorf("ollama: fetch image %s: %s", p.URL, resp.Status)
+			}
+
+			if resp.ContentLength > gochain.MaxImageSize {
+				resp.Body.Close()
+				return nil, fmt.Errorf("ollama: fetch image %s: %w", p.URL, gochain.ErrImageTooLarge)
+			}
+
+			mimeType := p.MIMEType
+			if mimeType == "" {
+				mimeType = resp.Header.Get("Content-Type")
+			}
+
+			img, err := gochain.ImageFromReader(resp.Body, mimeType)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("ollama: fetch image %s: %w", p.URL, err)
+			}
+
+			img.URL = p.URL
+			parts[j] = img
+		}
+
+		loaded[i].Parts = parts
+	}
+
+	return loaded, nil
+}
+
 func (o *Ollama) SendChat(ctx context.Context, req *ChatRequest, fn func(ChatResponse) error) error {
-	return o.stream(ctx, http.MethodPost, "/api/chat", req, func(bts []byte) error {
+	messages, err := o.loadImages(ctx, req.Messages)
+	if err != nil {
+		return err
+	}
+
+	r := *req
+	r.Messages = messages
+
+	return o.stream(ctx, http.MethodPost, "/api/chat", &r, func(bts []byte) error {
 		var resp ChatResponse
 		if err := json.Unmarshal(bts, &resp); err != nil {
 			return err
@@ -0,0 +1,250 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/llm/ollama"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// authTransport adds the credentials meant for the Ollama server only.
+type authTransport struct{}
+
+func (authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer secret")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newServer returns an Ollama server that records the images it receives.
+func newServer(t *testing.T, images *[]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Images []string `json:"images"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		for _, m := range req.Messages {
+			*images = append(*images, m.Images...)
+		}
+
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"a chart"},"done":true}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func imageMessage(url string) []gochain.Message {
+	return []gochain.Message{{Role: "user", Parts: []gochain.ContentPart{
+		gochain.TextPart("What is in this image?"),
+		gochain.ImageURLPart(url, ""),
+	}}}
+}
+
+func TestImageURLUsesImageClient(t *testing.T) {
+	var authorization string
+	images := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer images.Close()
+
+	var received []string
+	llm, err := ollama.New(newServer(t, &received).URL, &http.Client{Transport: authTransport{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.SetImageClient(images.Client())
+
+	if _, err := llm.Chat(context.Background(), imageMessage(images.URL+"/chart.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "" {
+		t.Errorf("image request carried the Ollama credentials %q", authorization)
+	}
+
+	if want := (gochain.ContentPart{Data: png}).Base64(); len(received) != 1 || received[0] != want {
+		t.Errorf("images sent = %q, want %q", received, want)
+	}
+}
+
+func TestImageURLSchemeNotAllowed(t *testing.T) {
+	fetched := false
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		_, _ = w.Write(png)
+	}))
+	defer images.Close()
+
+	var received []string
+	llm, err := ollama.New(newServer(t, &received).URL, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{images.URL + "/chart.png", "file:///etc/passwd", "ftp://example.com/chart.png"} {
+		if _, err := llm.Chat(context.Background(), imageMessage(url)); !errors.Is(err, gochain.ErrUnsupportedImage) {
+			t.Errorf("Chat(%s) err = %v, want ErrUnsupportedImage", url, err)
+		}
+	}
+
+	if fetched {
+		t.Error("an http image was fetched while only https is allowed")
+	}
+
+	// Allowing the scheme explicitly lets the download through.
+	llm.SetImageClient(images.Client(), "http")
+	if _, err := llm.Chat(context.Background(), imageMessage(images.URL+"/chart.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fetched || len(received) != 1 {
+		t.Errorf("fetched = %v, images sent = %d", fetched, len(received))
+	}
+}
+
+func TestImageURLRedirectToOtherScheme(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(png)
+	}))
+	defer plain.Close()
+
+	images := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/chart.png", http.StatusFound)
+	}))
+	defer images.Close()
+
+	var received []string
+	llm, err := ollama.New(newServer(t, &received).URL, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.SetImageClient(images.Client())
+
+	if _, err := llm.Chat(context.Background(), imageMessage(images.URL)); !errors.Is(err, gochain.ErrUnsupportedImage) {
+		t.Errorf("err = %v, want ErrUnsupportedImage", err)
+	}
+}
+
+func TestImageURLTooLarge(t *testing.T) {
+	images := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(gochain.MaxImageSize+1))
+		_, _ = w.Write(png)
+	}))
+	defer images.Close()
+
+	var received []string
+	llm, err := ollama.New(newServer(t, &received).URL, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.SetImageClient(images.Client())
+
+	if _, err := llm.Chat(context.Background(), imageMessage(images.URL)); !errors.Is(err, gochain.ErrImageTooLarge) {
+		t.Errorf("err = %v, want ErrImageTooLarge", err)
+	}
+
+	if len(received) != 0 {
+		t.Error("the request reached Ollama")
+	}
+}
+
+func TestChatWithToolsOptions(t *testing.T) {
+	var req map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true}`))
+	}))
+	defer server.Close()
+
+	llm, err := ollama.New(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := map[string]interface{}{"temperature": 0.0, "format": "json"}
+	resp, err := llm.ChatWithTools(context.Background(), []gochain.Message{{Role: "user", Content: "weather in Paris?"}}, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Calls) != 1 || resp.Calls[0].Tool != "get_weather" || resp.Calls[0].ToolInput["city"] != "Paris" {
+		t.Errorf("calls = %+v", resp.Calls)
+	}
+
+	if req["format"] != "json" {
+		t.Errorf("format = %v, want json", req["format"])
+	}
+
+	if opts, _ := req["options"].(map[string]interface{}); len(opts) != 1 || opts["temperature"] != 0.0 {
+		t.Errorf("options = %v, want the temperature only", req["options"])
+	}
+
+	if len(options) != 2 {
+		t.Errorf("caller's options changed to %v", options)
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"server busy"}`))
+	}))
+	defer server.Close()
+
+	llm, err := ollama.New(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = llm.ChatWithTools(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}}, nil)
+
+	var pe *gochain.ProviderError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, want a ProviderError", err)
+	}
+
+	if pe.Provider != "ollama" || pe.StatusCode != http.StatusTooManyRequests || pe.RetryAfter != 2*time.Second || pe.Body != `{"error":"server busy"}` {
+		t.Errorf("ProviderError = %+v", pe)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"par"},"done":false}` + "\n" + `{"error":"out of memory"}` + "\n"))
+	}))
+	defer server.Close()
+
+	llm, err := ollama.New(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = llm.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}})
+
+	var pe *gochain.ProviderError
+	if !errors.As(err, &pe) || pe.Cause == nil || pe.Cause.Error() != "out of memory" {
+		t.Errorf("err = %v, want a ProviderError for the error line", err)
+	}
+}
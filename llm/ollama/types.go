@@ -1,6 +1,8 @@
 package ollama
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ryanbekhen/gochain"
 	"time"
@@ -13,6 +15,67 @@ type ChatRequest struct {
 	Format    string                 `json:"format"`
 	KeepAlive time.Duration          `json:"keep_alive,omitempty"`
 	Options   map[string]interface{} `json:"options"`
+	Tools     []Tool                 `json:"tools,omitempty"`
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  interface{} `json:"parameters"`
+}
+
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type toolChatResponse struct {
+	Message struct {
+		Content   string     `json:"content"`
+		ToolCalls []ToolCall `json:"tool_calls"`
+	} `json:"message"`
+}
+
+type chatMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// MarshalJSON flattens multimodal messages into Ollama's content and
+// base64 images fields.
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	messages := make([]chatMessage, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		msg := chatMessage{Role: m.Role, Content: m.Text()}
+		for _, img := range m.Images() {
+			if len(img.Data) == 0 {
+				return nil, errors.New("ollama: image must be loaded before sending, got url " + img.URL)
+			}
+
+			msg.Images = append(msg.Images, img.Base64())
+		}
+
+		messages = append(messages, msg)
+	}
+
+	type alias ChatRequest
+	return json.Marshal(struct {
+		alias
+		Messages []chatMessage `json:"messages"`
+	}{
+		alias:    alias(r),
+		Messages: messages,
+	})
 }
 
 type ChatResponse struct {
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket refilled continuously at perMinute tokens per
+// minute. Reservations may take it below zero; later callers then wait
+// for the deficit to refill.
+type bucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	return &bucket{capacity: float64(perMinute), tokens: float64(perMinute)}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens += b.capacity * now.Sub(b.last).Minutes()
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+
+	b.last = now
+}
+
+// reserve takes n tokens and returns how long the caller must wait before
+// using them. Requests larger than the bucket are capped to its size.
+func (b *bucket) reserve(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+
+	want := float64(n)
+	if want > b.capacity {
+		want = b.capacity
+	}
+
+	b.tokens -= want
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.capacity * float64(time.Minute))
+}
+
+// adjust returns n tokens to the bucket, or takes them when n is negative.
+func (b *bucket) adjust(n int, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+
+	b.tokens += float64(n)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
@@ -0,0 +1,243 @@
+// Package ratelimit limits the requests, tokens and concurrent calls sent
+// to an LLM provider.
+package ratelimit
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/tokenizer"
+	"time"
+)
+
+// Clock is the time source used to refill buckets and wait for them.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type Option func(*Limiter)
+
+func WithRequestsPerMinute(n int) Option {
+	return func(l *Limiter) {
+		l.requests = newBucket(n)
+	}
+}
+
+// WithTokensPerMinute limits prompt and completion tokens. Requests are
+// charged an estimate up front, the prompt tokens plus the completion
+// tokens they allow, corrected by the usage the provider reports.
+func WithTokensPerMinute(n int) Option {
+	return func(l *Limiter) {
+		l.tokens = newBucket(n)
+	}
+}
+
+func WithMaxInFlight(n int) Option {
+	return func(l *Limiter) {
+		l.inFlight = make(chan struct{}, n)
+	}
+}
+
+// WithCompletionTokens sets the completion tokens reserved for requests
+// that do not set max_tokens or num_predict. It defaults to 256.
+func WithCompletionTokens(n int) Option {
+	return func(l *Limiter) {
+		l.completionTokens = n
+	}
+}
+
+// WithTokenizer sets the tokenizer used to estimate request tokens.
+func WithTokenizer(t gochain.Tokenizer) Option {
+	return func(l *Limiter) {
+		l.tokenizer = t
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(l *Limiter) {
+		l.clock = clock
+	}
+}
+
+// Limiter holds the budget of one provider account. A single Limiter can
+// be shared by every LLM and Chain calling that provider.
+type Limiter struct {
+	requests         *bucket
+	tokens           *bucket
+	inFlight         chan struct{}
+	tokenizer        gochain.Tokenizer
+	completionTokens int
+	clock            Clock
+}
+
+const defaultCompletionTokens = 256
+
+func NewLimiter(opts ...Option) *Limiter {
+	l := &Limiter{tokenizer: tokenizer.NewHeuristic(), completionTokens: defaultCompletionTokens, clock: realClock{}}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Reservation is a granted request. Done must be called once the request
+// finishes.
+type Reservation struct {
+	limiter  *Limiter
+	tokens   int
+	released bool
+}
+
+// Wait blocks until a request of the given estimated token count may be
+// sent, or ctx is done. The rate budget is waited for before an in-flight
+// slot, so requests waiting on the rate do not keep others from running.
+func (l *Limiter) Wait(ctx context.Context, tokens int) (*Reservation, error) {
+	r := &Reservation{limiter: l, tokens: tokens}
+
+	now := l.clock.Now()
+	var wait time.Duration
+	if l.requests != nil {
+		wait = max(wait, l.requests.reserve(1, now))
+	}
+	if l.tokens != nil {
+		wait = max(wait, l.tokens.reserve(tokens, now))
+		// reserve caps requests to the bucket size, track what it took.
+		r.tokens = min(tokens, int(l.tokens.capacity))
+	}
+
+	if wait > 0 {
+		select {
+		case <-l.clock.After(wait):
+		case <-ctx.Done():
+			r.refund()
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			r.refund()
+			return nil, ctx.Err()
+		}
+	}
+
+	return r, nil
+}
+
+// refund gives back the budget a reservation took when it is never used.
+func (r *Reservation) refund() {
+	now := r.limiter.clock.Now()
+	if r.limiter.requests != nil {
+		r.limiter.requests.adjust(1, now)
+	}
+	if r.limiter.tokens != nil {
+		r.limiter.tokens.adjust(r.tokens, now)
+	}
+
+	r.released = true
+}
+
+// Done releases the in-flight slot and charges the difference between the
+// estimated and the actual token count. Pass zero when it is not known.
+func (r *Reservation) Done(actualTokens int) {
+	if r.released {
+		return
+	}
+	r.released = true
+
+	if actualTokens > 0 && r.limiter.tokens != nil {
+		r.limiter.tokens.adjust(r.tokens-actualTokens, r.limiter.clock.Now())
+	}
+
+	if r.limiter.inFlight != nil {
+		<-r.limiter.inFlight
+	}
+}
+
+// LLM waits on a Limiter before each request to the wrapped LLM.
+type LLM struct {
+	llm     gochain.LLM
+	limiter *Limiter
+}
+
+func New(llm gochain.LLM, limiter *Limiter) *LLM {
+	return &LLM{llm: llm, limiter: limiter}
+}
+
+func (l *LLM) Name() string {
+	return l.llm.Name()
+}
+
+// Model reports the model of the wrapped LLM, if it has one.
+func (l *LLM) Model() string {
+	if m, ok := l.llm.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+// JSONMode forwards to the wrapped LLM.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	gochain.EnableJSONMode(l.llm, options)
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	tokens := gochain.CountMessages(l.limiter.tokenizer, messages) + l.limiter.maxCompletionTokens(options)
+	r, err := l.limiter.Wait(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := gochain.Generate(ctx, l.llm, messages, options...)
+	if err != nil {
+		r.Done(0)
+		return nil, err
+	}
+
+	r.Done(g.Usage.TotalTokens)
+
+	return g, nil
+}
+
+// maxCompletionTokens returns the completion tokens a request allows, from
+// the max_tokens or num_predict option, or the limiter default.
+func (l *Limiter) maxCompletionTokens(options []map[string]interface{}) int {
+	for _, o := range options {
+		for _, key := range []string{"max_tokens", "num_predict"} {
+			switch n := o[key].(type) {
+			case int:
+				if n > 0 {
+					return n
+				}
+			case int64:
+				if n > 0 {
+					return int(n)
+				}
+			case float64:
+				if n > 0 {
+					return int(n)
+				}
+			}
+		}
+	}
+
+	return l.completionTokens
+}
@@ -0,0 +1,287 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/ratelimit"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a manual clock. After moves the time forward by the delay and
+// fires at once, unless blocked.
+type clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	delays  []time.Duration
+	blocked bool
+	waiting chan struct{}
+}
+
+func newClock() *clock {
+	return &clock{now: time.Unix(0, 0), waiting: make(chan struct{}, 16)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.delays = append(c.delays, d)
+	c.waiting <- struct{}{}
+
+	ch := make(chan time.Time, 1)
+	if !c.blocked {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+
+	return ch
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func (c *clock) Delays() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]time.Duration{}, c.delays...)
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithRequestsPerMinute(2), ratelimit.WithClock(c))
+
+	for i := 0; i < 3; i++ {
+		r, err := l.Wait(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Done(0)
+	}
+
+	// The third request waits for half a minute, one request refilling.
+	if got := c.Delays(); !reflect.DeepEqual(got, []time.Duration{30 * time.Second}) {
+		t.Errorf("delays = %v", got)
+	}
+
+	// Idle time refills the bucket up to its size only.
+	c.Advance(10 * time.Minute)
+	for i := 0; i < 2; i++ {
+		r, err := l.Wait(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Done(0)
+	}
+
+	if got := len(c.Delays()); got != 1 {
+		t.Errorf("%d delays after the refill, want none more", got)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithTokensPerMinute(1000), ratelimit.WithClock(c))
+
+	r, err := l.Wait(context.Background(), 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request used fewer tokens than estimated, the rest is given
+	// back.
+	r.Done(200)
+
+	r, err = l.Wait(context.Background(), 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Done(0)
+
+	if got := c.Delays(); len(got) != 0 {
+		t.Errorf("delays = %v, want none", got)
+	}
+
+	// Requests larger than the bucket wait for a full bucket, not forever.
+	r, err = l.Wait(context.Background(), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Done(0)
+
+	if got := c.Delays(); len(got) != 1 || got[0] > time.Minute {
+		t.Errorf("delays = %v, want one of at most a minute", got)
+	}
+}
+
+// zeroTokenizer counts no tokens, so prompts cost the message overhead.
+type zeroTokenizer struct{}
+
+func (zeroTokenizer) Count(string) int {
+	return 0
+}
+
+func TestLLMReservesCompletionTokens(t *testing.T) {
+	prompt := gochain.CountMessages(zeroTokenizer{}, []gochain.Message{{Role: "user", Content: "hi"}})
+
+	for _, tt := range []struct {
+		name    string
+		options map[string]interface{}
+		want    int
+	}{
+		{"default", nil, 256},
+		{"max_tokens", map[string]interface{}{"max_tokens": 100}, 100},
+		{"num_predict", map[string]interface{}{"num_predict": float64(50)}, 50},
+		{"unlimited num_predict", map[string]interface{}{"num_predict": -1}, 256},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClock()
+
+			// A bucket of exactly the estimate: one request is free, the
+			// second waits a full minute.
+			l := ratelimit.NewLimiter(ratelimit.WithTokensPerMinute(prompt+tt.want), ratelimit.WithTokenizer(zeroTokenizer{}), ratelimit.WithClock(c))
+			llm := ratelimit.New(gochaintest.NewFakeLLM().Default(gochaintest.Text("hello")), l)
+
+			for i := 0; i < 2; i++ {
+				if _, err := llm.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}}, tt.options); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := c.Delays(); !reflect.DeepEqual(got, []time.Duration{time.Minute}) {
+				t.Errorf("delays = %v, want the second request to wait a minute", got)
+			}
+		})
+	}
+}
+
+func TestLLMChargesUsage(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithTokensPerMinute(1000), ratelimit.WithCompletionTokens(500), ratelimit.WithTokenizer(zeroTokenizer{}), ratelimit.WithClock(c))
+
+	generation := gochaintest.Response{Generation: &gochain.Generation{Content: "hi", Usage: gochain.Usage{TotalTokens: 100}}}
+	llm := ratelimit.New(gochaintest.NewFakeLLM().Default(generation), l)
+
+	// Each request reserves about 500 tokens but is charged the 100 it
+	// used, so five requests fit in the bucket.
+	for i := 0; i < 5; i++ {
+		if _, err := llm.Chat(context.Background(), []gochain.Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := c.Delays(); len(got) != 0 {
+		t.Errorf("delays = %v, want none", got)
+	}
+}
+
+func TestSharedLimiter(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithRequestsPerMinute(1), ratelimit.WithClock(c))
+
+	chat := ratelimit.New(gochaintest.NewFakeLLM().Default(gochaintest.Text("a")), l)
+	other := ratelimit.New(gochaintest.NewFakeLLM().Default(gochaintest.Text("b")), l)
+
+	if _, err := chat.Chat(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.Chat(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Delays(); !reflect.DeepEqual(got, []time.Duration{time.Minute}) {
+		t.Errorf("delays = %v, want the second LLM to wait for the first", got)
+	}
+}
+
+func TestCancelWhileWaitingForRate(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithRequestsPerMinute(1), ratelimit.WithMaxInFlight(1), ratelimit.WithClock(c))
+
+	held, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.blocked = true
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := l.Wait(ctx, 0)
+		done <- err
+	}()
+
+	// The second request waits on the rate while the first holds the
+	// only slot, it does not queue for the slot first.
+	<-c.waiting
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	// The cancelled request gave its budget back: a minute refills one
+	// request and the next one goes through without waiting.
+	held.Done(0)
+	c.blocked = false
+	c.Advance(time.Minute)
+
+	r, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Done(0)
+
+	if got := len(c.Delays()); got != 1 {
+		t.Errorf("%d delays, want only the cancelled one", got)
+	}
+}
+
+func TestCancelWhileWaitingForSlot(t *testing.T) {
+	c := newClock()
+	l := ratelimit.NewLimiter(ratelimit.WithMaxInFlight(1), ratelimit.WithRequestsPerMinute(2), ratelimit.WithClock(c))
+
+	held, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The slot is free again once released, and the request budget taken
+	// by the cancelled call was given back. Releasing twice is harmless.
+	held.Done(0)
+	held.Done(0)
+
+	r, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Done(0)
+
+	if got := c.Delays(); len(got) != 0 {
+		t.Errorf("delays = %v, want none", got)
+	}
+}
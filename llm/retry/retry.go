@@ -0,0 +1,277 @@
+// Package retry wraps a gochain.LLM so transient failures are retried with
+// exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/internal/logging"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts  = 3
+	defaultInitialDelay = 500 * time.Millisecond
+	defaultMaxDelay     = 30 * time.Second
+	defaultJitter       = 0.2
+)
+
+// Clock is the time source used to wait between attempts.
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Classifier reports whether err is worth another attempt.
+type Classifier func(err error) bool
+
+type Option func(*LLM)
+
+// WithMaxAttempts sets how many times a request is tried in total.
+func WithMaxAttempts(n int) Option {
+	return func(l *LLM) {
+		l.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the cap the
+// doubling delay never exceeds.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(l *LLM) {
+		l.initialDelay = initial
+		l.maxDelay = max
+	}
+}
+
+// WithJitter randomizes each delay by up to the given fraction in either
+// direction. Zero disables jitter.
+func WithJitter(fraction float64) Option {
+	return func(l *LLM) {
+		l.jitter = fraction
+	}
+}
+
+// WithRandom sets the source of jitter, returning values in [0, 1).
+func WithRandom(random func() float64) Option {
+	return func(l *LLM) {
+		l.random = random
+	}
+}
+
+// WithAttemptTimeout bounds each attempt separately from the caller's
+// context.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(l *LLM) {
+		l.attemptTimeout = d
+	}
+}
+
+func WithClassifier(classify Classifier) Option {
+	return func(l *LLM) {
+		l.classify = classify
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(l *LLM) {
+		l.clock = clock
+	}
+}
+
+// WithLogger logs every retry at warn level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(l *LLM) {
+		l.logger = logger
+	}
+}
+
+// LLM retries requests to the wrapped LLM. It implements
+// gochain.GenerationLLM only: the streaming and native tool calling of the
+// wrapped LLM are hidden, so a Chain falls back to prompted tool calls and
+// gochain.Stream to a single chunk. Use Do to retry those calls directly.
+type LLM struct {
+	llm            gochain.LLM
+	maxAttempts    int
+	initialDelay   time.Duration
+	maxDelay       time.Duration
+	jitter         float64
+	random         func() float64
+	attemptTimeout time.Duration
+	classify       Classifier
+	clock          Clock
+	logger         *slog.Logger
+}
+
+func New(llm gochain.LLM, opts ...Option) *LLM {
+	l := &LLM{
+		llm:          llm,
+		maxAttempts:  defaultMaxAttempts,
+		initialDelay: defaultInitialDelay,
+		maxDelay:     defaultMaxDelay,
+		jitter:       defaultJitter,
+		random:       rand.Float64,
+		classify:     Retryable,
+		clock:        realClock{},
+		logger:       logging.Discard,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *LLM) Name() string {
+	return l.llm.Name()
+}
+
+// Model reports the model of the wrapped LLM, if it has one.
+func (l *LLM) Model() string {
+	if m, ok := l.llm.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+// JSONMode forwards to the wrapped LLM.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	gochain.EnableJSONMode(l.llm, options)
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	var g *gochain.Generation
+	err := l.Do(ctx, func(ctx context.Context) error {
+		var err error
+		g, err = gochain.Generate(ctx, l.llm, messages, options...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Do runs fn until it succeeds, fails with a fatal error or runs out of
+// attempts. It is exported so other calls, such as embeddings, can share
+// the same policy.
+func (l *LLM) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = l.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+
+		// The caller gave up, so the attempt timing out is not transient.
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if attempt >= l.maxAttempts || !l.classify(err) {
+			break
+		}
+
+		delay := l.delay(attempt, err)
+		l.logger.WarnContext(ctx, "retry: request failed, retrying",
+			slog.String(gochain.LogKeyProvider, l.llm.Name()),
+			slog.Int(gochain.LogKeyAttempt, attempt),
+			slog.Duration(gochain.LogKeyDuration, delay),
+			slog.Any(gochain.LogKeyError, err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(delay):
+		}
+	}
+
+	if attempt == 1 {
+		return err
+	}
+
+	return fmt.Errorf("retry: giving up after %d attempts: %w", attempt, err)
+}
+
+func (l *LLM) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if l.attemptTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.attemptTimeout)
+	defer cancel()
+
+	return fn(ctx)
+}
+
+// delay returns the backoff before the next attempt, or the wait the
+// provider asked for when it is longer.
+func (l *LLM) delay(attempt int, err error) time.Duration {
+	d := float64(l.initialDelay) * math.Pow(2, float64(attempt-1))
+	if max := float64(l.maxDelay); l.maxDelay > 0 && d > max {
+		d = max
+	}
+
+	if l.jitter > 0 {
+		d *= 1 + l.jitter*(2*l.random()-1)
+	}
+
+	delay := time.Duration(d)
+
+	var pe *gochain.ProviderError
+	if errors.As(err, &pe) && pe.RetryAfter > delay {
+		delay = pe.RetryAfter
+	}
+
+	return delay
+}
+
+// Retryable is the default classifier. Rate limits, server errors, timeouts
+// and dropped connections are retried; everything else is fatal.
+func Retryable(err error) bool {
+	var pe *gochain.ProviderError
+	if errors.As(err, &pe) {
+		switch {
+		case pe.StatusCode == http.StatusTooManyRequests,
+			pe.StatusCode == http.StatusRequestTimeout,
+			pe.StatusCode >= http.StatusInternalServerError && pe.StatusCode != http.StatusNotImplemented:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
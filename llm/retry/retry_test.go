@@ -0,0 +1,194 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/retry"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeClock records the requested delays and fires them at once, unless
+// blocked.
+type fakeClock struct {
+	delays  []time.Duration
+	blocked bool
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+
+	ch := make(chan time.Time, 1)
+	if !c.blocked {
+		ch <- time.Time{}
+	}
+
+	return ch
+}
+
+var messages = []gochain.Message{{Role: "user", Content: "hi"}}
+
+func unavailable() gochaintest.Response {
+	return gochaintest.Error(&gochain.ProviderError{StatusCode: http.StatusServiceUnavailable})
+}
+
+func TestBackoff(t *testing.T) {
+	clock := &fakeClock{}
+	fake := gochaintest.NewFakeLLM().Default(unavailable())
+	llm := retry.New(fake,
+		retry.WithMaxAttempts(5),
+		retry.WithBackoff(100*time.Millisecond, time.Second),
+		retry.WithJitter(0),
+		retry.WithClock(clock))
+
+	_, err := llm.Chat(context.Background(), messages)
+
+	var pe *gochain.ProviderError
+	if !errors.As(err, &pe) || pe.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want the provider error", err)
+	}
+
+	if !strings.Contains(err.Error(), "after 5 attempts") {
+		t.Errorf("err = %q", err)
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond}
+	if !reflect.DeepEqual(clock.delays, want) {
+		t.Errorf("delays = %v, want %v", clock.delays, want)
+	}
+
+	if got := fake.CallCount(); got != 5 {
+		t.Errorf("calls = %d, want 5", got)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	clock := &fakeClock{}
+	llm := retry.New(gochaintest.NewFakeLLM().Default(unavailable()),
+		retry.WithMaxAttempts(4),
+		retry.WithBackoff(time.Second, 3*time.Second),
+		retry.WithJitter(0),
+		retry.WithClock(clock))
+
+	_, _ = llm.Chat(context.Background(), messages)
+
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if !reflect.DeepEqual(clock.delays, want) {
+		t.Errorf("delays = %v, want %v", clock.delays, want)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	clock := &fakeClock{}
+	llm := retry.New(gochaintest.NewFakeLLM().Default(unavailable()),
+		retry.WithMaxAttempts(2),
+		retry.WithBackoff(time.Second, time.Minute),
+		retry.WithJitter(0.2),
+		retry.WithRandom(func() float64 { return 0.75 }),
+		retry.WithClock(clock))
+
+	_, _ = llm.Chat(context.Background(), messages)
+
+	want := []time.Duration{1100 * time.Millisecond}
+	if !reflect.DeepEqual(clock.delays, want) {
+		t.Errorf("delays = %v, want %v", clock.delays, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	clock := &fakeClock{}
+	fake := gochaintest.NewFakeLLM().Respond(
+		gochaintest.Error(&gochain.ProviderError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}),
+		gochaintest.Text("hello"),
+	)
+	llm := retry.New(fake, retry.WithBackoff(100*time.Millisecond, time.Second), retry.WithJitter(0), retry.WithClock(clock))
+
+	got, err := llm.Chat(context.Background(), messages)
+	if err != nil || got != "hello" {
+		t.Fatalf("Chat() = %q, %v", got, err)
+	}
+
+	// Retry-After wins over a shorter backoff, even above the cap.
+	want := []time.Duration{5 * time.Second}
+	if !reflect.DeepEqual(clock.delays, want) {
+		t.Errorf("delays = %v, want %v", clock.delays, want)
+	}
+}
+
+func TestFatalErrorIsNotRetried(t *testing.T) {
+	clock := &fakeClock{}
+	badRequest := &gochain.ProviderError{StatusCode: http.StatusBadRequest}
+	fake := gochaintest.NewFakeLLM().Default(gochaintest.Error(badRequest))
+	llm := retry.New(fake, retry.WithClock(clock))
+
+	_, err := llm.Chat(context.Background(), messages)
+	if err != badRequest {
+		t.Errorf("err = %v, want the unwrapped provider error", err)
+	}
+
+	if fake.CallCount() != 1 || len(clock.delays) != 0 {
+		t.Errorf("calls = %d, delays = %v", fake.CallCount(), clock.delays)
+	}
+}
+
+func TestCancelWhileWaiting(t *testing.T) {
+	clock := &fakeClock{blocked: true}
+	fake := gochaintest.NewFakeLLM().Default(unavailable())
+	llm := retry.New(fake, retry.WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := llm.Chat(ctx, messages)
+		done <- err
+	}()
+
+	// Wait for the first attempt before cancelling the backoff.
+	for fake.CallCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retry did not stop on cancellation")
+	}
+
+	if got := fake.CallCount(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&gochain.ProviderError{StatusCode: http.StatusTooManyRequests}, true},
+		{&gochain.ProviderError{StatusCode: http.StatusRequestTimeout}, true},
+		{&gochain.ProviderError{StatusCode: http.StatusBadGateway}, true},
+		{&gochain.ProviderError{StatusCode: http.StatusNotImplemented}, false},
+		{&gochain.ProviderError{StatusCode: http.StatusUnauthorized}, false},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("invalid request"), false},
+	} {
+		if got := retry.Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of the newest sample in the latency moving
+// average.
+const latencyWeight = 0.3
+
+// backend tracks the health of one routed LLM.
+type backend struct {
+	Backend
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+	unhealthy bool
+	latency   time.Duration
+}
+
+// available reports whether the backend may take a request. Once the
+// cooldown of an open circuit elapses a single probe request is let through.
+func (b *backend) available(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.unhealthy {
+		return false
+	}
+
+	if b.openUntil.IsZero() {
+		return true
+	}
+
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+
+	b.probing = true
+
+	return true
+}
+
+func (b *backend) success(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+
+	if b.latency == 0 {
+		b.latency = latency
+	} else {
+		b.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(b.latency))
+	}
+}
+
+func (b *backend) failure(now time.Time, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if threshold > 0 && b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+	}
+}
+
+// abandon ends a request given up by the caller without recording it.
+// The circuit stays as it was, but a probe may be sent again.
+func (b *backend) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *backend) setHealthy(healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.unhealthy = !healthy
+}
+
+func (b *backend) averageLatency() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.latency
+}
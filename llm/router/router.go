@@ -0,0 +1,323 @@
+// Package router spreads requests over several gochain.LLM backends and
+// fails over between them.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/internal/logging"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoBackends         = errors.New("router: no backends")
+	ErrNoBackendAvailable = errors.New("router: no backend available")
+)
+
+// MetadataBackend is the Generation.Metadata key holding the name of the
+// backend that served the request.
+const MetadataBackend = "router.backend"
+
+type Strategy int
+
+const (
+	// Fallback tries backends in the order they were given.
+	Fallback Strategy = iota
+	// RoundRobin rotates the first backend tried on every request.
+	RoundRobin
+	// Weighted picks the first backend at random, proportionally to its
+	// weight.
+	Weighted
+	// LeastLatency prefers the backend with the lowest average latency.
+	LeastLatency
+)
+
+// Backend is an LLM taking part in routing. Name defaults to the LLM name
+// and Weight, used by the Weighted strategy, to 1.
+type Backend struct {
+	Name   string
+	LLM    gochain.LLM
+	Weight int
+}
+
+// HealthCheck reports whether a backend is able to serve requests.
+type HealthCheck func(ctx context.Context, llm gochain.LLM) error
+
+type Option func(*Router)
+
+func WithStrategy(strategy Strategy) Option {
+	return func(r *Router) {
+		r.strategy = strategy
+	}
+}
+
+// WithCircuitBreaker stops routing to a backend after threshold
+// consecutive failures, until cooldown has passed.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(r *Router) {
+		r.threshold = threshold
+		r.cooldown = cooldown
+	}
+}
+
+// WithHealthCheck sets the check run by Check and RunHealthChecks.
+func WithHealthCheck(check HealthCheck) Option {
+	return func(r *Router) {
+		r.check = check
+	}
+}
+
+// WithFailover decides which errors move a request on to the next backend.
+// By default every error does.
+func WithFailover(failover func(err error) bool) Option {
+	return func(r *Router) {
+		r.failover = failover
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(r *Router) {
+		r.now = now
+	}
+}
+
+// WithRandom sets the source used by the Weighted strategy, returning
+// values in [0, 1).
+func WithRandom(random func() float64) Option {
+	return func(r *Router) {
+		r.random = random
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Router) {
+		r.logger = logger
+	}
+}
+
+// Router implements gochain.LLM over several backends.
+type Router struct {
+	backends  []*backend
+	strategy  Strategy
+	threshold int
+	cooldown  time.Duration
+	check     HealthCheck
+	failover  func(error) bool
+	now       func() time.Time
+	random    func() float64
+	logger    *slog.Logger
+
+	next   atomic.Uint64
+	randMu sync.Mutex
+}
+
+func New(backends []Backend, opts ...Option) (*Router, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackends
+	}
+
+	r := &Router{
+		now:    time.Now,
+		random: rand.Float64,
+		logger: logging.Discard,
+	}
+
+	for _, b := range backends {
+		if b.LLM == nil {
+			return nil, fmt.Errorf("router: backend %q has no LLM", b.Name)
+		}
+
+		if b.Name == "" {
+			b.Name = b.LLM.Name()
+		}
+
+		if b.Weight <= 0 {
+			b.Weight = 1
+		}
+
+		r.backends = append(r.backends, &backend{Backend: b})
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+func (r *Router) Name() string {
+	return "router"
+}
+
+// jsonModeKey marks calls asking for JSON mode. The backend serving the
+// call decides which options enable it.
+const jsonModeKey = "router.json_mode"
+
+// JSONMode enables JSON mode on whichever backend serves the call, when it
+// has one.
+func (r *Router) JSONMode(options map[string]interface{}) {
+	options[jsonModeKey] = true
+}
+
+func (r *Router) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := r.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+// ChatGeneration sends the request to the backends in strategy order until
+// one succeeds. The backend used is recorded under MetadataBackend.
+func (r *Router) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	jsonMode := false
+	for _, o := range options {
+		if _, ok := o[jsonModeKey]; ok {
+			jsonMode = true
+		}
+	}
+
+	var errs []error
+	for _, b := range r.order() {
+		if !b.available(r.now()) {
+			continue
+		}
+
+		start := r.now()
+		g, err := gochain.Generate(ctx, b.LLM, messages, backendOptions(b.LLM, jsonMode, options)...)
+		if err == nil {
+			b.success(r.now().Sub(start))
+
+			if g.Metadata == nil {
+				g.Metadata = map[string]interface{}{}
+			}
+			g.Metadata[MetadataBackend] = b.Name
+
+			return g, nil
+		}
+
+		// The caller giving up says nothing about the backend health.
+		if ctx.Err() != nil {
+			b.abandon()
+			return nil, err
+		}
+
+		b.failure(r.now(), r.threshold, r.cooldown)
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+
+		if r.failover != nil && !r.failover(err) {
+			return nil, err
+		}
+
+		r.logger.WarnContext(ctx, "router: backend failed",
+			slog.String(gochain.LogKeyProvider, b.Name),
+			slog.Any(gochain.LogKeyError, err))
+	}
+
+	return nil, errors.Join(append([]error{ErrNoBackendAvailable}, errs...)...)
+}
+
+// backendOptions copies the call options for llm, replacing the JSON mode
+// marker with the options llm uses for it.
+func backendOptions(llm gochain.LLM, jsonMode bool, options []map[string]interface{}) []map[string]interface{} {
+	if !jsonMode {
+		return options
+	}
+
+	merged := map[string]interface{}{}
+	for _, o := range options {
+		for k, v := range o {
+			merged[k] = v
+		}
+	}
+
+	delete(merged, jsonModeKey)
+	gochain.EnableJSONMode(llm, merged)
+
+	return []map[string]interface{}{merged}
+}
+
+// order returns the backends in the order they should be tried.
+func (r *Router) order() []*backend {
+	order := append([]*backend{}, r.backends...)
+
+	switch r.strategy {
+	case RoundRobin:
+		n := int(r.next.Add(1)-1) % len(order)
+		order = append(order[n:], order[:n]...)
+	case Weighted:
+		total := 0
+		for _, b := range order {
+			total += b.Weight
+		}
+
+		r.randMu.Lock()
+		pick := int(r.random() * float64(total))
+		r.randMu.Unlock()
+
+		for i, b := range order {
+			if pick < b.Weight {
+				order[0], order[i] = order[i], order[0]
+				break
+			}
+			pick -= b.Weight
+		}
+	case LeastLatency:
+		// Backends without samples yet go first so they get measured.
+		sort.SliceStable(order, func(i, j int) bool {
+			return order[i].averageLatency() < order[j].averageLatency()
+		})
+	}
+
+	return order
+}
+
+// Check runs the health check against every backend once. Unhealthy
+// backends are skipped until a later check passes.
+func (r *Router) Check(ctx context.Context) {
+	if r.check == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, b := range r.backends {
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+
+			err := r.check(ctx, b.LLM)
+			if err != nil {
+				r.logger.WarnContext(ctx, "router: health check failed",
+					slog.String(gochain.LogKeyProvider, b.Name),
+					slog.Any(gochain.LogKeyError, err))
+			}
+
+			b.setHealthy(err == nil)
+		}(b)
+	}
+	wg.Wait()
+}
+
+// RunHealthChecks calls Check every interval until ctx is done.
+func (r *Router) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/llm/router"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var messages = []gochain.Message{{Role: "user", Content: "hi"}}
+
+// clock is a manual clock.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// fakeLLM answers with its name, or fails while failing is set.
+type fakeLLM struct {
+	name    string
+	calls   int
+	failing bool
+	latency time.Duration
+	clock   *clock
+	chat    func(ctx context.Context) error
+}
+
+func (l *fakeLLM) Name() string {
+	return l.name
+}
+
+func (l *fakeLLM) Chat(ctx context.Context, _ []gochain.Message, _ ...map[string]interface{}) (string, error) {
+	l.calls++
+
+	if l.clock != nil {
+		l.clock.Advance(l.latency)
+	}
+
+	if l.chat != nil {
+		if err := l.chat(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	if l.failing {
+		return "", errors.New(l.name + " is down")
+	}
+
+	return l.name, nil
+}
+
+func backends(llms ...*fakeLLM) []router.Backend {
+	b := make([]router.Backend, len(llms))
+	for i, l := range llms {
+		b[i] = router.Backend{LLM: l}
+	}
+
+	return b
+}
+
+// serve returns the backends that served n requests.
+func serve(t *testing.T, r *router.Router, n int) []string {
+	t.Helper()
+
+	var served []string
+	for i := 0; i < n; i++ {
+		g, err := r.ChatGeneration(context.Background(), messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if g.Metadata[router.MetadataBackend] != g.Content {
+			t.Errorf("metadata backend = %v, served by %s", g.Metadata[router.MetadataBackend], g.Content)
+		}
+
+		served = append(served, g.Content)
+	}
+
+	return served
+}
+
+func TestNew(t *testing.T) {
+	if _, err := router.New(nil); !errors.Is(err, router.ErrNoBackends) {
+		t.Errorf("err = %v, want ErrNoBackends", err)
+	}
+
+	if _, err := router.New([]router.Backend{{Name: "a"}}); err == nil {
+		t.Error("backend without LLM accepted")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	a, b := &fakeLLM{name: "a", failing: true}, &fakeLLM{name: "b"}
+	r, err := router.New(backends(a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := serve(t, r, 2); !reflect.DeepEqual(got, []string{"b", "b"}) {
+		t.Errorf("served by %q", got)
+	}
+
+	b.failing = true
+	_, err = r.Chat(context.Background(), messages)
+	if !errors.Is(err, router.ErrNoBackendAvailable) {
+		t.Errorf("err = %v, want ErrNoBackendAvailable", err)
+	}
+
+	if err == nil || err.Error() != "router: no backend available\na: a is down\nb: b is down" {
+		t.Errorf("err = %q, want the error of every backend", err)
+	}
+}
+
+func TestFailoverPredicate(t *testing.T) {
+	a, b := &fakeLLM{name: "a", failing: true}, &fakeLLM{name: "b"}
+	r, err := router.New(backends(a, b), router.WithFailover(func(error) bool { return false }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Chat(context.Background(), messages); err == nil || err.Error() != "a is down" || b.calls != 0 {
+		t.Errorf("err = %v, b called %d times", err, b.calls)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	r, err := router.New(backends(&fakeLLM{name: "a"}, &fakeLLM{name: "b"}, &fakeLLM{name: "c"}), router.WithStrategy(router.RoundRobin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := serve(t, r, 4); !reflect.DeepEqual(got, []string{"a", "b", "c", "a"}) {
+		t.Errorf("served by %q", got)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	random := 0.0
+	r, err := router.New([]router.Backend{
+		{LLM: &fakeLLM{name: "a"}, Weight: 1},
+		{LLM: &fakeLLM{name: "b"}, Weight: 3},
+	}, router.WithStrategy(router.Weighted), router.WithRandom(func() float64 { return random }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		random float64
+		want   string
+	}{
+		{0, "a"},
+		{0.24, "a"},
+		{0.25, "b"},
+		{0.99, "b"},
+	} {
+		random = tt.random
+		if got := serve(t, r, 1)[0]; got != tt.want {
+			t.Errorf("random %v served by %s, want %s", tt.random, got, tt.want)
+		}
+	}
+}
+
+func TestLeastLatency(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	slow := &fakeLLM{name: "slow", clock: c, latency: time.Second}
+	fast := &fakeLLM{name: "fast", clock: c, latency: 10 * time.Millisecond}
+
+	r, err := router.New(backends(slow, fast), router.WithStrategy(router.LeastLatency), router.WithClock(c.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Backends without samples go first so they get measured: fast is
+	// measured when slow fails, slow on the next request. Then the fastest
+	// wins.
+	slow.failing = true
+	serve(t, r, 1)
+	slow.failing = false
+
+	if got := serve(t, r, 3); !reflect.DeepEqual(got, []string{"slow", "fast", "fast"}) {
+		t.Errorf("served by %q", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	a, b := &fakeLLM{name: "a", failing: true}, &fakeLLM{name: "b"}
+	r, err := router.New(backends(a, b), router.WithCircuitBreaker(2, time.Minute), router.WithClock(c.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, r, 3)
+	if a.calls != 2 {
+		t.Fatalf("a called %d times, want the circuit open after 2 failures", a.calls)
+	}
+
+	// After the cooldown a single probe goes through, and fails.
+	c.Advance(time.Minute)
+	serve(t, r, 2)
+	if a.calls != 3 {
+		t.Fatalf("a called %d times, want one probe", a.calls)
+	}
+
+	// The failed probe opens the circuit again.
+	c.Advance(30 * time.Second)
+	serve(t, r, 1)
+	if a.calls != 3 {
+		t.Fatalf("a called %d times during the new cooldown", a.calls)
+	}
+
+	// A successful probe closes it.
+	a.failing = false
+	c.Advance(time.Minute)
+	if got := serve(t, r, 3); !reflect.DeepEqual(got, []string{"a", "a", "a"}) {
+		t.Errorf("served by %q after recovery", got)
+	}
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	a, b := &fakeLLM{name: "a", failing: true}, &fakeLLM{name: "b"}
+	r, err := router.New(backends(a, b), router.WithCircuitBreaker(1, time.Minute), router.WithClock(c.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, r, 1)
+	c.Advance(time.Minute)
+
+	// The caller gives up during the probe.
+	ctx, cancel := context.WithCancel(context.Background())
+	a.chat = func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if _, err := r.Chat(ctx, messages); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	if b.calls != 1 {
+		t.Errorf("b called %d times after the cancellation", b.calls)
+	}
+
+	// The next request probes again, the circuit did not reopen and is
+	// not stuck probing.
+	a.chat = nil
+	a.failing = false
+	if got := serve(t, r, 1); got[0] != "a" || a.calls != 3 {
+		t.Errorf("served by %q, a called %d times, want a probed again", got, a.calls)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	a, b := &fakeLLM{name: "a"}, &fakeLLM{name: "b"}
+	healthy := map[string]bool{"a": false, "b": true}
+	r, err := router.New(backends(a, b), router.WithHealthCheck(func(_ context.Context, llm gochain.LLM) error {
+		if !healthy[llm.Name()] {
+			return errors.New("unhealthy")
+		}
+
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Check(context.Background())
+	if got := serve(t, r, 1); got[0] != "b" {
+		t.Errorf("served by %s, want the healthy backend", got[0])
+	}
+
+	healthy["a"] = true
+	r.Check(context.Background())
+	if got := serve(t, r, 1); got[0] != "a" {
+		t.Errorf("served by %s after a recovered", got[0])
+	}
+}
+
+func TestJSONModePerBackend(t *testing.T) {
+	fake := gochaintest.NewFakeLLM().Default(gochaintest.Text("{}"))
+	r, err := router.New([]router.Backend{{LLM: fake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := map[string]interface{}{"temperature": 0}
+	gochain.EnableJSONMode(r, options)
+	if _, err := r.Chat(context.Background(), messages, options); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake has no JSON mode, so only the marker is dropped.
+	if got := fake.LastCall().Options; len(got) != 1 || !reflect.DeepEqual(got[0], map[string]interface{}{"temperature": 0}) {
+		t.Errorf("backend options = %v", got)
+	}
+}
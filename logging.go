@@ -0,0 +1,196 @@
+package gochain
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Attribute keys used by gochain and its backends in log records.
+const (
+	LogKeyProvider  = "provider"
+	LogKeyModel     = "model"
+	LogKeySessionID = "session_id"
+	LogKeyRunID     = "run_id"
+	LogKeyParentID  = "parent_run_id"
+	LogKeyTool      = "tool"
+	LogKeyInput     = "input"
+	LogKeyOutput    = "output"
+	LogKeyMessages  = "messages"
+	LogKeyContent   = "content"
+	LogKeyRaw       = "raw"
+	LogKeyDuration  = "duration"
+	LogKeyAttempt   = "attempt"
+	LogKeyStatus    = "status"
+	LogKeyURL       = "url"
+	LogKeyError     = "error"
+)
+
+const defaultLogMaxContentLength = 1024
+
+type LogOption func(*LogConfig)
+
+// LogConfig controls how prompt and response text is written to logs.
+type LogConfig struct {
+	// MaxContentLength truncates logged text to this many bytes. Negative
+	// disables truncation.
+	MaxContentLength int
+	// Redact rewrites logged text, for example to mask personal data. It
+	// is called from concurrent tool calls and must be goroutine-safe.
+	Redact func(string) string
+}
+
+func NewLogConfig(opts ...LogOption) LogConfig {
+	c := LogConfig{MaxContentLength: defaultLogMaxContentLength}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+func WithLogMaxContentLength(n int) LogOption {
+	return func(c *LogConfig) {
+		c.MaxContentLength = n
+	}
+}
+
+func WithLogRedactor(redact func(string) string) LogOption {
+	return func(c *LogConfig) {
+		c.Redact = redact
+	}
+}
+
+// Content prepares text for a log record: redacted, then truncated.
+func (c LogConfig) Content(text string) string {
+	if c.Redact != nil {
+		text = c.Redact(text)
+	}
+
+	if c.MaxContentLength < 0 || len(text) <= c.MaxContentLength {
+		return text
+	}
+
+	cut := c.MaxContentLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+
+	return text[:cut] + "...(" + strconv.Itoa(len(text)-cut) + " more bytes)"
+}
+
+// SetLogger logs requests, responses, parse failures and tool dispatch of
+// every invocation to logger.
+func (a *Chain) SetLogger(logger *slog.Logger, opts ...LogOption) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if logger == nil {
+		a.logger = nil
+		return
+	}
+
+	a.logger = &logHandler{logger: logger, config: NewLogConfig(opts...)}
+}
+
+// logHandler writes chain events to a slog.Logger. It runs after the user
+// callbacks so it logs tool calls as they are actually dispatched.
+type logHandler struct {
+	BaseCallbackHandler
+	logger *slog.Logger
+	config LogConfig
+}
+
+func (h *logHandler) runAttrs(info RunInfo) []any {
+	attrs := []any{slog.String(LogKeyRunID, info.RunID), slog.String(LogKeySessionID, info.SessionID)}
+	if info.ParentRunID != "" {
+		attrs = append(attrs, slog.String(LogKeyParentID, info.ParentRunID))
+	}
+
+	return attrs
+}
+
+func (h *logHandler) OnChainStart(ctx context.Context, e *ChainStartEvent) {
+	h.logger.DebugContext(ctx, "gochain: invoke",
+		append(h.runAttrs(e.RunInfo), slog.String(LogKeyInput, h.config.Content(e.Input)))...)
+}
+
+func (h *logHandler) OnChainEnd(ctx context.Context, e *ChainEndEvent) {
+	attrs := append(h.runAttrs(e.RunInfo), slog.Duration(LogKeyDuration, e.Duration))
+	if e.Err != nil {
+		h.logger.ErrorContext(ctx, "gochain: invoke failed", append(attrs, slog.Any(LogKeyError, e.Err))...)
+		return
+	}
+
+	h.logger.DebugContext(ctx, "gochain: invoke done", attrs...)
+}
+
+func (h *logHandler) OnLLMStart(ctx context.Context, e *LLMStartEvent) {
+	attrs := append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyProvider, e.Provider),
+		slog.String(LogKeyModel, e.Model),
+		slog.Int(LogKeyMessages, len(e.Messages)))
+
+	if len(e.Messages) > 0 {
+		attrs = append(attrs, slog.String(LogKeyContent, h.config.Content(e.Messages[len(e.Messages)-1].Text())))
+	}
+
+	h.logger.DebugContext(ctx, "gochain: llm request", attrs...)
+}
+
+func (h *logHandler) OnLLMEnd(ctx context.Context, e *LLMEndEvent) {
+	h.logger.DebugContext(ctx, "gochain: llm response", append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyProvider, e.Generation.Provider),
+		slog.String(LogKeyModel, e.Generation.Model),
+		slog.String(LogKeyRaw, h.config.Content(e.Generation.Content)),
+		slog.Duration(LogKeyDuration, e.Duration))...)
+}
+
+func (h *logHandler) OnLLMError(ctx context.Context, e *LLMErrorEvent) {
+	h.logger.ErrorContext(ctx, "gochain: llm request failed", append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyProvider, e.Provider),
+		slog.Duration(LogKeyDuration, e.Duration),
+		slog.Any(LogKeyError, e.Err))...)
+}
+
+func (h *logHandler) OnParseError(ctx context.Context, e *ParseErrorEvent) {
+	h.logger.WarnContext(ctx, "gochain: invalid model response", append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyRaw, h.config.Content(e.Raw)),
+		slog.Any(LogKeyError, e.Err))...)
+}
+
+func (h *logHandler) OnToolStart(ctx context.Context, e *ToolStartEvent) error {
+	input, _ := jsonString(e.Call.ToolInput)
+	h.logger.InfoContext(ctx, "gochain: tool call", append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyTool, e.Call.Tool),
+		slog.String(LogKeyInput, h.config.Content(input)))...)
+
+	return nil
+}
+
+func (h *logHandler) OnToolEnd(ctx context.Context, e *ToolEndEvent) {
+	attrs := append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyTool, e.Result.Tool),
+		slog.Duration(LogKeyDuration, e.Duration))
+
+	if e.Result.Output != nil {
+		output, _ := jsonString(e.Result.Output)
+		attrs = append(attrs, slog.String(LogKeyOutput, h.config.Content(output)))
+	}
+
+	h.logger.DebugContext(ctx, "gochain: tool done", attrs...)
+}
+
+func (h *logHandler) OnToolError(ctx context.Context, e *ToolErrorEvent) {
+	h.logger.WarnContext(ctx, "gochain: tool failed", append(h.runAttrs(e.RunInfo),
+		slog.String(LogKeyTool, e.Result.Tool),
+		slog.Duration(LogKeyDuration, e.Duration),
+		slog.Any(LogKeyError, e.Result.Err))...)
+}
+
+func jsonString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,132 @@
+package gochain_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogConfigContent(t *testing.T) {
+	mask := func(s string) string {
+		return strings.ReplaceAll(s, "secret", "******")
+	}
+
+	for _, tt := range []struct {
+		name   string
+		config gochain.LogConfig
+		text   string
+		want   string
+	}{
+		{"short", gochain.NewLogConfig(), "hello", "hello"},
+		{"truncated", gochain.NewLogConfig(gochain.WithLogMaxContentLength(5)), "hello world", "hello...(6 more bytes)"},
+		// "é" is two bytes, the cut moves back to the start of the rune.
+		{"rune boundary", gochain.NewLogConfig(gochain.WithLogMaxContentLength(2)), "aé", "a...(2 more bytes)"},
+		{"unlimited", gochain.NewLogConfig(gochain.WithLogMaxContentLength(-1)), strings.Repeat("a", 2000), strings.Repeat("a", 2000)},
+		{"default limit", gochain.NewLogConfig(), strings.Repeat("a", 1030), strings.Repeat("a", 1024) + "...(6 more bytes)"},
+		{"redacted", gochain.NewLogConfig(gochain.WithLogRedactor(mask)), "my secret", "my ******"},
+		// Redaction runs first, so secrets are never cut in half.
+		{"redacted then truncated", gochain.NewLogConfig(gochain.WithLogRedactor(mask), gochain.WithLogMaxContentLength(6)), "secret!", "******...(1 more bytes)"},
+	} {
+		if got := tt.config.Content(tt.text); got != tt.want {
+			t.Errorf("%s: Content() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// records decodes the JSON log lines in buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var r []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+
+		r = append(r, record)
+	}
+
+	return r
+}
+
+func TestChainLogger(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Respond(
+		gochaintest.ToolCall("lookup", map[string]interface{}{"card": "secret"}),
+		gochaintest.Text("not json"),
+	)
+
+	chain := gochain.New(llm)
+	if err := chain.RegisterTool("lookup", "", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("card blocked")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	chain.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		gochain.WithLogRedactor(func(s string) string { return strings.ReplaceAll(s, "secret", "***") }))
+
+	// The tool fails in the first run, the model in the second.
+	if _, err := chain.Run(context.Background(), "look up my card", gochain.WithSessionID("s1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.Run(context.Background(), "look up my card", gochain.WithSessionID("s1")); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	logged := records(t, &buf)
+
+	var messages []string
+	for _, r := range logged {
+		messages = append(messages, r["level"].(string)+" "+r["msg"].(string))
+
+		if r["session_id"] != "s1" || r["run_id"] == "" {
+			t.Errorf("record %q without its run: %v", r["msg"], r)
+		}
+	}
+
+	want := []string{
+		"DEBUG gochain: invoke",
+		"DEBUG gochain: llm request",
+		"DEBUG gochain: llm response",
+		"INFO gochain: tool call",
+		"WARN gochain: tool failed",
+		"DEBUG gochain: invoke done",
+		"DEBUG gochain: invoke",
+		"DEBUG gochain: llm request",
+		"DEBUG gochain: llm response",
+		"WARN gochain: invalid model response",
+		"ERROR gochain: invoke failed",
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("logged %q, want %q", messages, want)
+	}
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("unredacted log:\n%s", buf.String())
+	}
+
+	if call := logged[3]; call["tool"] != "lookup" || call["input"] != `{"card":"***"}` || call["parent_run_id"] != logged[0]["run_id"] {
+		t.Errorf("tool call record = %v", call)
+	}
+
+	buf.Reset()
+	chain.SetLogger(nil)
+	_, _ = chain.Run(context.Background(), "again")
+	if buf.Len() != 0 {
+		t.Errorf("logged after SetLogger(nil):\n%s", buf.String())
+	}
+}
@@ -0,0 +1,11 @@
+package gochain
+
+import "context"
+
+const DefaultSessionID = "default"
+
+type Memory interface {
+	Load(ctx context.Context, sessionID string) ([]Message, error)
+	Save(ctx context.Context, sessionID string, messages ...Message) error
+	Clear(ctx context.Context, sessionID string) error
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"sync"
+)
+
+// Buffer keeps the full history of every session in process memory.
+type Buffer struct {
+	mu       sync.RWMutex
+	sessions map[string][]gochain.Message
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{sessions: map[string][]gochain.Message{}}
+}
+
+func (b *Buffer) Load(_ context.Context, sessionID string) ([]gochain.Message, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	messages := b.sessions[sessionID]
+	result := make([]gochain.Message, len(messages))
+	copy(result, messages)
+
+	return result, nil
+}
+
+func (b *Buffer) Save(_ context.Context, sessionID string, messages ...gochain.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sessions[sessionID] = append(b.sessions[sessionID], messages...)
+
+	return nil
+}
+
+func (b *Buffer) Replace(_ context.Context, sessionID string, messages ...gochain.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sessions[sessionID] = append([]gochain.Message(nil), messages...)
+
+	return nil
+}
+
+func (b *Buffer) Clear(_ context.Context, sessionID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sessions, sessionID)
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const jsonlExt = ".jsonl"
+
+type jsonlRecord struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Message   gochain.Message `json:"message"`
+}
+
+// JSONLStore appends every session to its own JSON Lines file in dir.
+type JSONLStore struct {
+	dir  string
+	opts StoreOptions
+	mu   sync.Mutex
+}
+
+func NewJSONLStore(dir string, opts ...StoreOption) (*JSONLStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &JSONLStore{dir: dir, opts: NewStoreOptions(opts...)}, nil
+}
+
+func (s *JSONLStore) path(sessionID string) string {
+	return filepath.Join(s.dir, url.PathEscape(sessionID)+jsonlExt)
+}
+
+func (s *JSONLStore) Load(_ context.Context, sessionID string) ([]gochain.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && s.opts.Expired(records[len(records)-1].CreatedAt) {
+		return nil, s.remove(sessionID)
+	}
+
+	messages := make([]gochain.Message, 0, len(records))
+	for _, r := range records {
+		messages = append(messages, r.Message)
+	}
+
+	return messages, nil
+}
+
+func (s *JSONLStore) Save(_ context.Context, sessionID string, messages ...gochain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path(sessionID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(f, messages); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Replace writes the new history to a temporary file and renames it over
+// the session file.
+func (s *JSONLStore) Replace(_ context.Context, sessionID string, messages ...gochain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(messages) == 0 {
+		return s.remove(sessionID)
+	}
+
+	f, err := os.CreateTemp(s.dir, ".replace-*")
+	if err != nil {
+		return err
+	}
+
+	err = s.write(f, messages)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(sessionID))
+}
+
+func (s *JSONLStore) Clear(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remove(sessionID)
+}
+
+func (s *JSONLStore) Sessions(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, jsonlExt) {
+			continue
+		}
+
+		id, err := url.PathUnescape(strings.TrimSuffix(name, jsonlExt))
+		if err != nil {
+			continue
+		}
+
+		sessions = append(sessions, id)
+	}
+
+	return sessions, nil
+}
+
+func (s *JSONLStore) Prune(ctx context.Context) (int, error) {
+	if s.opts.TTL <= 0 {
+		return 0, nil
+	}
+
+	sessions, err := s.Sessions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := 0
+	for _, id := range sessions {
+		records, err := s.read(id)
+		if err != nil {
+			return pruned, err
+		}
+
+		if len(records) > 0 && !s.opts.Expired(records[len(records)-1].CreatedAt) {
+			continue
+		}
+
+		if err := s.remove(id); err != nil {
+			return pruned, err
+		}
+
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+func (s *JSONLStore) read(sessionID string) ([]jsonlRecord, error) {
+	f, err := os.Open(s.path(sessionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []jsonlRecord
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r jsonlRecord
+		if err := dec.Decode(&r); err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+func (s *JSONLStore) write(f *os.File, messages []gochain.Message) error {
+	now := s.opts.Now()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, m := range messages {
+		if err := enc.Encode(jsonlRecord{CreatedAt: now, Message: m}); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func (s *JSONLStore) remove(sessionID string) error {
+	err := os.Remove(s.path(sessionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,244 @@
+package memory_test
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain/memory"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for TTL tests.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestJSONLStorePersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := memory.NewJSONLStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := conversation(3)
+	if err := s.Save(ctx, "s", messages[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "s", messages[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := memory.NewJSONLStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := load(t, reopened, "s"); !reflect.DeepEqual(got, messages) {
+		t.Errorf("reloaded %q", contents(got))
+	}
+}
+
+func TestJSONLStoreEscapesSessionIDs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+
+	s, err := memory.NewJSONLStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{"../outside", "user/42", "a b?c#d", "%2F", "セッション"}
+	for i, id := range ids {
+		if err := s.Save(ctx, id, conversation(i+1)...); err != nil {
+			t.Fatalf("Save(%q): %v", id, err)
+		}
+	}
+
+	for i, id := range ids {
+		if got := load(t, s, id); len(got) != i+1 {
+			t.Errorf("Load(%q) = %d messages, want %d", id, len(got), i+1)
+		}
+	}
+
+	// Every session stays a single file inside the store directory.
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("files written outside the store: %v", entries)
+	}
+
+	entries, err := os.ReadDir(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(ids) {
+		t.Errorf("store holds %d files, want %d", len(entries), len(ids))
+	}
+
+	sessions, err := s.Sessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(ids)
+	sort.Strings(sessions)
+	if !reflect.DeepEqual(sessions, ids) {
+		t.Errorf("Sessions() = %q, want %q", sessions, ids)
+	}
+}
+
+func TestJSONLStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	s, err := memory.NewJSONLStore(t.TempDir(), memory.WithTTL(time.Hour), memory.WithClock(c.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(ctx, "old", conversation(2)...); err != nil {
+		t.Fatal(err)
+	}
+
+	c.now = c.now.Add(30 * time.Minute)
+	if err := s.Save(ctx, "recent", conversation(2)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// The TTL counts from the last message, not the first.
+	if err := s.Save(ctx, "old", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	c.now = c.now.Add(59 * time.Minute)
+	if got := load(t, s, "old"); len(got) != 3 {
+		t.Errorf("session expired early, loaded %d messages", len(got))
+	}
+
+	c.now = c.now.Add(2 * time.Minute)
+	if got := load(t, s, "old"); len(got) != 0 {
+		t.Errorf("expired session loaded %d messages", len(got))
+	}
+
+	sessions, err := s.Sessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sessions, []string{"recent"}) {
+		t.Errorf("Sessions() = %q, the expired session was not removed on Load", sessions)
+	}
+}
+
+func TestJSONLStorePrune(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	s, err := memory.NewJSONLStore(t.TempDir(), memory.WithTTL(time.Hour), memory.WithClock(c.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		if err := s.Save(ctx, id, conversation(1)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.now = c.now.Add(2 * time.Hour)
+	if err := s.Save(ctx, "c", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := s.Prune(ctx)
+	if err != nil || pruned != 2 {
+		t.Errorf("Prune() = %d, %v, want 2", pruned, err)
+	}
+
+	if sessions, _ := s.Sessions(ctx); !reflect.DeepEqual(sessions, []string{"c"}) {
+		t.Errorf("Sessions() = %q", sessions)
+	}
+}
+
+func TestJSONLStoreReplace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := memory.NewJSONLStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := conversation(4)
+	if err := s.Save(ctx, "s", messages[:3]...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Replace(ctx, "s", messages[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := contents(load(t, s, "s")); !reflect.DeepEqual(got, []string{"message 3"}) {
+		t.Errorf("after Replace = %q", got)
+	}
+
+	// The temporary file is renamed over the session, nothing is left over.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("store holds %v", entries)
+	}
+
+	if err := s.Replace(ctx, "s"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions, _ := s.Sessions(ctx); len(sessions) != 0 {
+		t.Errorf("replacing with no messages left %q", sessions)
+	}
+}
+
+func TestJSONLStoreReplaceIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := memory.NewJSONLStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(ctx, "s", conversation(3)...); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if err := s.Replace(ctx, "s", conversation(3)...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if got := load(t, s, "s"); len(got) != 3 {
+			t.Fatalf("loaded %d messages during Replace, want 3", len(got))
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/memory"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func conversation(n int) []gochain.Message {
+	messages := make([]gochain.Message, n)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+
+		messages[i] = gochain.Message{Role: role, Content: fmt.Sprintf("message %d", i)}
+	}
+
+	return messages
+}
+
+func contents(messages []gochain.Message) []string {
+	c := make([]string, len(messages))
+	for i, m := range messages {
+		c[i] = m.Content
+	}
+
+	return c
+}
+
+func load(t *testing.T, m gochain.Memory, sessionID string) []gochain.Message {
+	t.Helper()
+
+	messages, err := m.Load(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return messages
+}
+
+func TestBuffer(t *testing.T) {
+	ctx := context.Background()
+	b := memory.NewBuffer()
+
+	messages := conversation(3)
+	if err := b.Save(ctx, "a", messages[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(ctx, "a", messages[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := load(t, b, "a"); !reflect.DeepEqual(got, messages) {
+		t.Errorf("Load() = %v", contents(got))
+	}
+
+	if got := load(t, b, "b"); len(got) != 0 {
+		t.Errorf("other session = %v", contents(got))
+	}
+
+	// The loaded slice is a copy.
+	load(t, b, "a")[0].Content = "changed"
+	if got := load(t, b, "a")[0].Content; got != "message 0" {
+		t.Errorf("stored message changed to %q", got)
+	}
+
+	if err := b.Replace(ctx, "a", messages[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(load(t, b, "a")); !reflect.DeepEqual(got, []string{"message 1"}) {
+		t.Errorf("after Replace = %v", got)
+	}
+
+	if err := b.Clear(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(t, b, "a"); len(got) != 0 {
+		t.Errorf("after Clear = %v", contents(got))
+	}
+}
+
+func TestWindow(t *testing.T) {
+	for _, tt := range []struct {
+		size int
+		want int
+	}{
+		{size: 0, want: 0},
+		{size: 2, want: 2},
+		{size: 5, want: 5},
+		{size: 10, want: 5},
+	} {
+		w := memory.NewWindow(tt.size)
+		if err := w.Save(context.Background(), "s", conversation(5)...); err != nil {
+			t.Fatal(err)
+		}
+
+		got := load(t, w, "s")
+		if len(got) != tt.want {
+			t.Errorf("size %d: loaded %d messages, want %d", tt.size, len(got), tt.want)
+		}
+
+		if len(got) > 0 && got[len(got)-1].Content != "message 4" {
+			t.Errorf("size %d: last message = %q", tt.size, got[len(got)-1].Content)
+		}
+	}
+}
+
+// wordTokenizer counts one token per word.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestTokenWindow(t *testing.T) {
+	// Every message costs its two words plus the per-message overhead.
+	perMessage := gochain.CountMessage(wordTokenizer{}, gochain.Message{Role: "user", Content: "message 0"})
+
+	for _, tt := range []struct {
+		maxTokens int
+		want      []string
+	}{
+		{maxTokens: perMessage - 1, want: []string{}},
+		{maxTokens: perMessage, want: []string{"message 3"}},
+		{maxTokens: 2*perMessage + 1, want: []string{"message 2", "message 3"}},
+		{maxTokens: 100 * perMessage, want: []string{"message 0", "message 1", "message 2", "message 3"}},
+	} {
+		w := memory.NewTokenWindow(tt.maxTokens, wordTokenizer{})
+		if err := w.Save(context.Background(), "s", conversation(4)...); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := contents(load(t, w, "s")); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("maxTokens %d: loaded %v, want %v", tt.maxTokens, got, tt.want)
+		}
+	}
+}
+
+func TestTokenWindowDefaultTokenizer(t *testing.T) {
+	w := memory.NewTokenWindow(20, nil)
+	if err := w.Save(context.Background(), "s", gochain.Message{Role: "user", Content: strings.Repeat("long ", 100)}, gochain.Message{Role: "user", Content: "short"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := contents(load(t, w, "s")); !reflect.DeepEqual(got, []string{"short"}) {
+		t.Errorf("loaded %v, want the short message only", got)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	llm := gochaintest.NewFakeLLM("first summary", "second summary")
+	s := memory.NewSummary(llm, 4)
+	ctx := context.Background()
+
+	messages := conversation(8)
+	if err := s.Save(ctx, "s", messages[:4]...); err != nil {
+		t.Fatal(err)
+	}
+
+	if llm.CallCount() != 0 {
+		t.Fatal("summarized a session within the limit")
+	}
+
+	if err := s.Save(ctx, "s", messages[4]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The three oldest messages are summarized, the last two kept.
+	want := []string{"Summary of the earlier conversation:\nfirst summary", "message 3", "message 4"}
+	if got := contents(load(t, s, "s")); !reflect.DeepEqual(got, want) {
+		t.Errorf("after the first summary = %q, want %q", got, want)
+	}
+
+	if prompt := llm.LastCall().Messages[0].Content; !strings.Contains(prompt, "user: message 0") || strings.Contains(prompt, "message 3") {
+		t.Errorf("summary prompt = %q", prompt)
+	}
+
+	if err := s.Save(ctx, "s", messages[5:]...); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous summary is extended rather than summarized as a message.
+	if prompt := llm.LastCall().Messages[0].Content; !strings.Contains(prompt, "Previous summary:\nfirst summary") {
+		t.Errorf("summary prompt = %q", prompt)
+	}
+
+	want = []string{"Summary of the earlier conversation:\nsecond summary", "message 6", "message 7"}
+	if got := contents(load(t, s, "s")); !reflect.DeepEqual(got, want) {
+		t.Errorf("after the second summary = %q, want %q", got, want)
+	}
+}
+
+// failingSave is a Memory that is not a Replacer and fails to save after
+// a Clear, the way a store losing its connection would.
+type failingSave struct {
+	*memory.Buffer
+	cleared bool
+}
+
+func (f *failingSave) Clear(ctx context.Context, sessionID string) error {
+	f.cleared = true
+	return f.Buffer.Clear(ctx, sessionID)
+}
+
+func (f *failingSave) Save(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	if f.cleared {
+		return errors.New("store unavailable")
+	}
+
+	return f.Buffer.Save(ctx, sessionID, messages...)
+}
+
+func TestSummaryUsesReplace(t *testing.T) {
+	store := memory.NewBuffer()
+	s := memory.NewSummary(gochaintest.NewFakeLLM().Default(gochaintest.Text("summary")), 2, memory.WithStore(store))
+
+	if err := s.Save(context.Background(), "s", conversation(3)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := load(t, store, "s"); len(got) != 2 || got[0].Role != "system" {
+		t.Errorf("store holds %q", contents(got))
+	}
+}
+
+func TestSummaryFallsBackToClearAndSave(t *testing.T) {
+	store := &failingSave{Buffer: memory.NewBuffer()}
+	s := memory.NewSummary(gochaintest.NewFakeLLM().Default(gochaintest.Text("summary")), 2, memory.WithStore(struct {
+		gochain.Memory
+	}{store}))
+
+	if err := s.Save(context.Background(), "s", conversation(3)...); err == nil {
+		t.Fatal("expected the failed save to be reported")
+	}
+
+	if !store.cleared {
+		t.Error("a store without Replace was not cleared before saving")
+	}
+}
+
+func TestSummaryKeepsHistoryWhenSummarizerFails(t *testing.T) {
+	store := memory.NewBuffer()
+	s := memory.NewSummary(gochaintest.NewFakeLLM().Default(gochaintest.Error(errors.New("model unavailable"))), 2, memory.WithStore(store))
+
+	if err := s.Save(context.Background(), "s", conversation(3)...); err == nil {
+		t.Fatal("expected the summarizer error")
+	}
+
+	if got := load(t, store, "s"); len(got) != 3 {
+		t.Errorf("store holds %d messages, want the 3 saved", len(got))
+	}
+}
@@ -0,0 +1,30 @@
+package memory
+
+import "github.com/ryanbekhen/gochain"
+
+type Option func(*options)
+
+type options struct {
+	store gochain.Memory
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.store == nil {
+		o.store = NewBuffer()
+	}
+
+	return o
+}
+
+// WithStore sets the memory that holds the underlying history. It defaults
+// to a new Buffer.
+func WithStore(store gochain.Memory) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
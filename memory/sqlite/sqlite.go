@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/memory"
+	_ "modernc.org/sqlite"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS gochain_messages (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id TEXT    NOT NULL,
+	message    TEXT    NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS gochain_messages_session ON gochain_messages (session_id, id);
+`
+
+// Store persists sessions in a SQLite database using a pure Go driver.
+type Store struct {
+	db   *sql.DB
+	opts memory.StoreOptions
+}
+
+func Open(path string, opts ...memory.StoreOption) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, serialize access instead of failing
+	// with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	s, err := New(db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func New(db *sql.DB, opts ...memory.StoreOption) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db, opts: memory.NewStoreOptions(opts...)}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Load(ctx context.Context, sessionID string) ([]gochain.Message, error) {
+	if s.opts.TTL > 0 {
+		expired, err := s.expired(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if expired {
+			return nil, s.Clear(ctx, sessionID)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT message FROM gochain_messages WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []gochain.Message
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		var m gochain.Message
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+func (s *Store) Save(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.insert(ctx, tx, sessionID, messages); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replace deletes and rewrites the session in a single transaction.
+func (s *Store) Replace(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM gochain_messages WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+
+	if err := s.insert(ctx, tx, sessionID, messages); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) insert(ctx context.Context, tx *sql.Tx, sessionID string, messages []gochain.Message) error {
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO gochain_messages (session_id, message, created_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := s.opts.Now().UnixNano()
+	for _, m := range messages {
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.ExecContext(ctx, sessionID, string(raw), now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Clear(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM gochain_messages WHERE session_id = ?`, sessionID)
+	return err
+}
+
+func (s *Store) Sessions(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT session_id FROM gochain_messages ORDER BY session_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, id)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (s *Store) Prune(ctx context.Context) (int, error) {
+	if s.opts.TTL <= 0 {
+		return 0, nil
+	}
+
+	cutoff := s.opts.Now().Add(-s.opts.TTL).UnixNano()
+	rows, err := s.db.QueryContext(ctx, `SELECT session_id FROM gochain_messages GROUP BY session_id HAVING MAX(created_at) < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		expired = append(expired, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range expired {
+		if err := s.Clear(ctx, id); err != nil {
+			return i, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+func (s *Store) expired(ctx context.Context, sessionID string) (bool, error) {
+	var last sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(created_at) FROM gochain_messages WHERE session_id = ?`, sessionID).Scan(&last)
+	if err != nil {
+		return false, err
+	}
+
+	return last.Valid && s.opts.Expired(time.Unix(0, last.Int64)), nil
+}
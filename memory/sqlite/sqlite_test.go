@@ -0,0 +1,200 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/memory"
+	"github.com/ryanbekhen/gochain/memory/sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func conversation(n int) []gochain.Message {
+	messages := make([]gochain.Message, n)
+	for i := range messages {
+		messages[i] = gochain.Message{Role: "user", Content: fmt.Sprintf("message %d", i)}
+	}
+
+	return messages
+}
+
+func load(t *testing.T, s *sqlite.Store, sessionID string) []string {
+	t.Helper()
+
+	messages, err := s.Load(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		contents[i] = m.Content
+	}
+
+	return contents
+}
+
+func open(t *testing.T, path string, opts ...memory.StoreOption) *sqlite.Store {
+	t.Helper()
+
+	s, err := sqlite.Open(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memory.db")
+
+	s := open(t, path)
+	image := gochain.Message{Role: "user", Parts: []gochain.ContentPart{gochain.TextPart("look"), gochain.ImagePart([]byte("\x89PNG\r\n\x1a\n"), "")}}
+	if err := s.Save(ctx, "s", image); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "s", conversation(2)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	reopened := open(t, path)
+	messages, err := reopened.Load(ctx, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]gochain.Message{image}, conversation(2)...)
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("reloaded %+v", messages)
+	}
+}
+
+func TestStoreSessionIDs(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, filepath.Join(t.TempDir(), "memory.db"))
+
+	ids := []string{"'; DROP TABLE gochain_messages; --", "user/42", "セッション"}
+	for _, id := range ids {
+		if err := s.Save(ctx, id, conversation(1)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := s.Sessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(sessions, ids) {
+		t.Errorf("Sessions() = %q, want %q", sessions, ids)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := open(t, filepath.Join(t.TempDir(), "memory.db"), memory.WithTTL(time.Hour), memory.WithClock(func() time.Time { return now }))
+
+	if err := s.Save(ctx, "old", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := s.Save(ctx, "old", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "recent", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// The TTL counts from the last message, not the first.
+	now = now.Add(59 * time.Minute)
+	if got := load(t, s, "old"); len(got) != 2 {
+		t.Errorf("session expired early, loaded %q", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := load(t, s, "old"); len(got) != 0 {
+		t.Errorf("expired session loaded %q", got)
+	}
+
+	if sessions, _ := s.Sessions(ctx); !reflect.DeepEqual(sessions, []string{"recent"}) {
+		t.Errorf("Sessions() = %q, the expired session was not removed on Load", sessions)
+	}
+
+	now = now.Add(time.Hour)
+	pruned, err := s.Prune(ctx)
+	if err != nil || pruned != 1 {
+		t.Errorf("Prune() = %d, %v, want 1", pruned, err)
+	}
+
+	if sessions, _ := s.Sessions(ctx); len(sessions) != 0 {
+		t.Errorf("Sessions() after Prune = %q", sessions)
+	}
+}
+
+func TestStoreReplace(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, filepath.Join(t.TempDir(), "memory.db"))
+
+	if err := s.Save(ctx, "s", conversation(3)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "other", conversation(1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Replace(ctx, "s", gochain.Message{Role: "system", Content: "summary"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := load(t, s, "s"); !reflect.DeepEqual(got, []string{"summary"}) {
+		t.Errorf("after Replace = %q", got)
+	}
+
+	if got := load(t, s, "other"); len(got) != 1 {
+		t.Errorf("Replace touched another session: %q", got)
+	}
+}
+
+func TestStoreReplaceRollsBack(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	s, err := sqlite.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(ctx, "s", conversation(3)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the insert after the delete has run.
+	_, err = db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON gochain_messages
+		WHEN NEW.message LIKE '%poison%' BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Replace(ctx, "s", gochain.Message{Role: "system", Content: "poison"}); err == nil {
+		t.Fatal("expected the insert to fail")
+	}
+
+	if got := load(t, s, "s"); len(got) != 3 {
+		t.Errorf("failed Replace left %q, want the 3 original messages", got)
+	}
+}
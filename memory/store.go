@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"time"
+)
+
+// Store is a Memory that persists sessions and can enumerate and expire
+// them.
+type Store interface {
+	gochain.Memory
+	Replacer
+	Sessions(ctx context.Context) ([]string, error)
+	Prune(ctx context.Context) (int, error)
+}
+
+// Replacer is implemented by memories that can swap the whole history of a
+// session in one step, so a failed write never leaves the session empty.
+type Replacer interface {
+	Replace(ctx context.Context, sessionID string, messages ...gochain.Message) error
+}
+
+// replace swaps the history of sessionID, falling back to Clear and Save
+// for memories that are not a Replacer.
+func replace(ctx context.Context, m gochain.Memory, sessionID string, messages ...gochain.Message) error {
+	if r, ok := m.(Replacer); ok {
+		return r.Replace(ctx, sessionID, messages...)
+	}
+
+	if err := m.Clear(ctx, sessionID); err != nil {
+		return err
+	}
+
+	return m.Save(ctx, sessionID, messages...)
+}
+
+type StoreOption func(*StoreOptions)
+
+type StoreOptions struct {
+	// TTL expires a session once its last message is older than it. Zero
+	// keeps sessions forever.
+	TTL time.Duration
+	Now func() time.Time
+}
+
+func NewStoreOptions(opts ...StoreOption) StoreOptions {
+	o := StoreOptions{Now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func WithTTL(ttl time.Duration) StoreOption {
+	return func(o *StoreOptions) {
+		o.TTL = ttl
+	}
+}
+
+func WithClock(now func() time.Time) StoreOption {
+	return func(o *StoreOptions) {
+		o.Now = now
+	}
+}
+
+func (o StoreOptions) Expired(lastUpdate time.Time) bool {
+	return o.TTL > 0 && o.Now().Sub(lastUpdate) > o.TTL
+}
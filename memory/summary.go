@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"strings"
+	"sync"
+)
+
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+const summaryPrompt = `Progressively summarize the conversation below, adding onto the previous summary. Keep names, places, dates and any facts the user may refer back to. Respond with the new summary only.
+
+Previous summary:
+{summary}
+
+New lines of conversation:
+{conversation}
+`
+
+// Summary keeps the last keep messages verbatim and compresses everything
+// older into a single system message once the session grows past
+// maxMessages.
+type Summary struct {
+	llm         gochain.LLM
+	store       gochain.Memory
+	maxMessages int
+	keep        int
+	mu          sync.Mutex
+}
+
+func NewSummary(llm gochain.LLM, maxMessages int, opts ...Option) *Summary {
+	o := newOptions(opts)
+
+	return &Summary{
+		llm:         llm,
+		store:       o.store,
+		maxMessages: maxMessages,
+		keep:        maxMessages / 2,
+	}
+}
+
+func (s *Summary) Load(ctx context.Context, sessionID string) ([]gochain.Message, error) {
+	return s.store.Load(ctx, sessionID)
+}
+
+func (s *Summary) Save(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.store.Save(ctx, sessionID, messages...); err != nil {
+		return err
+	}
+
+	history, err := s.store.Load(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	var summary string
+	if len(history) > 0 && isSummary(history[0]) {
+		summary = strings.TrimPrefix(history[0].Content, summaryPrefix)
+		history = history[1:]
+	}
+
+	if len(history) <= s.maxMessages {
+		return nil
+	}
+
+	old, recent := history[:len(history)-s.keep], history[len(history)-s.keep:]
+	summary, err = s.summarize(ctx, summary, old)
+	if err != nil {
+		return err
+	}
+
+	compacted := append([]gochain.Message{{Role: "system", Content: summaryPrefix + summary}}, recent...)
+
+	return replace(ctx, s.store, sessionID, compacted...)
+}
+
+func (s *Summary) Clear(ctx context.Context, sessionID string) error {
+	return s.store.Clear(ctx, sessionID)
+}
+
+func (s *Summary) summarize(ctx context.Context, summary string, messages []gochain.Message) (string, error) {
+	var conversation strings.Builder
+	for _, m := range messages {
+		conversation.WriteString(m.Role + ": " + m.Text() + "\n")
+	}
+
+	content := strings.NewReplacer("{summary}", summary, "{conversation}", conversation.String()).Replace(summaryPrompt)
+	response, err := s.llm.Chat(ctx, []gochain.Message{{Role: "user", Content: content}})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response), nil
+}
+
+func isSummary(m gochain.Message) bool {
+	return m.Role == "system" && strings.HasPrefix(m.Content, summaryPrefix)
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/tokenizer"
+)
+
+// TokenWindow returns the most recent messages of a session that fit in
+// maxTokens.
+type TokenWindow struct {
+	store     gochain.Memory
+	maxTokens int
+	tokenizer gochain.Tokenizer
+}
+
+// NewTokenWindow measures messages with t, falling back to
+// tokenizer.Heuristic when it is nil.
+func NewTokenWindow(maxTokens int, t gochain.Tokenizer, opts ...Option) *TokenWindow {
+	o := newOptions(opts)
+
+	if t == nil {
+		t = tokenizer.NewHeuristic()
+	}
+
+	return &TokenWindow{store: o.store, maxTokens: maxTokens, tokenizer: t}
+}
+
+func (w *TokenWindow) Load(ctx context.Context, sessionID string) ([]gochain.Message, error) {
+	messages, err := w.store.Load(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	start := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		total += gochain.CountMessage(w.tokenizer, messages[i])
+		if total > w.maxTokens {
+			break
+		}
+
+		start = i
+	}
+
+	return messages[start:], nil
+}
+
+func (w *TokenWindow) Save(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	return w.store.Save(ctx, sessionID, messages...)
+}
+
+func (w *TokenWindow) Clear(ctx context.Context, sessionID string) error {
+	return w.store.Clear(ctx, sessionID)
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+)
+
+// Window returns only the last size messages of a session.
+type Window struct {
+	store gochain.Memory
+	size  int
+}
+
+func NewWindow(size int, opts ...Option) *Window {
+	o := newOptions(opts)
+
+	return &Window{store: o.store, size: size}
+}
+
+func (w *Window) Load(ctx context.Context, sessionID string) ([]gochain.Message, error) {
+	messages, err := w.store.Load(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.size >= 0 && len(messages) > w.size {
+		messages = messages[len(messages)-w.size:]
+	}
+
+	return messages, nil
+}
+
+func (w *Window) Save(ctx context.Context, sessionID string, messages ...gochain.Message) error {
+	return w.store.Save(ctx, sessionID, messages...)
+}
+
+func (w *Window) Clear(ctx context.Context, sessionID string) error {
+	return w.store.Clear(ctx, sessionID)
+}
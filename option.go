@@ -0,0 +1,99 @@
+package gochain
+
+type InvokeOption func(*invocation)
+
+// invocation is the state of a single Invoke or Run call: a snapshot of the
+// Chain settings with the per-call options applied.
+type invocation struct {
+	settings
+
+	sessionID  string
+	history    []Message
+	hasHistory bool
+	llmOptions map[string]interface{}
+	tools      toolFilter
+
+	runID       string
+	parentRunID string
+}
+
+func newInvocation(s settings, opts []InvokeOption) *invocation {
+	inv := &invocation{settings: s, sessionID: DefaultSessionID}
+	for _, opt := range opts {
+		opt(inv)
+	}
+
+	return inv
+}
+
+func WithSessionID(id string) InvokeOption {
+	return func(inv *invocation) {
+		inv.sessionID = id
+	}
+}
+
+// WithHistory uses history as the previous turns of the conversation
+// instead of loading and saving them with the Chain memory.
+func WithHistory(history []Message) InvokeOption {
+	return func(inv *invocation) {
+		inv.history = history
+		inv.hasHistory = true
+	}
+}
+
+func WithMemory(m Memory) InvokeOption {
+	return func(inv *invocation) {
+		inv.memory = m
+	}
+}
+
+// WithLLMOptions passes backend options, such as temperature, to every
+// chat request of the call.
+func WithLLMOptions(options map[string]interface{}) InvokeOption {
+	return func(inv *invocation) {
+		inv.llmOptions = options
+	}
+}
+
+// WithConversationalHandler sets the conversational handler of the call.
+//
+// Deprecated: the output is the result of the fallback call returned by
+// Run.
+func WithConversationalHandler(h ConversationalFunctionHandler) InvokeOption {
+	return func(inv *invocation) {
+		inv.convHandler = h
+	}
+}
+
+func WithMaxConcurrency(n int) InvokeOption {
+	return func(inv *invocation) {
+		inv.maxConcurrency = n
+	}
+}
+
+func WithMaxSteps(n int) InvokeOption {
+	return func(inv *invocation) {
+		inv.maxSteps = n
+	}
+}
+
+// WithAllowedTools offers only the functions matching one of patterns,
+// such as "weather.*", to the model.
+func WithAllowedTools(patterns ...string) InvokeOption {
+	return func(inv *invocation) {
+		inv.tools.allowed = append(inv.tools.allowed, patterns...)
+	}
+}
+
+func WithDisabledTools(patterns ...string) InvokeOption {
+	return func(inv *invocation) {
+		inv.tools.disabled = append(inv.tools.disabled, patterns...)
+	}
+}
+
+// WithAllowedGroups offers only the functions tagged with one of groups.
+func WithAllowedGroups(groups ...string) InvokeOption {
+	return func(inv *invocation) {
+		inv.tools.groups = append(inv.tools.groups, groups...)
+	}
+}
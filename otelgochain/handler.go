@@ -0,0 +1,181 @@
+package otelgochain
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+// Handler is a gochain.CallbackHandler that records a span and metrics
+// for every chain, LLM and tool run. Spans are parented through the run
+// IDs, so LLM and tool spans nest under their chain span, and runs go on
+// with their span in the context, so the spans of instrumented HTTP
+// clients and tool handlers nest under the LLM and tool spans.
+type Handler struct {
+	gochain.BaseCallbackHandler
+
+	*instruments
+
+	mu    sync.Mutex
+	spans map[string]trace.Span
+}
+
+func NewHandler(opts ...Option) (*Handler, error) {
+	inst, err := newInstruments(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{instruments: inst, spans: map[string]trace.Span{}}, nil
+}
+
+func (h *Handler) start(ctx context.Context, info gochain.RunInfo, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) {
+	h.mu.Lock()
+	parent, ok := h.spans[info.ParentRunID]
+	h.mu.Unlock()
+
+	if ok {
+		ctx = trace.ContextWithSpan(ctx, parent)
+	}
+
+	if info.SessionID != "" {
+		attrs = append(attrs, attrConversationID.String(info.SessionID))
+	}
+
+	_, span := h.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+
+	h.mu.Lock()
+	h.spans[info.RunID] = span
+	h.mu.Unlock()
+}
+
+// RunContext returns ctx with the span of the run.
+func (h *Handler) RunContext(ctx context.Context, info gochain.RunInfo) context.Context {
+	h.mu.Lock()
+	span, ok := h.spans[info.RunID]
+	h.mu.Unlock()
+
+	if !ok {
+		return ctx
+	}
+
+	return trace.ContextWithSpan(ctx, span)
+}
+
+func (h *Handler) end(info gochain.RunInfo, err error, attrs ...attribute.KeyValue) {
+	h.mu.Lock()
+	span, ok := h.spans[info.RunID]
+	delete(h.spans, info.RunID)
+	h.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attrErrorType.String(errorType(err)))
+	}
+
+	span.End()
+}
+
+func (h *Handler) record(ctx context.Context, operation string, d time.Duration, err error, attrs ...attribute.KeyValue) {
+	attrs = append(attrs, attrOperationName.String(operation))
+	if err != nil {
+		attrs = append(attrs, attrErrorType.String(errorType(err)))
+		h.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+
+	h.duration.Record(ctx, d.Seconds(), metric.WithAttributes(attrs...))
+}
+
+func (h *Handler) OnChainStart(ctx context.Context, e *gochain.ChainStartEvent) {
+	h.start(ctx, e.RunInfo, operationChain+" gochain", trace.SpanKindInternal,
+		attrOperationName.String(operationChain),
+		attrAgentName.String("gochain"))
+}
+
+func (h *Handler) OnChainEnd(ctx context.Context, e *gochain.ChainEndEvent) {
+	h.end(e.RunInfo, e.Err)
+	h.record(ctx, operationChain, e.Duration, e.Err)
+}
+
+func (h *Handler) OnLLMStart(ctx context.Context, e *gochain.LLMStartEvent) {
+	name := operationChat
+	attrs := []attribute.KeyValue{
+		attrOperationName.String(operationChat),
+		attrSystem.String(e.Provider),
+	}
+
+	if e.Model != "" {
+		name += " " + e.Model
+		attrs = append(attrs, attrRequestModel.String(e.Model))
+	}
+
+	h.start(ctx, e.RunInfo, name, trace.SpanKindClient, attrs...)
+}
+
+func (h *Handler) OnLLMEnd(ctx context.Context, e *gochain.LLMEndEvent) {
+	g := e.Generation
+	h.end(e.RunInfo, nil, usageAttributes(g)...)
+
+	attrs := []attribute.KeyValue{attrSystem.String(g.Provider)}
+	if g.Model != "" {
+		attrs = append(attrs, attrResponseModel.String(g.Model))
+	}
+
+	h.record(ctx, operationChat, e.Duration, nil, attrs...)
+	h.recordTokens(ctx, g, attrs)
+}
+
+func (h *Handler) OnLLMError(ctx context.Context, e *gochain.LLMErrorEvent) {
+	h.end(e.RunInfo, e.Err)
+	h.record(ctx, operationChat, e.Duration, e.Err, attrSystem.String(e.Provider))
+}
+
+func (h *Handler) OnToolStart(ctx context.Context, e *gochain.ToolStartEvent) error {
+	h.start(ctx, e.RunInfo, operationTool+" "+e.Call.Tool, trace.SpanKindInternal,
+		attrOperationName.String(operationTool),
+		attrToolName.String(e.Call.Tool),
+		attrToolCallID.String(e.RunID))
+
+	return nil
+}
+
+func (h *Handler) OnToolEnd(ctx context.Context, e *gochain.ToolEndEvent) {
+	h.end(e.RunInfo, nil)
+	h.toolCalls.Add(ctx, 1, metric.WithAttributes(attrToolName.String(e.Result.Tool)))
+	h.record(ctx, operationTool, e.Duration, nil, attrToolName.String(e.Result.Tool))
+}
+
+func (h *Handler) OnToolError(ctx context.Context, e *gochain.ToolErrorEvent) {
+	h.end(e.RunInfo, e.Result.Err)
+	h.toolCalls.Add(ctx, 1, metric.WithAttributes(attrToolName.String(e.Result.Tool)))
+	h.record(ctx, operationTool, e.Duration, e.Result.Err, attrToolName.String(e.Result.Tool))
+}
+
+func (h *Handler) OnParseError(ctx context.Context, e *gochain.ParseErrorEvent) {
+	h.errors.Add(ctx, 1, metric.WithAttributes(
+		attrOperationName.String(operationChat),
+		attrErrorType.String("parse_error")))
+}
+
+func (i *instruments) recordTokens(ctx context.Context, g *gochain.Generation, attrs []attribute.KeyValue) {
+	if g.Usage.PromptTokens > 0 {
+		i.tokenUsage.Record(ctx, int64(g.Usage.PromptTokens), metric.WithAttributes(
+			append(attrs, attrOperationName.String(operationChat), attrTokenType.String("input"))...))
+	}
+
+	if g.Usage.CompletionTokens > 0 {
+		i.tokenUsage.Record(ctx, int64(g.Usage.CompletionTokens), metric.WithAttributes(
+			append(attrs, attrOperationName.String(operationChat), attrTokenType.String("output"))...))
+	}
+}
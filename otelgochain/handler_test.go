@@ -0,0 +1,350 @@
+package otelgochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/otelgochain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+// telemetry collects the spans and metrics recorded during a test.
+type telemetry struct {
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+}
+
+func newTelemetry() (*telemetry, []otelgochain.Option) {
+	t := &telemetry{spans: tracetest.NewInMemoryExporter(), reader: sdkmetric.NewManualReader()}
+
+	return t, []otelgochain.Option{
+		otelgochain.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(t.spans))),
+		otelgochain.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(t.reader))),
+	}
+}
+
+func (tel *telemetry) span(t *testing.T, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, s := range tel.spans.GetSpans() {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	t.Fatalf("no span %q", name)
+	return tracetest.SpanStub{}
+}
+
+func (tel *telemetry) metric(t *testing.T, name string) metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := tel.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+
+	t.Fatalf("no metric %q", name)
+	return metricdata.Metrics{}
+}
+
+// counter returns the value of the data point of a counter with all the
+// given attributes.
+func (tel *telemetry) counter(t *testing.T, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+
+	sum, ok := tel.metric(t, name).Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s is not an int64 counter", name)
+	}
+
+	var total int64
+	for _, dp := range sum.DataPoints {
+		if hasAttributes(dp.Attributes, attrs) {
+			total += dp.Value
+		}
+	}
+
+	return total
+}
+
+// histogram returns the count and sum of the data points of an int64
+// histogram with all the given attributes.
+func (tel *telemetry) histogram(t *testing.T, name string, attrs ...attribute.KeyValue) (uint64, int64) {
+	t.Helper()
+
+	h, ok := tel.metric(t, name).Data.(metricdata.Histogram[int64])
+	if !ok {
+		t.Fatalf("%s is not an int64 histogram", name)
+	}
+
+	var count uint64
+	var sum int64
+	for _, dp := range h.DataPoints {
+		if hasAttributes(dp.Attributes, attrs) {
+			count += dp.Count
+			sum += dp.Sum
+		}
+	}
+
+	return count, sum
+}
+
+func hasAttributes(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if v, ok := set.Value(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func spanAttribute(s tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestHandlerNestsSpans(t *testing.T) {
+	tel, opts := newTelemetry()
+	handler, err := otelgochain.NewHandler(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := gochaintest.ToolCall("echo", map[string]interface{}{"text": "hi"})
+	llm := gochaintest.NewFakeLLM().Respond(
+		gochaintest.Response{Generation: &gochain.Generation{
+			Content: call.Content,
+			Model:   "fake-1",
+			Usage:   gochain.Usage{PromptTokens: 12, CompletionTokens: 5},
+		}},
+		gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "hi"}),
+	)
+
+	chain := gochain.New(llm)
+	chain.AddCallbackHandler(handler)
+	chain.RegisterConversationalFunction(func(string) {})
+	err = chain.RegisterTool("echo", "Echo the text", nil, func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		return input["text"], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Invoke(context.Background(), "say hi", gochain.WithSessionID("session-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tel.spans.GetSpans()
+	if len(spans) != 5 {
+		t.Fatalf("got %d spans, want chain, two chat and two tool spans", len(spans))
+	}
+
+	root := tel.span(t, "invoke_agent gochain")
+	if root.Parent.IsValid() {
+		t.Error("chain span has a parent")
+	}
+
+	for _, s := range spans {
+		if s.Name == root.Name {
+			continue
+		}
+
+		if s.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("span %q is not a child of the chain span", s.Name)
+		}
+
+		if s.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("span %q is in another trace", s.Name)
+		}
+	}
+
+	chat := tel.span(t, "chat fake")
+	if chat.SpanKind != trace.SpanKindClient {
+		t.Errorf("chat span kind = %v", chat.SpanKind)
+	}
+
+	for key, want := range map[string]attribute.Value{
+		"gen_ai.operation.name":      attribute.StringValue("chat"),
+		"gen_ai.system":              attribute.StringValue("fake"),
+		"gen_ai.request.model":       attribute.StringValue("fake"),
+		"gen_ai.response.model":      attribute.StringValue("fake-1"),
+		"gen_ai.usage.input_tokens":  attribute.IntValue(12),
+		"gen_ai.usage.output_tokens": attribute.IntValue(5),
+		"gen_ai.conversation.id":     attribute.StringValue("session-1"),
+	} {
+		if got := spanAttribute(chat, key); got != want {
+			t.Errorf("chat span %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	tool := tel.span(t, "execute_tool echo")
+	if got := spanAttribute(tool, "gen_ai.tool.name").AsString(); got != "echo" {
+		t.Errorf("tool span gen_ai.tool.name = %q", got)
+	}
+
+	count, sum := tel.histogram(t, "gen_ai.client.token.usage", attribute.String("gen_ai.token.type", "input"))
+	if count != 1 || sum != 12 {
+		t.Errorf("input token usage = %d records summing %d, want 1 of 12", count, sum)
+	}
+
+	count, sum = tel.histogram(t, "gen_ai.client.token.usage", attribute.String("gen_ai.token.type", "output"))
+	if count != 1 || sum != 5 {
+		t.Errorf("output token usage = %d records summing %d, want 1 of 5", count, sum)
+	}
+
+	if got := tel.counter(t, "gochain.tool.calls", attribute.String("gen_ai.tool.name", "echo")); got != 1 {
+		t.Errorf("echo tool calls = %d, want 1", got)
+	}
+}
+
+func TestHandlerRecordsErrors(t *testing.T) {
+	tel, opts := newTelemetry()
+	handler, err := otelgochain.NewHandler(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	llm := gochaintest.NewFakeLLM().Respond(
+		gochaintest.ToolCall("fail", nil),
+		gochaintest.Error(errors.New("model unavailable")),
+	)
+
+	chain := gochain.New(llm)
+	chain.AddCallbackHandler(handler)
+	err = chain.RegisterTool("fail", "Always fails", nil, func(context.Context, map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run fails in the tool, the second in the model.
+	for i := 0; i < 2; i++ {
+		if err := chain.Invoke(context.Background(), "fail please"); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	failed := map[string]int{}
+	for _, s := range tel.spans.GetSpans() {
+		if s.Status.Code == codes.Error {
+			failed[s.Name]++
+		}
+	}
+
+	// Tool failures are results, only the model failure fails the run.
+	for name, want := range map[string]int{"invoke_agent gochain": 1, "chat fake": 1, "execute_tool fail": 1} {
+		if failed[name] != want {
+			t.Errorf("failed %q spans = %d, want %d", name, failed[name], want)
+		}
+	}
+
+	tool := tel.span(t, "execute_tool fail")
+	if got := spanAttribute(tool, "error.type").AsString(); got != "*gochain.ToolExecutionError" {
+		t.Errorf("tool span error.type = %q", got)
+	}
+
+	for operation, want := range map[string]int64{"execute_tool": 1, "chat": 1, "invoke_agent": 1} {
+		if got := tel.counter(t, "gochain.errors", attribute.String("gen_ai.operation.name", operation)); got != want {
+			t.Errorf("%s errors = %d, want %d", operation, got, want)
+		}
+	}
+}
+
+// tracedLLM records a span for every request, like an LLM behind an
+// instrumented HTTP client.
+type tracedLLM struct {
+	llm    *gochaintest.FakeLLM
+	tracer trace.Tracer
+}
+
+func (l tracedLLM) Name() string {
+	return l.llm.Name()
+}
+
+func (l tracedLLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	_, span := l.tracer.Start(ctx, "POST /api/chat")
+	defer span.End()
+
+	return l.llm.Chat(ctx, messages, options...)
+}
+
+func TestHandlerPropagatesSpans(t *testing.T) {
+	tel, opts := newTelemetry()
+	handler, err := otelgochain.NewHandler(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(tel.spans)).Tracer("test")
+	llm := tracedLLM{tracer: tracer, llm: gochaintest.NewFakeLLM().Respond(
+		gochaintest.ToolCall("lookup", nil),
+		gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "found"}),
+	)}
+
+	chain := gochain.New(llm)
+	chain.AddCallbackHandler(handler)
+	err = chain.RegisterTool("lookup", "Look up", nil, func(ctx context.Context, _ map[string]interface{}) (interface{}, error) {
+		_, span := tracer.Start(ctx, "SELECT")
+		defer span.End()
+
+		return "row", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Invoke(context.Background(), "look it up"); err != nil {
+		t.Fatal(err)
+	}
+
+	chats := map[trace.SpanID]bool{}
+	for _, s := range tel.spans.GetSpans() {
+		if s.Name == "chat" {
+			chats[s.SpanContext.SpanID()] = true
+		}
+	}
+
+	requests := 0
+	for _, s := range tel.spans.GetSpans() {
+		if s.Name != "POST /api/chat" {
+			continue
+		}
+
+		requests++
+		if !chats[s.Parent.SpanID()] {
+			t.Errorf("request span is not a child of a chat span")
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("got %d request spans, want 2", requests)
+	}
+
+	if query, tool := tel.span(t, "SELECT"), tel.span(t, "execute_tool lookup"); query.Parent.SpanID() != tool.SpanContext.SpanID() {
+		t.Error("span of the tool handler is not a child of the tool span")
+	}
+}
@@ -0,0 +1,159 @@
+// Package otelgochain instruments gochain with OpenTelemetry traces and
+// metrics following the GenAI semantic conventions.
+package otelgochain
+
+import (
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ryanbekhen/gochain/otelgochain"
+
+const (
+	operationChat       = "chat"
+	operationEmbeddings = "embeddings"
+	operationTool       = "execute_tool"
+	operationChain      = "invoke_agent"
+)
+
+// Attribute keys from the GenAI semantic conventions.
+const (
+	attrOperationName  = attribute.Key("gen_ai.operation.name")
+	attrSystem         = attribute.Key("gen_ai.system")
+	attrRequestModel   = attribute.Key("gen_ai.request.model")
+	attrResponseModel  = attribute.Key("gen_ai.response.model")
+	attrFinishReasons  = attribute.Key("gen_ai.response.finish_reasons")
+	attrInputTokens    = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens   = attribute.Key("gen_ai.usage.output_tokens")
+	attrTokenType      = attribute.Key("gen_ai.token.type")
+	attrToolName       = attribute.Key("gen_ai.tool.name")
+	attrToolCallID     = attribute.Key("gen_ai.tool.call.id")
+	attrAgentName      = attribute.Key("gen_ai.agent.name")
+	attrConversationID = attribute.Key("gen_ai.conversation.id")
+	attrErrorType      = attribute.Key("error.type")
+)
+
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// instruments holds the tracer and metric instruments shared by the
+// callback handler and the wrappers.
+type instruments struct {
+	tracer     trace.Tracer
+	duration   metric.Float64Histogram
+	tokenUsage metric.Int64Histogram
+	errors     metric.Int64Counter
+	toolCalls  metric.Int64Counter
+}
+
+func newInstruments(opts []Option) (*instruments, error) {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	meter := c.meterProvider.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithDescription("GenAI operation duration."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenUsage, err := meter.Int64Histogram("gen_ai.client.token.usage",
+		metric.WithDescription("Measures number of input and output tokens used."),
+		metric.WithUnit("{token}"),
+		metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304))
+	if err != nil {
+		return nil, err
+	}
+
+	errors, err := meter.Int64Counter("gochain.errors",
+		metric.WithDescription("Number of failed chain, LLM, embedding and tool operations."),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return nil, err
+	}
+
+	toolCalls, err := meter.Int64Counter("gochain.tool.calls",
+		metric.WithDescription("Number of tool calls made by chains."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &instruments{
+		tracer:     c.tracerProvider.Tracer(instrumentationName),
+		duration:   duration,
+		tokenUsage: tokenUsage,
+		errors:     errors,
+		toolCalls:  toolCalls,
+	}, nil
+}
+
+func usageAttributes(g *gochain.Generation) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if g.Model != "" {
+		attrs = append(attrs, attrResponseModel.String(g.Model))
+	}
+
+	if g.FinishReason != "" {
+		attrs = append(attrs, attrFinishReasons.StringSlice([]string{g.FinishReason}))
+	}
+
+	if g.Usage.PromptTokens > 0 || g.Usage.CompletionTokens > 0 {
+		attrs = append(attrs,
+			attrInputTokens.Int(g.Usage.PromptTokens),
+			attrOutputTokens.Int(g.Usage.CompletionTokens))
+	}
+
+	return attrs
+}
+
+// modelOf returns the model of backends that expose one, such as the
+// Ollama and Cloudflare Workers AI clients.
+func modelOf(v interface{}) string {
+	if m, ok := v.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+func embeddingModelOf(v interface{}) string {
+	if m, ok := v.(interface{ EmbeddingModel() string }); ok {
+		return m.EmbeddingModel()
+	}
+
+	return ""
+}
+
+// errorType is the low cardinality error.type value for err.
+func errorType(err error) string {
+	return fmt.Sprintf("%T", err)
+}
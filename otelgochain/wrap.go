@@ -0,0 +1,119 @@
+package otelgochain
+
+import (
+	"context"
+	"github.com/ryanbekhen/gochain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// LLM traces calls made directly on a gochain.LLM, outside of a Chain.
+type LLM struct {
+	gochain.LLM
+	*instruments
+}
+
+func WrapLLM(llm gochain.LLM, opts ...Option) (*LLM, error) {
+	inst, err := newInstruments(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLM{LLM: llm, instruments: inst}, nil
+}
+
+// JSONMode forwards to the wrapped LLM.
+func (l *LLM) JSONMode(options map[string]interface{}) {
+	gochain.EnableJSONMode(l.LLM, options)
+}
+
+func (l *LLM) Chat(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (string, error) {
+	g, err := l.ChatGeneration(ctx, messages, options...)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Content, nil
+}
+
+func (l *LLM) ChatGeneration(ctx context.Context, messages []gochain.Message, options ...map[string]interface{}) (*gochain.Generation, error) {
+	model := modelOf(l.LLM)
+	attrs := []attribute.KeyValue{attrSystem.String(l.LLM.Name())}
+
+	name := operationChat
+	if model != "" {
+		name += " " + model
+		attrs = append(attrs, attrRequestModel.String(model))
+	}
+
+	ctx, span := l.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attrOperationName.String(operationChat))...))
+	defer span.End()
+
+	start := time.Now()
+	g, err := gochain.Generate(ctx, l.LLM, messages, options...)
+	l.finish(ctx, span, operationChat, start, err, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(usageAttributes(g)...)
+	l.recordTokens(ctx, g, attrs)
+
+	return g, nil
+}
+
+// Embedder traces embedding calls.
+type Embedder struct {
+	gochain.Embedder
+	*instruments
+}
+
+func WrapEmbedder(embedder gochain.Embedder, opts ...Option) (*Embedder, error) {
+	inst, err := newInstruments(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Embedder{Embedder: embedder, instruments: inst}, nil
+}
+
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	var attrs []attribute.KeyValue
+	if llm, ok := e.Embedder.(gochain.LLM); ok {
+		attrs = append(attrs, attrSystem.String(llm.Name()))
+	}
+
+	name := operationEmbeddings
+	if model := embeddingModelOf(e.Embedder); model != "" {
+		name += " " + model
+		attrs = append(attrs, attrRequestModel.String(model))
+	}
+
+	ctx, span := e.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attrOperationName.String(operationEmbeddings))...))
+	defer span.End()
+
+	start := time.Now()
+	vectors, err := e.Embedder.Embed(ctx, texts)
+	e.finish(ctx, span, operationEmbeddings, start, err, attrs)
+
+	return vectors, err
+}
+
+func (i *instruments) finish(ctx context.Context, span trace.Span, operation string, start time.Time, err error, attrs []attribute.KeyValue) {
+	attrs = append(attrs, attrOperationName.String(operation))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attrErrorType.String(errorType(err)))
+
+		attrs = append(attrs, attrErrorType.String(errorType(err)))
+		i.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+
+	i.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+}
@@ -0,0 +1,131 @@
+package otelgochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/otelgochain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestWrapLLM(t *testing.T) {
+	tel, opts := newTelemetry()
+
+	fake := gochaintest.NewFakeLLM()
+	fake.SetModel("fake-1")
+	fake.Respond(
+		gochaintest.Response{Generation: &gochain.Generation{
+			Content:      "hello",
+			Model:        "fake-1",
+			FinishReason: "stop",
+			Usage:        gochain.Usage{PromptTokens: 7, CompletionTokens: 3},
+		}},
+		gochaintest.Error(errors.New("model unavailable")),
+	)
+
+	llm, err := otelgochain.WrapLLM(fake, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []gochain.Message{{Role: "user", Content: "hi"}}
+	if _, err := llm.Chat(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := llm.Chat(context.Background(), messages); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	spans := tel.spans.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	ok, failed := spans[0], spans[1]
+	if ok.Name != "chat fake-1" || ok.SpanKind != trace.SpanKindClient {
+		t.Errorf("span = %q of kind %v", ok.Name, ok.SpanKind)
+	}
+
+	for key, want := range map[string]attribute.Value{
+		"gen_ai.system":              attribute.StringValue("fake"),
+		"gen_ai.request.model":       attribute.StringValue("fake-1"),
+		"gen_ai.response.model":      attribute.StringValue("fake-1"),
+		"gen_ai.usage.input_tokens":  attribute.IntValue(7),
+		"gen_ai.usage.output_tokens": attribute.IntValue(3),
+	} {
+		if got := spanAttribute(ok, key); got != want {
+			t.Errorf("span %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	if got := spanAttribute(ok, "gen_ai.response.finish_reasons").AsStringSlice(); len(got) != 1 || got[0] != "stop" {
+		t.Errorf("finish reasons = %v", got)
+	}
+
+	if failed.Status.Code != codes.Error {
+		t.Errorf("failed span status = %v", failed.Status.Code)
+	}
+
+	if count, sum := tel.histogram(t, "gen_ai.client.token.usage", attribute.String("gen_ai.token.type", "input")); count != 1 || sum != 7 {
+		t.Errorf("input token usage = %d records summing %d, want 1 of 7", count, sum)
+	}
+
+	if got := tel.counter(t, "gochain.errors", attribute.String("gen_ai.operation.name", "chat")); got != 1 {
+		t.Errorf("chat errors = %d, want 1", got)
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(context.Context, []string) ([][]float64, error) {
+	return nil, errors.New("embedding unavailable")
+}
+
+func TestWrapEmbedder(t *testing.T) {
+	tel, opts := newTelemetry()
+
+	embedder, err := otelgochain.WrapEmbedder(gochaintest.NewFakeLLM(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vectors) != 2 {
+		t.Fatalf("Embed() = %d vectors, %v", len(vectors), err)
+	}
+
+	failing, err := otelgochain.WrapEmbedder(failingEmbedder{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := failing.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	spans := tel.spans.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	if got := spanAttribute(spans[0], "gen_ai.operation.name").AsString(); got != "embeddings" {
+		t.Errorf("operation = %q", got)
+	}
+
+	if got := spanAttribute(spans[0], "gen_ai.system").AsString(); got != "fake" {
+		t.Errorf("system = %q", got)
+	}
+
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("failed span status = %v", spans[1].Status.Code)
+	}
+
+	if got := tel.counter(t, "gochain.errors", attribute.String("gen_ai.operation.name", "embeddings")); got != 1 {
+		t.Errorf("embedding errors = %d, want 1", got)
+	}
+}
@@ -0,0 +1,30 @@
+package gochain
+
+import (
+	"github.com/ryanbekhen/gochain/internal/prompt"
+	"strings"
+	"text/template"
+)
+
+// PromptFormatter renders a prompt from named values. The prompt package
+// templates implement it.
+type PromptFormatter interface {
+	Format(values map[string]interface{}) (string, error)
+}
+
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+func (f templateFormatter) Format(values map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	if err := f.tmpl.Execute(&sb, values); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+var defaultToolSelectionPrompt = templateFormatter{
+	tmpl: template.Must(template.New("toolSelection").Option("missingkey=error").Parse(prompt.FunctionsToCall)),
+}
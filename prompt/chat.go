@@ -0,0 +1,125 @@
+package prompt
+
+import (
+	"github.com/ryanbekhen/gochain"
+)
+
+// MessageTemplate renders one chat message. A template with a placeholder
+// instead expands to the []gochain.Message passed under that name.
+type MessageTemplate struct {
+	Role        string
+	Template    *PromptTemplate
+	Placeholder string
+}
+
+func SystemMessage(text string) MessageTemplate {
+	return MessageTemplate{Role: "system", Template: MustPromptTemplate(text)}
+}
+
+func UserMessage(text string) MessageTemplate {
+	return MessageTemplate{Role: "user", Template: MustPromptTemplate(text)}
+}
+
+func AssistantMessage(text string) MessageTemplate {
+	return MessageTemplate{Role: "assistant", Template: MustPromptTemplate(text)}
+}
+
+// MessagesPlaceholder inserts a list of messages, such as the conversation
+// history, at its position.
+func MessagesPlaceholder(name string) MessageTemplate {
+	return MessageTemplate{Placeholder: name}
+}
+
+type ChatPromptTemplate struct {
+	messages []MessageTemplate
+	partials map[string]interface{}
+}
+
+func NewChatPromptTemplate(messages ...MessageTemplate) *ChatPromptTemplate {
+	return &ChatPromptTemplate{messages: messages}
+}
+
+// Variables returns every variable required by the message templates and
+// placeholders that was not applied with Partial.
+func (c *ChatPromptTemplate) Variables() []Variable {
+	seen := map[string]bool{}
+	var variables []Variable
+	add := func(v Variable) {
+		if _, ok := c.partials[v.Name]; ok || seen[v.Name] {
+			return
+		}
+
+		seen[v.Name] = true
+		variables = append(variables, v)
+	}
+
+	for _, m := range c.messages {
+		if m.Placeholder != "" {
+			add(Messages(m.Placeholder))
+			continue
+		}
+
+		for _, v := range m.Template.Variables() {
+			add(v)
+		}
+	}
+
+	return variables
+}
+
+func (c *ChatPromptTemplate) Partial(values map[string]interface{}) *ChatPromptTemplate {
+	partials := make(map[string]interface{}, len(c.partials)+len(values))
+	for k, v := range c.partials {
+		partials[k] = v
+	}
+
+	for k, v := range values {
+		partials[k] = v
+	}
+
+	return &ChatPromptTemplate{messages: c.messages, partials: partials}
+}
+
+func (c *ChatPromptTemplate) FormatMessages(values map[string]interface{}) ([]gochain.Message, error) {
+	merged := make(map[string]interface{}, len(c.partials)+len(values))
+	for k, v := range c.partials {
+		merged[k] = v
+	}
+
+	for k, v := range values {
+		merged[k] = v
+	}
+
+	var missing []string
+	for _, v := range c.Variables() {
+		if _, ok := merged[v.Name]; !ok {
+			missing = append(missing, v.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingVariablesError{Names: missing}
+	}
+
+	var messages []gochain.Message
+	for _, m := range c.messages {
+		if m.Placeholder != "" {
+			v := Messages(m.Placeholder)
+			if err := v.check(merged[m.Placeholder]); err != nil {
+				return nil, err
+			}
+
+			messages = append(messages, merged[m.Placeholder].([]gochain.Message)...)
+			continue
+		}
+
+		content, err := m.Template.Format(merged)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, gochain.Message{Role: m.Role, Content: content})
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,59 @@
+package prompt_test
+
+import (
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/prompt"
+	"reflect"
+	"testing"
+)
+
+func TestChatPromptTemplate(t *testing.T) {
+	chat := prompt.NewChatPromptTemplate(
+		prompt.SystemMessage("You are {{.persona}}."),
+		prompt.MessagesPlaceholder("history"),
+		prompt.UserMessage("{{.question}}"),
+	)
+
+	if got := names(chat.Variables()); !reflect.DeepEqual(got, []string{"persona", "history", "question"}) {
+		t.Errorf("Variables() = %q", got)
+	}
+
+	history := []gochain.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	messages, err := chat.Partial(map[string]interface{}{"persona": "a pirate"}).FormatMessages(map[string]interface{}{
+		"history":  history,
+		"question": "where is the gold?",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []gochain.Message{
+		{Role: "system", Content: "You are a pirate."},
+		history[0],
+		history[1],
+		{Role: "user", Content: "where is the gold?"},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("FormatMessages() = %+v", messages)
+	}
+}
+
+func TestChatPromptTemplateErrors(t *testing.T) {
+	chat := prompt.NewChatPromptTemplate(
+		prompt.MessagesPlaceholder("history"),
+		prompt.UserMessage("{{.question}}"),
+	)
+
+	_, err := chat.FormatMessages(map[string]interface{}{"history": []gochain.Message{}})
+
+	var missing *prompt.MissingVariablesError
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Names, []string{"question"}) {
+		t.Errorf("err = %v, want question missing", err)
+	}
+
+	_, err = chat.FormatMessages(map[string]interface{}{"history": "not messages", "question": "hi"})
+	if !errors.Is(err, prompt.ErrInvalidVariable) {
+		t.Errorf("err = %v, want ErrInvalidVariable", err)
+	}
+}
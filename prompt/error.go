@@ -0,0 +1,38 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrMissingVariables = errors.New("missing prompt variables")
+	ErrInvalidVariable  = errors.New("invalid prompt variable")
+)
+
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return "prompt: missing variables: " + strings.Join(e.Names, ", ")
+}
+
+func (e *MissingVariablesError) Is(target error) bool {
+	return target == ErrMissingVariables
+}
+
+type VariableTypeError struct {
+	Name string
+	Want VariableType
+	Got  string
+}
+
+func (e *VariableTypeError) Error() string {
+	return fmt.Sprintf("prompt: variable %q must be %s, got %s", e.Name, e.Want, e.Got)
+}
+
+func (e *VariableTypeError) Is(target error) bool {
+	return target == ErrInvalidVariable
+}
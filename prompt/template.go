@@ -0,0 +1,243 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// PromptTemplate is a text/template prompt with declared input variables.
+type PromptTemplate struct {
+	tmpl      *template.Template
+	variables []Variable
+	// inferred is set when the variables were read from the template, so
+	// they are read again once partials are added.
+	inferred bool
+	partials map[string]interface{}
+}
+
+// NewPromptTemplate parses text as a text/template. Without explicit
+// variables every top-level field referenced by the template, such as
+// {{.question}} or {{$.question}}, becomes a required variable of any type.
+func NewPromptTemplate(text string, variables ...Variable) (*PromptTemplate, error) {
+	return newPromptTemplate("prompt", text, variables)
+}
+
+func MustPromptTemplate(text string, variables ...Variable) *PromptTemplate {
+	p, err := NewPromptTemplate(text, variables...)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func FromFile(path string, variables ...Variable) (*PromptTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPromptTemplate(path, string(data), variables)
+}
+
+func newPromptTemplate(name, text string, variables []Variable) (*PromptTemplate, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PromptTemplate{tmpl: tmpl, variables: variables}
+	if len(p.variables) == 0 {
+		p.inferred = true
+		p.variables = inferVariables(tmpl)
+	}
+
+	return p, nil
+}
+
+func inferVariables(tmpl *template.Template) []Variable {
+	var variables []Variable
+	for _, name := range referencedFields(tmpl) {
+		variables = append(variables, Any(name))
+	}
+
+	return variables
+}
+
+// Variables returns the variables still required by Format, that is the
+// declared ones that were not applied with Partial.
+func (p *PromptTemplate) Variables() []Variable {
+	var variables []Variable
+	for _, v := range p.variables {
+		if _, ok := p.partials[v.Name]; !ok {
+			variables = append(variables, v)
+		}
+	}
+
+	return variables
+}
+
+// Partial returns a copy of the template with some variables already set.
+func (p *PromptTemplate) Partial(values map[string]interface{}) *PromptTemplate {
+	partials := make(map[string]interface{}, len(p.partials)+len(values))
+	for k, v := range p.partials {
+		partials[k] = v
+	}
+
+	for k, v := range values {
+		partials[k] = v
+	}
+
+	return &PromptTemplate{tmpl: p.tmpl, variables: p.variables, inferred: p.inferred, partials: partials}
+}
+
+// WithPartial returns a copy of the template that defines a named sub
+// template callable with {{template "name" .}}. The fields the sub template
+// reads become required variables too, unless variables were declared.
+func (p *PromptTemplate) WithPartial(name, text string) (*PromptTemplate, error) {
+	tmpl, err := p.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tmpl.New(name).Parse(text); err != nil {
+		return nil, err
+	}
+
+	variables := p.variables
+	if p.inferred {
+		variables = inferVariables(tmpl)
+	}
+
+	return &PromptTemplate{tmpl: tmpl, variables: variables, inferred: p.inferred, partials: p.partials}, nil
+}
+
+func (p *PromptTemplate) WithPartialFile(name, path string) (*PromptTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.WithPartial(name, string(data))
+}
+
+func (p *PromptTemplate) Format(values map[string]interface{}) (string, error) {
+	merged, err := p.merge(values)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, merged); err != nil {
+		return "", fmt.Errorf("prompt: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+func (p *PromptTemplate) merge(values map[string]interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(p.partials)+len(values))
+	for k, v := range p.partials {
+		merged[k] = v
+	}
+
+	for k, v := range values {
+		merged[k] = v
+	}
+
+	var missing []string
+	for _, v := range p.variables {
+		value, ok := merged[v.Name]
+		if !ok {
+			missing = append(missing, v.Name)
+			continue
+		}
+
+		if err := v.check(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingVariablesError{Names: missing}
+	}
+
+	return merged, nil
+}
+
+// referencedFields lists the top-level fields read from the template data,
+// either from dot or from $. Fields of dot inside range and with blocks are
+// ignored, since dot changes there.
+func referencedFields(tmpl *template.Template) []string {
+	seen := map[string]bool{}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walkFields(t.Tree.Root, seen, false)
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// walkFields records the fields read in node. nested is set inside range
+// and with blocks, where only fields of $ are top-level.
+func walkFields(node parse.Node, seen map[string]bool, nested bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			walkFields(c, seen, nested)
+		}
+	case *parse.ActionNode:
+		walkFields(n.Pipe, seen, nested)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Cmds {
+			walkFields(c, seen, nested)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkFields(arg, seen, nested)
+		}
+	case *parse.FieldNode:
+		if !nested {
+			seen[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			seen[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		walkFields(n.Node, seen, nested)
+	case *parse.IfNode:
+		walkFields(n.Pipe, seen, nested)
+		walkFields(n.List, seen, nested)
+		walkFields(n.ElseList, seen, nested)
+	case *parse.RangeNode:
+		walkFields(n.Pipe, seen, nested)
+		walkFields(n.List, seen, true)
+		walkFields(n.ElseList, seen, nested)
+	case *parse.WithNode:
+		walkFields(n.Pipe, seen, nested)
+		walkFields(n.List, seen, true)
+		walkFields(n.ElseList, seen, nested)
+	case *parse.TemplateNode:
+		walkFields(n.Pipe, seen, nested)
+	}
+}
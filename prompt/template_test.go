@@ -0,0 +1,187 @@
+package prompt_test
+
+import (
+	"errors"
+	"github.com/ryanbekhen/gochain/prompt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func names(variables []prompt.Variable) []string {
+	n := make([]string, len(variables))
+	for i, v := range variables {
+		n[i] = v.Name
+	}
+
+	return n
+}
+
+func TestInferredVariables(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want []string
+	}{
+		{"{{.question}}", []string{"question"}},
+		{"{{.b}} {{.a}} {{.b}}", []string{"a", "b"}},
+		{"{{.user.name}}", []string{"user"}},
+		{"{{$.question}}", []string{"question"}},
+		{"{{if .verbose}}{{.detail}}{{else}}{{.summary}}{{end}}", []string{"detail", "summary", "verbose"}},
+		// Dot is an item inside range and with, but $ stays the data.
+		{"{{range .items}}{{.name}} for {{$.user}}{{end}}", []string{"items", "user"}},
+		{"{{with .profile}}{{.name}}{{else}}{{.fallback}}{{end}}", []string{"fallback", "profile"}},
+		{"{{$name := .first}}{{$name}}", []string{"first"}},
+		{"{{len .items | printf \"%d\"}}", []string{"items"}},
+		{"no variables", []string{}},
+	} {
+		p, err := prompt.NewPromptTemplate(tt.text)
+		if err != nil {
+			t.Fatalf("NewPromptTemplate(%q): %v", tt.text, err)
+		}
+
+		if got := names(p.Variables()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("variables of %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	p := prompt.MustPromptTemplate("Answer {{.question}} for {{$.user}}.")
+
+	got, err := p.Format(map[string]interface{}{"question": "why", "user": "ana"})
+	if err != nil || got != "Answer why for ana." {
+		t.Errorf("Format() = %q, %v", got, err)
+	}
+
+	_, err = p.Format(map[string]interface{}{"question": "why"})
+
+	var missing *prompt.MissingVariablesError
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Names, []string{"user"}) {
+		t.Errorf("err = %v, want user missing", err)
+	}
+
+	if !errors.Is(err, prompt.ErrMissingVariables) {
+		t.Errorf("err = %v does not match ErrMissingVariables", err)
+	}
+}
+
+func TestDeclaredVariables(t *testing.T) {
+	p := prompt.MustPromptTemplate("{{.name}} is {{.age}}", prompt.String("name"), prompt.Int("age"))
+
+	if got, err := p.Format(map[string]interface{}{"name": "Ana", "age": 30}); err != nil || got != "Ana is 30" {
+		t.Errorf("Format() = %q, %v", got, err)
+	}
+
+	for _, values := range []map[string]interface{}{
+		{"name": 1, "age": 30},
+		{"name": "Ana", "age": "thirty"},
+	} {
+		if _, err := p.Format(values); !errors.Is(err, prompt.ErrInvalidVariable) {
+			t.Errorf("Format(%v) err = %v, want ErrInvalidVariable", values, err)
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	p := prompt.MustPromptTemplate("{{.persona}}: {{.question}}")
+	partial := p.Partial(map[string]interface{}{"persona": "Pirate"})
+
+	if got := names(partial.Variables()); !reflect.DeepEqual(got, []string{"question"}) {
+		t.Errorf("Variables() = %q, want question", got)
+	}
+
+	if got := names(p.Variables()); len(got) != 2 {
+		t.Errorf("Partial changed the original template, variables = %q", got)
+	}
+
+	got, err := partial.Format(map[string]interface{}{"question": "where is the gold?"})
+	if err != nil || got != "Pirate: where is the gold?" {
+		t.Errorf("Format() = %q, %v", got, err)
+	}
+
+	// Values passed to Format win over partials.
+	got, _ = partial.Format(map[string]interface{}{"persona": "Robot", "question": "hi"})
+	if got != "Robot: hi" {
+		t.Errorf("Format() = %q", got)
+	}
+}
+
+func TestWithPartialInfersVariables(t *testing.T) {
+	p := prompt.MustPromptTemplate(`{{template "rules" .}}{{.question}}`)
+
+	withRules, err := p.WithPartial("rules", "Answer in {{.language}}. ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(withRules.Variables()); !reflect.DeepEqual(got, []string{"language", "question"}) {
+		t.Errorf("Variables() = %q, want the variables of the sub template too", got)
+	}
+
+	if _, err := withRules.Format(map[string]interface{}{"question": "hi"}); !errors.Is(err, prompt.ErrMissingVariables) {
+		t.Errorf("err = %v, want ErrMissingVariables for language", err)
+	}
+
+	got, err := withRules.Format(map[string]interface{}{"question": "hi", "language": "French"})
+	if err != nil || got != "Answer in French. hi" {
+		t.Errorf("Format() = %q, %v", got, err)
+	}
+
+	// Partial values still count once the sub template is added.
+	partial, err := p.Partial(map[string]interface{}{"language": "French"}).WithPartial("rules", "Answer in {{.language}}. ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(partial.Variables()); !reflect.DeepEqual(got, []string{"question"}) {
+		t.Errorf("Variables() = %q, want question", got)
+	}
+}
+
+func TestWithPartialKeepsDeclaredVariables(t *testing.T) {
+	p := prompt.MustPromptTemplate(`{{template "rules" .}}{{.question}}`, prompt.String("question"))
+
+	withRules, err := p.WithPartial("rules", "{{if .strict}}Be brief. {{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(withRules.Variables()); !reflect.DeepEqual(got, []string{"question"}) {
+		t.Errorf("Variables() = %q, want the declared ones only", got)
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prompt.tmpl")
+	if err := os.WriteFile(path, []byte(`{{template "greeting" .}} {{.name}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	partial := filepath.Join(dir, "greeting.tmpl")
+	if err := os.WriteFile(partial, []byte("{{.salutation}},"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := prompt.FromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = p.WithPartialFile("greeting", partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.Format(map[string]interface{}{"salutation": "Hello", "name": "Ana"})
+	if err != nil || got != "Hello, Ana" {
+		t.Errorf("Format() = %q, %v", got, err)
+	}
+}
+
+func TestToolSelection(t *testing.T) {
+	if got := names(prompt.ToolSelection().Variables()); !reflect.DeepEqual(got, []string{"functions"}) {
+		t.Errorf("Variables() = %q", got)
+	}
+}
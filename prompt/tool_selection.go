@@ -0,0 +1,9 @@
+package prompt
+
+import internalprompt "github.com/ryanbekhen/gochain/internal/prompt"
+
+// ToolSelection returns the default prompt gochain.Chain uses to make the
+// model pick a tool, as a starting point for custom ones.
+func ToolSelection() *PromptTemplate {
+	return MustPromptTemplate(internalprompt.FunctionsToCall, String("functions"))
+}
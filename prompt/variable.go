@@ -0,0 +1,85 @@
+package prompt
+
+import (
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"reflect"
+)
+
+type VariableType string
+
+const (
+	TypeAny      VariableType = "any"
+	TypeString   VariableType = "string"
+	TypeInt      VariableType = "int"
+	TypeFloat    VariableType = "float"
+	TypeBool     VariableType = "bool"
+	TypeMessages VariableType = "messages"
+)
+
+type Variable struct {
+	Name string
+	Type VariableType
+}
+
+func String(name string) Variable {
+	return Variable{Name: name, Type: TypeString}
+}
+
+func Int(name string) Variable {
+	return Variable{Name: name, Type: TypeInt}
+}
+
+func Float(name string) Variable {
+	return Variable{Name: name, Type: TypeFloat}
+}
+
+func Bool(name string) Variable {
+	return Variable{Name: name, Type: TypeBool}
+}
+
+func Any(name string) Variable {
+	return Variable{Name: name, Type: TypeAny}
+}
+
+func Messages(name string) Variable {
+	return Variable{Name: name, Type: TypeMessages}
+}
+
+func (v Variable) check(value interface{}) error {
+	if v.Type == TypeAny || v.Type == "" {
+		return nil
+	}
+
+	ok := false
+	switch v.Type {
+	case TypeString:
+		switch value.(type) {
+		case string, fmt.Stringer:
+			ok = true
+		}
+	case TypeInt:
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			ok = true
+		}
+	case TypeFloat:
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ok = true
+		}
+	case TypeBool:
+		_, ok = value.(bool)
+	case TypeMessages:
+		_, ok = value.([]gochain.Message)
+	default:
+		return fmt.Errorf("prompt: variable %q has unknown type %q", v.Name, v.Type)
+	}
+
+	if !ok {
+		return &VariableTypeError{Name: v.Name, Want: v.Type, Got: fmt.Sprintf("%T", value)}
+	}
+
+	return nil
+}
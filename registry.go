@@ -0,0 +1,267 @@
+package gochain
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+)
+
+func (a *Chain) RegisterFunction(name string, description string, parameters interface{}, fn FunctionHandler, opts ...FunctionOption) error {
+	return a.register(newFunction(name, description, parameters, fn, opts), false)
+}
+
+// RegisterTool registers a function whose handler returns an output. The
+// outputs of a turn are sent back to the model to answer the user.
+func (a *Chain) RegisterTool(name string, description string, parameters interface{}, h ToolHandler, opts ...FunctionOption) error {
+	return a.register(newTool(name, description, parameters, h, opts), false)
+}
+
+// ReplaceFunction registers f in place of the function with the same name,
+// or adds it when there is none.
+func (a *Chain) ReplaceFunction(f *Function) error {
+	if f == nil {
+		return ErrInvalidFunctionName
+	}
+
+	replacement := *withHandler(f)
+	replacement.Examples = append([]Example(nil), f.Examples...)
+
+	return a.register(&replacement, true)
+}
+
+func (a *Chain) UnregisterFunction(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fn := make([]*Function, 0, len(a.fn))
+	for _, f := range a.fn {
+		if f.Name != name {
+			fn = append(fn, f)
+		}
+	}
+
+	if len(fn) == len(a.fn) {
+		return ErrFunctionNotFound
+	}
+
+	a.fn = fn
+
+	return nil
+}
+
+// Functions returns a copy of the registered functions, without the
+// fallback, in the order they are offered to the model.
+func (a *Chain) Functions() []Function {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	functions := make([]Function, len(a.fn))
+	for i, f := range a.fn {
+		functions[i] = *f
+	}
+
+	return functions
+}
+
+// Namespace returns a view of the chain that registers functions under
+// name, so "current" in the "weather" namespace becomes "weather.current".
+func (a *Chain) Namespace(name string) *Namespace {
+	return &Namespace{chain: a, prefix: name + "."}
+}
+
+type Namespace struct {
+	chain  *Chain
+	prefix string
+}
+
+func (n *Namespace) Name() string {
+	return strings.TrimSuffix(n.prefix, ".")
+}
+
+func (n *Namespace) Namespace(name string) *Namespace {
+	return &Namespace{chain: n.chain, prefix: n.prefix + name + "."}
+}
+
+func (n *Namespace) RegisterFunction(name string, description string, parameters interface{}, fn FunctionHandler, opts ...FunctionOption) error {
+	return n.chain.RegisterFunction(n.prefix+name, description, parameters, fn, opts...)
+}
+
+func (n *Namespace) RegisterTool(name string, description string, parameters interface{}, h ToolHandler, opts ...FunctionOption) error {
+	return n.chain.RegisterTool(n.prefix+name, description, parameters, h, opts...)
+}
+
+func (n *Namespace) UnregisterFunction(name string) error {
+	return n.chain.UnregisterFunction(n.prefix + name)
+}
+
+// Functions returns the functions registered under the namespace.
+func (n *Namespace) Functions() []Function {
+	var functions []Function
+	for _, f := range n.chain.Functions() {
+		if strings.HasPrefix(f.Name, n.prefix) {
+			functions = append(functions, f)
+		}
+	}
+
+	return functions
+}
+
+func newFunction(name string, description string, parameters interface{}, fn FunctionHandler, opts []FunctionOption) *Function {
+	f := &Function{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+		Function:    fn,
+		Handler:     wrapFunctionHandler(fn),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+func newTool(name string, description string, parameters interface{}, h ToolHandler, opts []FunctionOption) *Function {
+	f := &Function{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+		Handler:     h,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+func wrapFunctionHandler(fn FunctionHandler) ToolHandler {
+	return func(_ context.Context, input map[string]interface{}) (interface{}, error) {
+		return nil, fn(input)
+	}
+}
+
+func (a *Chain) register(f *Function, replace bool) error {
+	if !validFunctionName(f.Name) {
+		return ErrInvalidFunctionName
+	}
+
+	for i := range f.Examples {
+		f.Examples[i].Tool = f.Name
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.fallback != nil && a.fallback.Name == f.Name {
+		return ErrFallbackFunctionName
+	}
+
+	// Running invocations hold the previous slice, never modify it in place.
+	fn := make([]*Function, 0, len(a.fn)+1)
+	for _, existing := range a.fn {
+		if existing.Name != f.Name {
+			fn = append(fn, existing)
+			continue
+		}
+
+		if !replace {
+			return ErrDuplicateFunction
+		}
+	}
+
+	fn = append(fn, f)
+	sort.Slice(fn, func(i, j int) bool {
+		return fn[i].Name < fn[j].Name
+	})
+
+	a.fn = fn
+
+	return nil
+}
+
+// validFunctionName accepts dot separated segments of letters, digits,
+// underscores and dashes.
+func validFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, segment := range strings.Split(name, ".") {
+		if segment == "" {
+			return false
+		}
+
+		for _, r := range segment {
+			if !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// toolFilter narrows the functions offered to the model for one
+// invocation.
+type toolFilter struct {
+	allowed  []string
+	disabled []string
+	groups   []string
+}
+
+func (t toolFilter) apply(fn []*Function) []*Function {
+	if len(t.allowed) == 0 && len(t.disabled) == 0 && len(t.groups) == 0 {
+		return fn
+	}
+
+	filtered := make([]*Function, 0, len(fn))
+	for _, f := range fn {
+		if t.enabled(f) {
+			filtered = append(filtered, f)
+		}
+	}
+
+	return filtered
+}
+
+func (t toolFilter) enabled(f *Function) bool {
+	if matchAny(t.disabled, f.Name) {
+		return false
+	}
+
+	if len(t.allowed) > 0 && !matchAny(t.allowed, f.Name) {
+		return false
+	}
+
+	if len(t.groups) > 0 && !hasAnyGroup(f, t.groups) {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasAnyGroup(f *Function, groups []string) bool {
+	for _, g := range f.Groups {
+		for _, want := range groups {
+			if g == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
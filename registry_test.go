@@ -0,0 +1,194 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func nop(context.Context, map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func functionNames(functions []gochain.Function) []string {
+	names := make([]string, len(functions))
+	for i, f := range functions {
+		names[i] = f.Name
+	}
+
+	return names
+}
+
+var functionName = regexp.MustCompile(`"name":"([^"]+)"`)
+
+// offered returns the names of the functions in the system prompt of the
+// last call.
+func offered(llm *gochaintest.FakeLLM) []string {
+	var names []string
+	for _, m := range functionName.FindAllStringSubmatch(llm.LastCall().Messages[0].Content, -1) {
+		names = append(names, m[1])
+	}
+
+	return names
+}
+
+func TestRegisterFunction(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM())
+
+	for _, name := range []string{"search", "get_weather", "weather.current"} {
+		if err := chain.RegisterTool(name, "", nil, nop); err != nil {
+			t.Fatalf("RegisterTool(%q): %v", name, err)
+		}
+	}
+
+	if err := chain.RegisterTool("search", "", nil, nop); !errors.Is(err, gochain.ErrDuplicateFunction) {
+		t.Errorf("err = %v, want ErrDuplicateFunction", err)
+	}
+
+	for _, name := range []string{"", "has space", "weather.", ".current", "a..b", "emoji😀"} {
+		if err := chain.RegisterTool(name, "", nil, nop); !errors.Is(err, gochain.ErrInvalidFunctionName) {
+			t.Errorf("RegisterTool(%q) err = %v, want ErrInvalidFunctionName", name, err)
+		}
+	}
+
+	if got, want := functionNames(chain.Functions()), []string{"get_weather", "search", "weather.current"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Functions() = %q, want %q", got, want)
+	}
+}
+
+func TestUnregisterFunction(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM())
+	if err := chain.RegisterTool("search", "", nil, nop); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.UnregisterFunction("search"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.UnregisterFunction("search"); !errors.Is(err, gochain.ErrFunctionNotFound) {
+		t.Errorf("err = %v, want ErrFunctionNotFound", err)
+	}
+
+	if len(chain.Functions()) != 0 {
+		t.Errorf("Functions() = %+v", chain.Functions())
+	}
+
+	// The name is free again.
+	if err := chain.RegisterTool("search", "", nil, nop); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplaceFunction(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM())
+	if err := chain.RegisterTool("search", "Old", nil, nop); err != nil {
+		t.Fatal(err)
+	}
+
+	example := gochain.Example{Input: "find cats"}
+	replacement := &gochain.Function{Name: "search", Description: "New", Handler: nop, Examples: []gochain.Example{example}}
+	if err := chain.ReplaceFunction(replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	functions := chain.Functions()
+	if len(functions) != 1 || functions[0].Description != "New" || functions[0].Examples[0].Tool != "search" {
+		t.Errorf("Functions() = %+v", functions)
+	}
+
+	// The caller's function is copied, not filled in.
+	if replacement.Examples[0].Tool != "" {
+		t.Errorf("ReplaceFunction changed the caller's examples to %+v", replacement.Examples)
+	}
+
+	if err := chain.ReplaceFunction(&gochain.Function{Name: "lookup", Handler: nop}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := functionNames(chain.Functions()); !reflect.DeepEqual(got, []string{"lookup", "search"}) {
+		t.Errorf("Functions() = %q", got)
+	}
+
+	if err := chain.ReplaceFunction(nil); !errors.Is(err, gochain.ErrInvalidFunctionName) {
+		t.Errorf("err = %v, want ErrInvalidFunctionName", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	chain := gochain.New(gochaintest.NewFakeLLM())
+	weather := chain.Namespace("weather")
+	alerts := weather.Namespace("alerts")
+
+	if alerts.Name() != "weather.alerts" {
+		t.Errorf("Name() = %q", alerts.Name())
+	}
+
+	for _, register := range []func() error{
+		func() error { return weather.RegisterTool("current", "", nil, nop) },
+		func() error { return alerts.RegisterTool("list", "", nil, nop) },
+		func() error { return chain.RegisterTool("weatherman", "", nil, nop) },
+	} {
+		if err := register(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := functionNames(weather.Functions()), []string{"weather.alerts.list", "weather.current"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("weather functions = %q, want %q", got, want)
+	}
+
+	if err := weather.UnregisterFunction("current"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := functionNames(chain.Functions()), []string{"weather.alerts.list", "weatherman"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Functions() = %q, want %q", got, want)
+	}
+}
+
+func TestToolFilters(t *testing.T) {
+	llm := gochaintest.NewFakeLLM().Default(gochaintest.ToolCall("conversationalResponse", map[string]interface{}{"response": "ok"}))
+
+	chain := gochain.New(llm)
+	chain.RegisterConversationalFunction(func(string) {})
+	for name, groups := range map[string][]string{
+		"search":           nil,
+		"weather.current":  {"public"},
+		"weather.forecast": {"public"},
+		"admin.reset":      {"admin"},
+	} {
+		if err := chain.RegisterTool(name, "", nil, nop, gochain.WithGroups(groups...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []gochain.InvokeOption
+		want []string
+	}{
+		{"all", nil, []string{"admin.reset", "search", "weather.current", "weather.forecast"}},
+		{"allowed", []gochain.InvokeOption{gochain.WithAllowedTools("weather.*")}, []string{"weather.current", "weather.forecast"}},
+		{"disabled", []gochain.InvokeOption{gochain.WithDisabledTools("admin.*", "search")}, []string{"weather.current", "weather.forecast"}},
+		{"groups", []gochain.InvokeOption{gochain.WithAllowedGroups("admin")}, []string{"admin.reset"}},
+		{"disabled wins", []gochain.InvokeOption{gochain.WithAllowedTools("weather.*"), gochain.WithDisabledTools("*.forecast")}, []string{"weather.current"}},
+		{"combined", []gochain.InvokeOption{gochain.WithAllowedGroups("public"), gochain.WithAllowedTools("*.forecast")}, []string{"weather.forecast"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := chain.Invoke(context.Background(), "hi", tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			// The fallback is always offered, last.
+			want := append(tt.want, "conversationalResponse")
+			if got := offered(llm); !reflect.DeepEqual(got, want) {
+				t.Errorf("offered %q, want %q", got, want)
+			}
+		})
+	}
+}
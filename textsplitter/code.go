@@ -0,0 +1,123 @@
+package textsplitter
+
+import (
+	"github.com/ryanbekhen/gochain"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+type Language string
+
+const (
+	Go         Language = "go"
+	Python     Language = "python"
+	JavaScript Language = "javascript"
+	Java       Language = "java"
+)
+
+// languageSeparators are tried before the default separators, so chunks
+// break between definitions first.
+var languageSeparators = map[Language][]string{
+	Go:         {"\nfunc ", "\ntype ", "\nvar ", "\nconst "},
+	Python:     {"\nclass ", "\ndef ", "\n\tdef ", "\n    def "},
+	JavaScript: {"\nfunction ", "\nclass ", "\nexport ", "\nconst ", "\nlet ", "\nvar "},
+	Java:       {"\nclass ", "\ninterface ", "\npublic ", "\nprotected ", "\nprivate ", "\nstatic "},
+}
+
+// Code splits source code at definition boundaries. Go source is parsed,
+// so every top-level declaration stays whole, with its doc comment, unless
+// it is longer than the chunk size.
+type Code struct {
+	text     *RecursiveCharacter
+	language Language
+}
+
+func NewCode(language Language, opts ...Option) *Code {
+	separators := append(append([]string{}, languageSeparators[language]...), DefaultSeparators...)
+
+	return &Code{text: NewRecursiveCharacter(opts...).WithSeparators(separators...), language: language}
+}
+
+func (s *Code) SplitText(text string) ([]string, error) {
+	if err := s.text.validate(); err != nil {
+		return nil, err
+	}
+
+	if s.language == Go {
+		if units, ok := goUnits(text); ok {
+			return s.mergeUnits(units), nil
+		}
+	}
+
+	return s.text.split(text, s.text.separators), nil
+}
+
+func (s *Code) SplitDocuments(docs []gochain.Document) ([]gochain.Document, error) {
+	return SplitDocuments(s, docs)
+}
+
+// mergeUnits packs whole declarations into chunks and splits only those
+// that do not fit on their own.
+func (s *Code) mergeUnits(units []string) []string {
+	var chunks, small []string
+	for _, unit := range units {
+		if s.text.length(unit) <= s.text.chunkSize {
+			small = append(small, unit)
+			continue
+		}
+
+		chunks = append(chunks, s.text.merge(small)...)
+		small = nil
+		chunks = append(chunks, s.text.split(unit, DefaultSeparators)...)
+	}
+
+	return append(chunks, s.text.merge(small)...)
+}
+
+// goUnits cuts a Go file into the package clause with imports followed by
+// one piece per top-level declaration. It reports false when the source
+// does not parse.
+func goUnits(src string) ([]string, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+
+	var starts []int
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+
+		pos := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			pos = doc.Pos()
+		}
+
+		starts = append(starts, fset.Position(pos).Offset)
+	}
+
+	var units []string
+	prev := 0
+	for _, start := range starts {
+		if start > prev {
+			units = append(units, src[prev:start])
+		}
+		prev = start
+	}
+
+	return append(units, src[prev:]), true
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+
+	return nil
+}
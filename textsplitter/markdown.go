@@ -0,0 +1,148 @@
+package textsplitter
+
+import (
+	"github.com/ryanbekhen/gochain"
+	"strings"
+)
+
+// MetadataHeaders is the metadata key holding the path of headings a
+// Markdown chunk is under, outermost first.
+const MetadataHeaders = "headers"
+
+// MarkdownHeader splits Markdown into sections at ATX headings and records
+// the heading path of each section. Sections longer than the chunk size
+// are split further by paragraph, line and word.
+type MarkdownHeader struct {
+	text     *RecursiveCharacter
+	maxLevel int
+}
+
+func NewMarkdownHeader(opts ...Option) *MarkdownHeader {
+	return &MarkdownHeader{text: NewRecursiveCharacter(opts...), maxLevel: 6}
+}
+
+// WithMaxLevel returns a copy of the splitter that only splits at headings
+// up to the given level, so "###" and deeper stay inside their section
+// with level 2.
+func (s *MarkdownHeader) WithMaxLevel(level int) *MarkdownHeader {
+	c := *s
+	c.maxLevel = level
+
+	return &c
+}
+
+type markdownSection struct {
+	headers []string
+	text    string
+}
+
+func (s *MarkdownHeader) SplitText(text string) ([]string, error) {
+	docs, err := s.SplitDocuments([]gochain.Document{{PageContent: text}})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]string, len(docs))
+	for i, d := range docs {
+		chunks[i] = d.PageContent
+	}
+
+	return chunks, nil
+}
+
+func (s *MarkdownHeader) SplitDocuments(docs []gochain.Document) ([]gochain.Document, error) {
+	if err := s.text.validate(); err != nil {
+		return nil, err
+	}
+
+	var out []gochain.Document
+	for _, doc := range docs {
+		i := 0
+		for _, section := range s.sections(doc.PageContent) {
+			for _, chunk := range s.text.split(section.text, s.text.separators) {
+				metadata := chunkMetadata(doc.Metadata, i)
+				if len(section.headers) > 0 {
+					metadata[MetadataHeaders] = section.headers
+				}
+
+				out = append(out, gochain.Document{PageContent: chunk, Metadata: metadata})
+				i++
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (s *MarkdownHeader) sections(text string) []markdownSection {
+	var sections []markdownSection
+	var headers []string
+	var lines []string
+	var fence string
+
+	flush := func() {
+		if body := strings.TrimSpace(strings.Join(lines, "\n")); body != "" {
+			sections = append(sections, markdownSection{headers: append([]string{}, headers...), text: body})
+		}
+		lines = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Lines starting with # inside code blocks are not headings.
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			lines = append(lines, line)
+			continue
+		}
+
+		level, title := parseHeading(trimmed)
+		if level == 0 || level > s.maxLevel {
+			lines = append(lines, line)
+			continue
+		}
+
+		flush()
+
+		if len(headers) >= level {
+			headers = headers[:level-1]
+		}
+		for len(headers) < level-1 {
+			headers = append(headers, "")
+		}
+		headers = append(headers, title)
+
+		lines = append(lines, line)
+	}
+
+	flush()
+
+	return sections
+}
+
+// parseHeading returns the level and title of an ATX heading line, or zero
+// when line is not one.
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+
+	title := strings.TrimSpace(line[level:])
+	title = strings.TrimSpace(strings.TrimRight(title, "#"))
+
+	return level, title
+}
@@ -0,0 +1,89 @@
+package textsplitter
+
+import (
+	"github.com/ryanbekhen/gochain"
+	"strings"
+)
+
+// DefaultSeparators split by paragraph, then line, then word, then
+// character.
+var DefaultSeparators = []string{"\n\n", "\n", " ", ""}
+
+// RecursiveCharacter splits text on the first separator that occurs in it
+// and splits pieces that are still too long on the next separators.
+type RecursiveCharacter struct {
+	options
+	separators []string
+}
+
+func NewRecursiveCharacter(opts ...Option) *RecursiveCharacter {
+	return &RecursiveCharacter{options: newOptions(opts), separators: DefaultSeparators}
+}
+
+// WithSeparators returns a copy of the splitter using separators, tried in
+// order.
+func (s *RecursiveCharacter) WithSeparators(separators ...string) *RecursiveCharacter {
+	c := *s
+	c.separators = separators
+
+	return &c
+}
+
+func (s *RecursiveCharacter) SplitText(text string) ([]string, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	return s.split(text, s.separators), nil
+}
+
+func (s *RecursiveCharacter) SplitDocuments(docs []gochain.Document) ([]gochain.Document, error) {
+	return SplitDocuments(s, docs)
+}
+
+func (s *RecursiveCharacter) split(text string, separators []string) []string {
+	separator, rest := "", []string(nil)
+	for i, sep := range separators {
+		if sep == "" || strings.Contains(text, sep) {
+			separator, rest = sep, separators[i+1:]
+			break
+		}
+	}
+
+	pieces := splitKeepSeparator(text, separator)
+
+	var chunks, small []string
+	for _, piece := range pieces {
+		if s.length(piece) <= s.chunkSize {
+			small = append(small, piece)
+			continue
+		}
+
+		chunks = append(chunks, s.merge(small)...)
+		small = nil
+
+		if len(rest) == 0 {
+			chunks = appendChunk(chunks, piece)
+		} else {
+			chunks = append(chunks, s.split(piece, rest)...)
+		}
+	}
+
+	return append(chunks, s.merge(small)...)
+}
+
+// splitKeepSeparator splits text before each separator, so joining the
+// pieces restores the text and code keywords used as separators stay with
+// the code they introduce.
+func splitKeepSeparator(text, separator string) []string {
+	if separator == "" {
+		return strings.Split(text, "")
+	}
+
+	parts := strings.Split(text, separator)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = separator + parts[i]
+	}
+
+	return parts
+}
@@ -0,0 +1,149 @@
+// Package textsplitter cuts documents into chunks small enough to embed or
+// fit in a prompt.
+package textsplitter
+
+import (
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 200
+)
+
+// MetadataChunk is the metadata key holding the position of a chunk within
+// the document it was split from.
+const MetadataChunk = "chunk"
+
+var ErrInvalidOverlap = errors.New("textsplitter: chunk overlap must be smaller than the chunk size")
+
+// TextSplitter splits plain text.
+type TextSplitter interface {
+	SplitText(text string) ([]string, error)
+}
+
+// Splitter splits documents, carrying their metadata over to every chunk.
+type Splitter interface {
+	SplitDocuments(docs []gochain.Document) ([]gochain.Document, error)
+}
+
+type Option func(*options)
+
+type options struct {
+	chunkSize    int
+	chunkOverlap int
+	length       func(string) int
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		chunkSize:    defaultChunkSize,
+		chunkOverlap: defaultChunkOverlap,
+		length:       utf8.RuneCountInString,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithChunkSize sets the maximum chunk length. Defaults to 1000.
+func WithChunkSize(size int) Option {
+	return func(o *options) {
+		o.chunkSize = size
+	}
+}
+
+// WithChunkOverlap sets how much of the end of a chunk is repeated at the
+// start of the next one. Defaults to 200.
+func WithChunkOverlap(overlap int) Option {
+	return func(o *options) {
+		o.chunkOverlap = overlap
+	}
+}
+
+// WithLengthFunction sets how chunk length is measured. Defaults to the
+// number of characters.
+func WithLengthFunction(length func(string) int) Option {
+	return func(o *options) {
+		o.length = length
+	}
+}
+
+func (o options) validate() error {
+	if o.chunkSize <= 0 || o.chunkOverlap < 0 || o.chunkOverlap >= o.chunkSize {
+		return ErrInvalidOverlap
+	}
+
+	return nil
+}
+
+// merge joins consecutive pieces into chunks of at most chunkSize,
+// starting each chunk with up to chunkOverlap of the previous one.
+func (o options) merge(pieces []string) []string {
+	var chunks, current []string
+	total := 0
+
+	for _, piece := range pieces {
+		n := o.length(piece)
+
+		if total+n > o.chunkSize && len(current) > 0 {
+			chunks = appendChunk(chunks, strings.Join(current, ""))
+
+			for len(current) > 0 && (total > o.chunkOverlap || total+n > o.chunkSize) {
+				total -= o.length(current[0])
+				current = current[1:]
+			}
+		}
+
+		current = append(current, piece)
+		total += n
+	}
+
+	if len(current) > 0 {
+		chunks = appendChunk(chunks, strings.Join(current, ""))
+	}
+
+	return chunks
+}
+
+func appendChunk(chunks []string, chunk string) []string {
+	if chunk = strings.TrimSpace(chunk); chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// SplitDocuments splits every document with s. Chunks keep a copy of the
+// document metadata and their position under MetadataChunk.
+func SplitDocuments(s TextSplitter, docs []gochain.Document) ([]gochain.Document, error) {
+	var out []gochain.Document
+	for _, doc := range docs {
+		chunks, err := s.SplitText(doc.PageContent)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, chunk := range chunks {
+			out = append(out, gochain.Document{PageContent: chunk, Metadata: chunkMetadata(doc.Metadata, i)})
+		}
+	}
+
+	return out, nil
+}
+
+func chunkMetadata(metadata map[string]interface{}, chunk int) map[string]interface{} {
+	m := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		m[k] = v
+	}
+	m[MetadataChunk] = chunk
+
+	return m
+}
@@ -0,0 +1,341 @@
+package textsplitter_test
+
+import (
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/textsplitter"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// words counts whitespace separated words.
+type words struct{}
+
+func (words) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestRecursiveCharacter(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{"fits", "hello world", 20, 5, []string{"hello world"}},
+		{"exactly the chunk size", "abcde", 5, 0, []string{"abcde"}},
+		{"one over the chunk size", "abcdef", 5, 0, []string{"abcde", "f"}},
+		{"words without overlap", "a b c d e f", 5, 0, []string{"a b c", "d e", "f"}},
+		// Each chunk starts with at most two characters of the previous one.
+		{"words with overlap", "a b c d e f", 5, 2, []string{"a b c", "c d", "d e", "e f"}},
+		// The overlap never pushes a chunk over its size: "c d" plus " e"
+		// would not fit after "a b c d".
+		{"overlap bounded by the size", "a b c d e", 7, 4, []string{"a b c d", "c d e"}},
+		{"characters", "abcdefgh", 3, 1, []string{"abc", "cde", "efg", "gh"}},
+		{"paragraphs before lines", "one two\nthree\n\nfour five", 14, 0, []string{"one two\nthree", "four five"}},
+		{"lines before words", "one two\nthree four", 11, 0, []string{"one two", "three four"}},
+		// A piece split further keeps its separator, which counts towards
+		// the size even though it is trimmed from the chunk.
+		{"separator counted", "one two\nthree four", 10, 0, []string{"one two", "three", "four"}},
+		{"runes, not bytes", "éééé", 2, 0, []string{"éé", "éé"}},
+		{"blank chunks dropped", "a\n\n\n\n\n\nb", 1, 0, []string{"a", "b"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := textsplitter.NewRecursiveCharacter(textsplitter.WithChunkSize(tt.size), textsplitter.WithChunkOverlap(tt.overlap))
+
+			got, err := s.SplitText(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitText() = %q, want %q", got, tt.want)
+			}
+
+			for _, chunk := range got {
+				if n := utf8.RuneCountInString(chunk); n > tt.size {
+					t.Errorf("chunk %q is %d long, over %d", chunk, n, tt.size)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkBoundaries(t *testing.T) {
+	// Numbered words, so every chunk is found at a single place.
+	var numbered []string
+	for i := 0; i < 300; i++ {
+		numbered = append(numbered, "w"+strconv.Itoa(i))
+	}
+	text := strings.Join(numbered, " ")
+
+	for _, size := range []int{10, 37, 100, 256} {
+		for _, overlap := range []int{0, 1, size / 4, size - 1} {
+			s := textsplitter.NewRecursiveCharacter(textsplitter.WithChunkSize(size), textsplitter.WithChunkOverlap(overlap))
+			chunks, err := s.SplitText(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Chunks fit, come in order, repeat at most the overlap of the
+			// previous chunk and leave nothing but spaces out.
+			end := 0
+			for i, chunk := range chunks {
+				if len(chunk) > size {
+					t.Fatalf("size %d overlap %d: chunk %d is %d long", size, overlap, i, len(chunk))
+				}
+
+				start := strings.Index(text, chunk)
+				if start < 0 || start+len(chunk) <= end {
+					t.Fatalf("size %d overlap %d: chunk %d %q out of order", size, overlap, i, chunk)
+				}
+
+				if start < end && end-start > overlap {
+					t.Errorf("size %d overlap %d: chunk %d repeats %d characters", size, overlap, i, end-start)
+				}
+
+				if start > end && strings.TrimSpace(text[end:start]) != "" {
+					t.Errorf("size %d overlap %d: %q lost before chunk %d", size, overlap, text[end:start], i)
+				}
+
+				end = start + len(chunk)
+			}
+
+			if end != len(text) {
+				t.Errorf("size %d overlap %d: %q lost at the end", size, overlap, text[end:])
+			}
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, tt := range []struct {
+		size    int
+		overlap int
+	}{
+		{0, 0},
+		{10, 10},
+		{10, 11},
+		{10, -1},
+	} {
+		s := textsplitter.NewRecursiveCharacter(textsplitter.WithChunkSize(tt.size), textsplitter.WithChunkOverlap(tt.overlap))
+		if _, err := s.SplitText("text"); !errors.Is(err, textsplitter.ErrInvalidOverlap) {
+			t.Errorf("size %d overlap %d: err = %v, want ErrInvalidOverlap", tt.size, tt.overlap, err)
+		}
+	}
+
+	// The defaults are valid.
+	if _, err := textsplitter.NewRecursiveCharacter().SplitText("text"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithSeparators(t *testing.T) {
+	s := textsplitter.NewRecursiveCharacter(textsplitter.WithChunkSize(6), textsplitter.WithChunkOverlap(0)).WithSeparators(";", "")
+
+	got, err := s.SplitText("ab;cd;efgh;ij")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Separators stay at the start of the piece they precede.
+	if want := []string{"ab;cd", ";efgh", ";ij"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitText() = %q, want %q", got, want)
+	}
+}
+
+func TestToken(t *testing.T) {
+	s := textsplitter.NewToken(words{}, textsplitter.WithChunkSize(3), textsplitter.WithChunkOverlap(1))
+
+	got, err := s.SplitText("one two three four five six")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"one two three", "three four five", "five six"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitText() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	docs := []gochain.Document{
+		{PageContent: "a b c d", Metadata: map[string]interface{}{"source": "x"}},
+		{PageContent: "e"},
+	}
+
+	s := textsplitter.NewRecursiveCharacter(textsplitter.WithChunkSize(4), textsplitter.WithChunkOverlap(0))
+	got, err := s.SplitDocuments(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []gochain.Document{
+		{PageContent: "a b", Metadata: map[string]interface{}{"source": "x", "chunk": 0}},
+		{PageContent: "c d", Metadata: map[string]interface{}{"source": "x", "chunk": 1}},
+		{PageContent: "e", Metadata: map[string]interface{}{"chunk": 0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitDocuments() = %+v, want %+v", got, want)
+	}
+
+	// Chunks get their own metadata.
+	got[0].Metadata["source"] = "changed"
+	if docs[0].Metadata["source"] != "x" || got[1].Metadata["source"] != "x" {
+		t.Error("chunks share the document metadata")
+	}
+}
+
+func TestMarkdownHeader(t *testing.T) {
+	text := `Intro.
+
+# Guide
+
+Welcome.
+
+## Install
+
+Run it.
+
+` + "```sh\n# not a heading\n```" + `
+
+### Linux
+
+Use apt.
+
+## Usage
+
+Call it.
+
+# FAQ #
+
+None.`
+
+	s := textsplitter.NewMarkdownHeader(textsplitter.WithChunkSize(100), textsplitter.WithChunkOverlap(0))
+	docs, err := s.SplitDocuments([]gochain.Document{{PageContent: text, Metadata: map[string]interface{}{"source": "a.md"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		content string
+		headers []string
+	}{
+		{"Intro.", nil},
+		{"# Guide\n\nWelcome.", []string{"Guide"}},
+		{"## Install\n\nRun it.\n\n```sh\n# not a heading\n```", []string{"Guide", "Install"}},
+		{"### Linux\n\nUse apt.", []string{"Guide", "Install", "Linux"}},
+		{"## Usage\n\nCall it.", []string{"Guide", "Usage"}},
+		{"# FAQ #\n\nNone.", []string{"FAQ"}},
+	}
+
+	if len(docs) != len(want) {
+		t.Fatalf("%d chunks, want %d: %q", len(docs), len(want), docs)
+	}
+
+	for i, w := range want {
+		headers, _ := docs[i].Metadata[textsplitter.MetadataHeaders].([]string)
+		if docs[i].PageContent != w.content || !reflect.DeepEqual(headers, w.headers) {
+			t.Errorf("chunk %d = %q under %q, want %q under %q", i, docs[i].PageContent, headers, w.content, w.headers)
+		}
+
+		if docs[i].Metadata["source"] != "a.md" || docs[i].Metadata[textsplitter.MetadataChunk] != i {
+			t.Errorf("chunk %d metadata = %v", i, docs[i].Metadata)
+		}
+	}
+
+	// Deeper headings stay in their section.
+	s = textsplitter.NewMarkdownHeader(textsplitter.WithChunkSize(200), textsplitter.WithChunkOverlap(0))
+	chunks, err := s.WithMaxLevel(1).SplitText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chunks) != 3 || !strings.Contains(chunks[1], "### Linux") {
+		t.Errorf("chunks up to level 1 = %q", chunks)
+	}
+}
+
+func TestMarkdownHeaderSplitsLongSections(t *testing.T) {
+	s := textsplitter.NewMarkdownHeader(textsplitter.WithChunkSize(20), textsplitter.WithChunkOverlap(0))
+
+	docs, err := s.SplitDocuments([]gochain.Document{{PageContent: "# Title\n\nfirst paragraph\n\nsecond paragraph"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 3 {
+		t.Fatalf("chunks = %+v", docs)
+	}
+
+	for i, d := range docs {
+		if !reflect.DeepEqual(d.Metadata[textsplitter.MetadataHeaders], []string{"Title"}) || d.Metadata[textsplitter.MetadataChunk] != i {
+			t.Errorf("chunk %d %q metadata = %v", i, d.PageContent, d.Metadata)
+		}
+	}
+}
+
+const goSource = `package main
+
+import "fmt"
+
+// greet says hello.
+// It is short.
+func greet() {
+	fmt.Println("hello")
+}
+
+type point struct {
+	x, y int
+}
+
+func main() {
+	greet()
+}
+`
+
+func TestCodeGo(t *testing.T) {
+	s := textsplitter.NewCode(textsplitter.Go, textsplitter.WithChunkSize(80), textsplitter.WithChunkOverlap(0))
+
+	got, err := s.SplitText(goSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Declarations stay whole and keep their doc comment.
+	want := []string{
+		"package main\n\nimport \"fmt\"",
+		"// greet says hello.\n// It is short.\nfunc greet() {\n\tfmt.Println(\"hello\")\n}",
+		"type point struct {\n\tx, y int\n}\n\nfunc main() {\n\tgreet()\n}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitText() = %q, want %q", got, want)
+	}
+}
+
+func TestCodeFallsBackToSeparators(t *testing.T) {
+	src := "def a():\n    return 1\n\ndef b():\n    return 2\n"
+
+	for _, tt := range []struct {
+		language textsplitter.Language
+		src      string
+	}{
+		{textsplitter.Python, src},
+		// Go that does not parse is split like other languages.
+		{textsplitter.Go, "func a() {\n\treturn 1\n\nfunc b() {\n\treturn 2\n"},
+	} {
+		s := textsplitter.NewCode(tt.language, textsplitter.WithChunkSize(25), textsplitter.WithChunkOverlap(0))
+
+		got, err := s.SplitText(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != 2 || !strings.HasSuffix(got[0], "1") || !strings.HasSuffix(got[1], "2") {
+			t.Errorf("%s: SplitText() = %q, want one chunk per definition", tt.language, got)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package textsplitter
+
+import "github.com/ryanbekhen/gochain"
+
+// NewToken returns a recursive splitter measuring chunk size and overlap in
+// tokens of t, so chunks stay within the input limit of an embedding
+// model.
+func NewToken(t gochain.Tokenizer, opts ...Option) *RecursiveCharacter {
+	return NewRecursiveCharacter(append([]Option{WithLengthFunction(t.Count)}, opts...)...)
+}
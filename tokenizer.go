@@ -0,0 +1,126 @@
+package gochain
+
+import (
+	"encoding/json"
+	"github.com/ryanbekhen/gochain/tokenizer"
+)
+
+type Tokenizer interface {
+	Count(text string) int
+}
+
+const (
+	// MessageOverhead approximates the role and separator tokens that chat
+	// templates add around every message.
+	MessageOverhead = 4
+	// ImageTokens is charged for every image part, most vision encoders
+	// use a fixed number of patches per image.
+	ImageTokens = 576
+)
+
+func CountMessage(t Tokenizer, m Message) int {
+	return t.Count(m.Role) + t.Count(m.Text()) + len(m.Images())*ImageTokens + MessageOverhead
+}
+
+func CountMessages(t Tokenizer, messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += CountMessage(t, m)
+	}
+
+	return total
+}
+
+// TrimMessages drops the oldest messages until messages fit in maxTokens.
+// Leading system messages and the current turn, from the last user message
+// on, are always kept, so the result may still exceed maxTokens when those
+// alone do. Tool messages left without the call they answer are dropped as
+// well.
+func TrimMessages(t Tokenizer, messages []Message, maxTokens int) []Message {
+	head := 0
+	for head < len(messages) && messages[head].Role == "system" {
+		head++
+	}
+
+	if head == len(messages) {
+		return messages
+	}
+
+	turn := len(messages) - 1
+	for i := turn; i >= head; i-- {
+		if messages[i].Role == "user" {
+			turn = i
+			break
+		}
+	}
+
+	budget := maxTokens - CountMessages(t, messages[:head]) - CountMessages(t, messages[turn:])
+	start := turn
+	for i := turn - 1; i >= head; i-- {
+		budget -= CountMessage(t, messages[i])
+		if budget < 0 {
+			break
+		}
+
+		start = i
+	}
+
+	for start < turn && messages[start].Role == "tool" {
+		start++
+	}
+
+	trimmed := make([]Message, 0, head+len(messages)-start)
+	trimmed = append(trimmed, messages[:head]...)
+	trimmed = append(trimmed, messages[start:]...)
+
+	return trimmed
+}
+
+func CountFunction(t Tokenizer, fn *Function) int {
+	data, err := json.Marshal(fn)
+	if err != nil {
+		return 0
+	}
+
+	return t.Count(string(data))
+}
+
+// FitFunctions keeps functions in order while they fit in maxTokens. The
+// functions named in required are kept regardless and counted first.
+func FitFunctions(t Tokenizer, functions []*Function, maxTokens int, required ...string) []*Function {
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		isRequired[name] = true
+	}
+
+	budget := maxTokens
+	for _, fn := range functions {
+		if isRequired[fn.Name] {
+			budget -= CountFunction(t, fn)
+		}
+	}
+
+	fitted := make([]*Function, 0, len(functions))
+	for _, fn := range functions {
+		if isRequired[fn.Name] {
+			fitted = append(fitted, fn)
+			continue
+		}
+
+		cost := CountFunction(t, fn)
+		if cost > budget {
+			continue
+		}
+
+		budget -= cost
+		fitted = append(fitted, fn)
+	}
+
+	return fitted
+}
+
+// defaultTokenizer is used when a context window or token budget is set
+// without a Tokenizer.
+func defaultTokenizer() Tokenizer {
+	return tokenizer.NewHeuristic()
+}
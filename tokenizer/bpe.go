@@ -0,0 +1,326 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maxCacheSize = 16 * 1024
+
+// ErrUnsupportedVocabulary is returned for vocabularies that are not
+// byte-level BPE or split text in a way BPE does not implement.
+var ErrUnsupportedVocabulary = errors.New("tokenizer: unsupported vocabulary")
+
+// BPE is a byte-level byte pair encoding tokenizer.
+type BPE struct {
+	// ranks orders merges, lower merges first. It is keyed by the bytes
+	// produced by the merge.
+	ranks   map[string]int
+	encoder map[string]int
+	decoder map[int]string
+	// split cuts text into the pieces merges stay within.
+	split func(text string) []string
+
+	mu    sync.RWMutex
+	cache map[string][]int
+}
+
+// newBPE checks that encoder has a token for every byte, so any text can
+// be encoded without dropping bytes.
+func newBPE(ranks, encoder map[string]int, split func(string) []string) (*BPE, error) {
+	for b := 0; b < 256; b++ {
+		if _, ok := encoder[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("%w: no token for byte 0x%02x", ErrUnsupportedVocabulary, b)
+		}
+	}
+
+	decoder := make(map[int]string, len(encoder))
+	for token, id := range encoder {
+		decoder[id] = token
+	}
+
+	return &BPE{ranks: ranks, encoder: encoder, decoder: decoder, split: split, cache: map[string][]int{}}, nil
+}
+
+// LoadTiktoken reads a tiktoken vocabulary, one base64 token and its rank
+// per line. Text is split with the cl100k_base pattern, so counts are exact
+// for cl100k_base and approximate for other tiktoken vocabularies.
+func LoadTiktoken(path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("tokenizer: %s:%d: expected token and rank", path, line)
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+
+		ranks[string(token)] = rank
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return newBPE(ranks, ranks, pretokenize)
+}
+
+type hfTokenizer struct {
+	PreTokenizer *hfPreTokenizer `json:"pre_tokenizer"`
+	Model        struct {
+		Type         string          `json:"type"`
+		Vocab        json.RawMessage `json:"vocab"`
+		Merges       json.RawMessage `json:"merges"`
+		ByteFallback bool            `json:"byte_fallback"`
+	} `json:"model"`
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+}
+
+type hfPreTokenizer struct {
+	Type           string `json:"type"`
+	AddPrefixSpace bool   `json:"add_prefix_space"`
+	UseRegex       *bool  `json:"use_regex"`
+	Pattern        struct {
+		Regex string `json:"Regex"`
+	} `json:"pattern"`
+	PreTokenizers []*hfPreTokenizer `json:"pretokenizers"`
+}
+
+// cl100kPattern is the pre-tokenizer regex of cl100k_base, also used by
+// Llama 3.
+const cl100kPattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+
+// splitter returns how the pre-tokenizer cuts text: either a ByteLevel
+// pre-tokenizer with the GPT-2 pattern, or a Split with the cl100k pattern
+// followed by a ByteLevel pre-tokenizer that does not split again.
+func (p *hfPreTokenizer) splitter() (func(string) []string, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: no byte-level pre-tokenizer", ErrUnsupportedVocabulary)
+	}
+
+	switch p.Type {
+	case "ByteLevel":
+		if p.UseRegex != nil && !*p.UseRegex {
+			return nil, fmt.Errorf("%w: byte-level pre-tokenizer without a split pattern", ErrUnsupportedVocabulary)
+		}
+
+		if p.AddPrefixSpace {
+			return func(text string) []string {
+				if !strings.HasPrefix(text, " ") {
+					text = " " + text
+				}
+
+				return pretokenizeGPT2(text)
+			}, nil
+		}
+
+		return pretokenizeGPT2, nil
+	case "Sequence":
+		if len(p.PreTokenizers) == 2 && p.PreTokenizers[0].Type == "Split" && p.PreTokenizers[0].Pattern.Regex == cl100kPattern &&
+			p.PreTokenizers[1].Type == "ByteLevel" && p.PreTokenizers[1].UseRegex != nil && !*p.PreTokenizers[1].UseRegex &&
+			!p.PreTokenizers[1].AddPrefixSpace {
+			return pretokenize, nil
+		}
+
+		return nil, fmt.Errorf("%w: pre-tokenizer sequence is not the cl100k split followed by ByteLevel", ErrUnsupportedVocabulary)
+	default:
+		return nil, fmt.Errorf("%w: %s pre-tokenizer", ErrUnsupportedVocabulary, p.Type)
+	}
+}
+
+// LoadHuggingFace reads a byte-level BPE tokenizer.json as published with
+// GPT-2 and Llama 3 style models. SentencePiece based vocabularies, as used
+// by Llama 2 and Mistral, and other pre-tokenizers return
+// ErrUnsupportedVocabulary rather than miscounting.
+func LoadHuggingFace(path string) (*BPE, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hf hfTokenizer
+	if err := json.Unmarshal(data, &hf); err != nil {
+		return nil, err
+	}
+
+	if hf.Model.Type != "BPE" {
+		return nil, fmt.Errorf("%w: model type %q", ErrUnsupportedVocabulary, hf.Model.Type)
+	}
+
+	if hf.Model.ByteFallback {
+		return nil, fmt.Errorf("%w: SentencePiece byte fallback", ErrUnsupportedVocabulary)
+	}
+
+	split, err := hf.PreTokenizer.splitter()
+	if err != nil {
+		return nil, err
+	}
+
+	merges, err := parseMerges(hf.Model.Merges)
+	if err != nil {
+		return nil, err
+	}
+
+	var vocab map[string]int
+	if err := json.Unmarshal(hf.Model.Vocab, &vocab); err != nil {
+		return nil, fmt.Errorf("tokenizer: invalid vocabulary: %w", err)
+	}
+
+	encoder := make(map[string]int, len(vocab))
+	for token, id := range vocab {
+		encoder[fromByteLevel(token)] = id
+	}
+
+	ranks := make(map[string]int, len(merges))
+	for i, m := range merges {
+		merged := fromByteLevel(m[0] + m[1])
+		if _, ok := ranks[merged]; !ok {
+			ranks[merged] = i
+		}
+	}
+
+	bpe, err := newBPE(ranks, encoder, split)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range hf.AddedTokens {
+		bpe.decoder[t.ID] = t.Content
+	}
+
+	return bpe, nil
+}
+
+// parseMerges accepts both the "a b" and ["a", "b"] merge encodings.
+func parseMerges(raw json.RawMessage) ([][2]string, error) {
+	var pairs [][2]string
+	if err := json.Unmarshal(raw, &pairs); err == nil {
+		return pairs, nil
+	}
+
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return nil, errors.New("tokenizer: invalid merges")
+	}
+
+	pairs = make([][2]string, 0, len(lines))
+	for _, l := range lines {
+		a, b, ok := strings.Cut(l, " ")
+		if !ok {
+			return nil, fmt.Errorf("tokenizer: invalid merge %q", l)
+		}
+
+		pairs = append(pairs, [2]string{a, b})
+	}
+
+	return pairs, nil
+}
+
+func (t *BPE) Encode(text string) []int {
+	var ids []int
+	for _, piece := range t.split(text) {
+		ids = append(ids, t.encodePiece(piece)...)
+	}
+
+	return ids
+}
+
+func (t *BPE) Decode(ids []int) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		sb.WriteString(t.decoder[id])
+	}
+
+	return sb.String()
+}
+
+func (t *BPE) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+func (t *BPE) encodePiece(piece string) []int {
+	if id, ok := t.encoder[piece]; ok {
+		return []int{id}
+	}
+
+	t.mu.RLock()
+	ids, ok := t.cache[piece]
+	t.mu.RUnlock()
+	if ok {
+		return ids
+	}
+
+	parts := make([]string, len(piece))
+	for i := range parts {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			rank, ok := t.ranks[parts[i]+parts[i+1]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	ids = make([]int, 0, len(parts))
+	for _, p := range parts {
+		if id, ok := t.encoder[p]; ok {
+			ids = append(ids, id)
+			continue
+		}
+
+		// A merge without a token of its own, fall back to its bytes,
+		// which newBPE guarantees are all in the vocabulary.
+		for i := 0; i < len(p); i++ {
+			ids = append(ids, t.encoder[p[i:i+1]])
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= maxCacheSize {
+		t.cache = map[string][]int{}
+	}
+	t.cache[piece] = ids
+	t.mu.Unlock()
+
+	return ids
+}
@@ -0,0 +1,256 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTiktoken writes a vocabulary of the 256 bytes followed by tokens,
+// ranked in order.
+func writeTiktoken(t *testing.T, tokens ...string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+
+	for i, token := range tokens {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+i)
+	}
+
+	path := filepath.Join(t.TempDir(), "vocab.tiktoken")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestTiktokenEncode(t *testing.T) {
+	bpe, err := LoadTiktoken(writeTiktoken(t, "he", "ll", "hell", "hello", " w", " wo", "rl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		text string
+		want []int
+	}{
+		// A piece in the vocabulary is a single token.
+		{"hello", []int{259}},
+		// " w" (260) merges first, then " wo" (261), then "rl" (262).
+		{" world", []int{261, 262, 'd'}},
+		{"hello world", []int{259, 261, 262, 'd'}},
+		// Merges stay within pieces: "o" and " w" never join.
+		{"hello wo", []int{259, 261}},
+		// Bytes without merges are tokens of their own.
+		{"é!", []int{0xC3, 0xA9, '!'}},
+		{"", nil},
+	} {
+		got := bpe.Encode(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+
+		if bpe.Count(tt.text) != len(tt.want) {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, bpe.Count(tt.text), len(tt.want))
+		}
+
+		if decoded := bpe.Decode(got); decoded != tt.text {
+			t.Errorf("Decode(Encode(%q)) = %q", tt.text, decoded)
+		}
+	}
+}
+
+func TestTiktokenMissingByte(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocab.tiktoken")
+	if err := os.WriteFile(path, []byte("aGk= 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTiktoken(path); !errors.Is(err, ErrUnsupportedVocabulary) {
+		t.Errorf("err = %v, want ErrUnsupportedVocabulary", err)
+	}
+}
+
+// byteLevel maps raw bytes to the printable runes of byte-level
+// vocabularies.
+func byteLevel(s string) string {
+	encoder := make(map[byte]rune, len(byteLevelDecoder))
+	for r, b := range byteLevelDecoder {
+		encoder[b] = r
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		sb.WriteRune(encoder[s[i]])
+	}
+
+	return sb.String()
+}
+
+// writeHuggingFace writes a tokenizer.json with a byte-level vocabulary of
+// the 256 bytes and tokens, the given merges and pre-tokenizer.
+func writeHuggingFace(t *testing.T, preTokenizer interface{}, tokens []string, merges []string) string {
+	t.Helper()
+
+	vocab := map[string]int{}
+	for b := 0; b < 256; b++ {
+		vocab[byteLevel(string([]byte{byte(b)}))] = b
+	}
+
+	for i, token := range tokens {
+		vocab[byteLevel(token)] = 256 + i
+	}
+
+	encoded := make([]string, len(merges))
+	for i, m := range merges {
+		a, b, _ := strings.Cut(m, "|")
+		encoded[i] = byteLevel(a) + " " + byteLevel(b)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"pre_tokenizer": preTokenizer,
+		"model":         map[string]interface{}{"type": "BPE", "vocab": vocab, "merges": encoded},
+		"added_tokens":  []map[string]interface{}{{"id": 1000, "content": "<|endoftext|>"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+var gpt2PreTokenizer = map[string]interface{}{"type": "ByteLevel", "add_prefix_space": false, "use_regex": true}
+
+func TestHuggingFaceEncode(t *testing.T) {
+	path := writeHuggingFace(t, gpt2PreTokenizer,
+		[]string{" w", " wo", "rl", "12", "123"},
+		[]string{" |w", " w|o", "r|l", "1|2", "12|3"})
+
+	bpe, err := LoadHuggingFace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		text string
+		want []int
+	}{
+		{" world", []int{257, 258, 'd'}},
+		// GPT-2 keeps numbers whole, where cl100k would cut after 3 digits.
+		{"12312", []int{260, 259}},
+		// The space is the byte-level Ġ in the vocabulary.
+		{"a b", []int{'a', ' ', 'b'}},
+	} {
+		if got := bpe.Encode(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+
+		if decoded := bpe.Decode(bpe.Encode(tt.text)); decoded != tt.text {
+			t.Errorf("Decode(Encode(%q)) = %q", tt.text, decoded)
+		}
+	}
+
+	if got := bpe.Decode([]int{1000}); got != "<|endoftext|>" {
+		t.Errorf("added token decoded as %q", got)
+	}
+}
+
+func TestHuggingFaceMergeWithoutToken(t *testing.T) {
+	// The merge builds "ab", which has no token of its own.
+	bpe, err := LoadHuggingFace(writeHuggingFace(t, gpt2PreTokenizer, nil, []string{"a|b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bpe.Encode("ab"); !reflect.DeepEqual(got, []int{'a', 'b'}) {
+		t.Errorf("Encode(ab) = %v, want the two bytes", got)
+	}
+}
+
+func TestHuggingFaceCL100KSplit(t *testing.T) {
+	preTokenizer := map[string]interface{}{
+		"type": "Sequence",
+		"pretokenizers": []interface{}{
+			map[string]interface{}{"type": "Split", "pattern": map[string]string{"Regex": cl100kPattern}, "behavior": "Isolated"},
+			map[string]interface{}{"type": "ByteLevel", "add_prefix_space": false, "use_regex": false},
+		},
+	}
+
+	bpe, err := LoadHuggingFace(writeHuggingFace(t, preTokenizer, []string{"12", "123"}, []string{"1|2", "12|3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bpe.Encode("12312"); !reflect.DeepEqual(got, []int{257, 256}) {
+		t.Errorf("Encode(12312) = %v, want the number cut after 3 digits", got)
+	}
+}
+
+func TestHuggingFaceUnsupported(t *testing.T) {
+	for name, tokenizer := range map[string]string{
+		"SentencePiece": `{
+			"pre_tokenizer": {"type": "Metaspace", "replacement": "▁"},
+			"model": {"type": "BPE", "byte_fallback": true, "vocab": {"▁hello": 0}, "merges": []}
+		}`,
+		"Metaspace": `{
+			"pre_tokenizer": {"type": "Metaspace", "replacement": "▁"},
+			"model": {"type": "BPE", "vocab": {"▁hello": 0}, "merges": []}
+		}`,
+		"no pre-tokenizer": `{"model": {"type": "BPE", "vocab": {"a": 0}, "merges": []}}`,
+		"Unigram":          `{"model": {"type": "Unigram", "vocab": []}}`,
+		"other split": `{
+			"pre_tokenizer": {"type": "Sequence", "pretokenizers": [
+				{"type": "Split", "pattern": {"Regex": "\\p{N}"}},
+				{"type": "ByteLevel", "use_regex": false}
+			]},
+			"model": {"type": "BPE", "vocab": {"a": 0}, "merges": []}
+		}`,
+		"missing bytes": `{
+			"pre_tokenizer": {"type": "ByteLevel", "use_regex": true},
+			"model": {"type": "BPE", "vocab": {"a": 0}, "merges": []}
+		}`,
+	} {
+		path := filepath.Join(t.TempDir(), "tokenizer.json")
+		if err := os.WriteFile(path, []byte(tokenizer), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadHuggingFace(path); !errors.Is(err, ErrUnsupportedVocabulary) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedVocabulary", name, err)
+		}
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	h := NewHeuristic()
+	for _, tt := range []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		// Characters, not bytes: four 3-byte runes are one token.
+		{"日本語だ", 1},
+	} {
+		if got := h.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package tokenizer
+
+// byteLevelDecoder maps the printable runes GPT-2 style tokenizers use in
+// their vocabularies back to the raw bytes they stand for.
+var byteLevelDecoder = func() map[rune]byte {
+	decoder := make(map[rune]byte, 256)
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			decoder[rune(b)] = byte(b)
+			continue
+		}
+
+		decoder[rune(256+n)] = byte(b)
+		n++
+	}
+
+	return decoder
+}()
+
+func fromByteLevel(token string) string {
+	b := make([]byte, 0, len(token))
+	for _, r := range token {
+		if c, ok := byteLevelDecoder[r]; ok {
+			b = append(b, c)
+			continue
+		}
+
+		b = append(b, string(r)...)
+	}
+
+	return string(b)
+}
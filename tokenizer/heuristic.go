@@ -0,0 +1,29 @@
+package tokenizer
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// Heuristic estimates token counts from the text length when no vocabulary
+// is available for the model.
+type Heuristic struct {
+	CharsPerToken float64
+}
+
+func NewHeuristic() *Heuristic {
+	return &Heuristic{CharsPerToken: 4}
+}
+
+func (h *Heuristic) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	charsPerToken := h.CharsPerToken
+	if charsPerToken <= 0 {
+		charsPerToken = 4
+	}
+
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / charsPerToken))
+}
@@ -0,0 +1,150 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// pretokenize splits text into the pieces BPE merges within. It follows the
+// cl100k pattern by hand because Go's regexp has no lookahead:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func pretokenize(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		n := nextPiece(text)
+		pieces = append(pieces, text[:n])
+		text = text[n:]
+	}
+
+	return pieces
+}
+
+func nextPiece(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+
+	if r == '\'' {
+		for _, c := range contractions {
+			if len(s) >= len(c) && strings.EqualFold(s[:len(c)], c) {
+				return len(c)
+			}
+		}
+	}
+
+	if unicode.IsLetter(r) {
+		return size + scan(s[size:], unicode.IsLetter, -1)
+	}
+
+	if r != '\r' && r != '\n' && !unicode.IsNumber(r) {
+		if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsLetter(next) {
+			return size + scan(s[size:], unicode.IsLetter, -1)
+		}
+	}
+
+	if unicode.IsNumber(r) {
+		return size + scan(s[size:], unicode.IsNumber, 2)
+	}
+
+	start := 0
+	if r == ' ' {
+		start = size
+	}
+
+	if n := scan(s[start:], isSymbol, -1); n > 0 {
+		end := start + n
+		return end + scan(s[end:], isNewline, -1)
+	}
+
+	if unicode.IsSpace(r) {
+		n := scan(s, unicode.IsSpace, -1)
+		if i := strings.LastIndexAny(s[:n], "\r\n"); i >= 0 {
+			return i + 1
+		}
+
+		// Leave the last space to prefix the following word.
+		if n < len(s) && n > size {
+			_, last := utf8.DecodeLastRuneInString(s[:n])
+			return n - last
+		}
+
+		return n
+	}
+
+	return size
+}
+
+// pretokenizeGPT2 splits text with the GPT-2 pattern, which has case
+// sensitive contractions, unbounded numbers and only lets a space prefix a
+// word:
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+func pretokenizeGPT2(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		n := nextPieceGPT2(text)
+		pieces = append(pieces, text[:n])
+		text = text[n:]
+	}
+
+	return pieces
+}
+
+func nextPieceGPT2(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+
+	if r == '\'' {
+		for _, c := range contractions {
+			if strings.HasPrefix(s, c) {
+				return len(c)
+			}
+		}
+	}
+
+	start := 0
+	if r == ' ' {
+		start = size
+	}
+
+	for _, class := range []func(rune) bool{unicode.IsLetter, unicode.IsNumber, isSymbol} {
+		if n := scan(s[start:], class, -1); n > 0 {
+			return start + n
+		}
+	}
+
+	// Only whitespace is left.
+	n := scan(s, unicode.IsSpace, -1)
+	if n < len(s) && n > size {
+		_, last := utf8.DecodeLastRuneInString(s[:n])
+		return n - last
+	}
+
+	return n
+}
+
+// scan returns the byte length of the prefix of s whose runes satisfy fn,
+// reading at most limit runes when limit is not negative.
+func scan(s string, fn func(rune) bool, limit int) int {
+	n := 0
+	for n < len(s) && limit != 0 {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !fn(r) {
+			break
+		}
+
+		n += size
+		limit--
+	}
+
+	return n
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
@@ -0,0 +1,47 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPretokenize(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"I'm fine", []string{"I", "'m", " fine"}},
+		{"HELLO'S", []string{"HELLO", "'S"}},
+		{"1234567", []string{"123", "456", "7"}},
+		{"hello  world", []string{"hello", " ", " world"}},
+		{"line1\n\nline2", []string{"line", "1", "\n\n", "line", "2"}},
+		{"x = foo(bar);\n", []string{"x", " =", " foo", "(bar", ");\n"}},
+		{"trailing  ", []string{"trailing", "  "}},
+		{"naïve café", []string{"naïve", " café"}},
+	} {
+		if got := pretokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pretokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPretokenizeGPT2(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"I'm fine", []string{"I", "'m", " fine"}},
+		{"HELLO'S", []string{"HELLO", "'", "S"}},
+		{"1234567", []string{"1234567"}},
+		{"hello  world", []string{"hello", " ", " world"}},
+		{"a\n\nb", []string{"a", "\n", "\n", "b"}},
+		{"x = foo(bar);", []string{"x", " =", " foo", "(", "bar", ");"}},
+		{"trailing  ", []string{"trailing", "  "}},
+	} {
+		if got := pretokenizeGPT2(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pretokenizeGPT2(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package gochain_test
+
+import (
+	"github.com/ryanbekhen/gochain"
+	"reflect"
+	"testing"
+)
+
+// unitTokenizer makes every message cost exactly MessageOverhead + 2
+// tokens, one for the role and one for the content.
+type unitTokenizer struct{}
+
+func (unitTokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	return 1
+}
+
+const perMessage = gochain.MessageOverhead + 2
+
+func transcript(messages []gochain.Message) []string {
+	r := make([]string, len(messages))
+	for i, m := range messages {
+		r[i] = m.Role + ":" + m.Content
+	}
+
+	return r
+}
+
+func TestTrimMessages(t *testing.T) {
+	history := []gochain.Message{
+		{Role: "system", Content: "s"},
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "call"},
+		{Role: "tool", Content: "r1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "call"},
+		{Role: "tool", Content: "r2"},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		messages int
+		want     []string
+	}{
+		{"everything fits", 8, transcript(history)},
+		{"drops the oldest", 7, []string{"system:s", "assistant:call", "tool:r1", "assistant:a1", "user:q2", "assistant:call", "tool:r2"}},
+		{"drops an orphaned tool message", 6, []string{"system:s", "assistant:a1", "user:q2", "assistant:call", "tool:r2"}},
+		{"keeps the current turn", 4, []string{"system:s", "user:q2", "assistant:call", "tool:r2"}},
+		{"keeps the current turn over the budget", 1, []string{"system:s", "user:q2", "assistant:call", "tool:r2"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gochain.TrimMessages(unitTokenizer{}, history, tt.messages*perMessage)
+
+			if !reflect.DeepEqual(transcript(got), tt.want) {
+				t.Errorf("TrimMessages() = %q, want %q", transcript(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimMessagesWithoutUser(t *testing.T) {
+	messages := []gochain.Message{
+		{Role: "system", Content: "s"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "assistant", Content: "a2"},
+	}
+
+	got := gochain.TrimMessages(unitTokenizer{}, messages, 2*perMessage)
+	if want := []string{"system:s", "assistant:a2"}; !reflect.DeepEqual(transcript(got), want) {
+		t.Errorf("TrimMessages() = %q, want %q", transcript(got), want)
+	}
+}
@@ -0,0 +1,49 @@
+package gochain
+
+import "context"
+
+// ToolRetriever picks the functions relevant to a query so large toolsets
+// do not have to fit in every prompt.
+type ToolRetriever interface {
+	RetrieveTools(ctx context.Context, query string, functions []*Function) ([]*Function, error)
+}
+
+// EmbeddingToolRetriever returns the K functions whose name and
+// description are most similar to the query and at least Threshold
+// similar. Function embeddings are computed once and cached.
+type EmbeddingToolRetriever struct {
+	Embedder  Embedder
+	K         int
+	Threshold float64
+
+	ranker embeddingRanker
+}
+
+func NewEmbeddingToolRetriever(embedder Embedder, k int, threshold float64) *EmbeddingToolRetriever {
+	return &EmbeddingToolRetriever{Embedder: embedder, K: k, Threshold: threshold}
+}
+
+func (r *EmbeddingToolRetriever) RetrieveTools(ctx context.Context, query string, functions []*Function) ([]*Function, error) {
+	texts := make([]string, len(functions))
+	for i, f := range functions {
+		texts[i] = toolText(f)
+	}
+
+	ranked, err := r.ranker.rank(ctx, r.Embedder, query, texts, r.K, r.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]*Function, len(ranked))
+	for i, index := range ranked {
+		selected[i] = functions[index]
+	}
+
+	return selected, nil
+}
+
+// toolText is what gets embedded for a function, and the cache key, so a
+// changed description is embedded again.
+func toolText(f *Function) string {
+	return f.Name + ": " + f.Description
+}
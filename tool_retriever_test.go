@@ -0,0 +1,122 @@
+package gochain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var tools = []*gochain.Function{
+	{Name: "get_weather", Description: "Get the current weather for a city"},
+	{Name: "book_flight", Description: "Book a flight to a city"},
+	{Name: "send_email", Description: "Send an email to a contact"},
+}
+
+func toolNames(functions []*gochain.Function) []string {
+	names := make([]string, len(functions))
+	for i, f := range functions {
+		names[i] = f.Name
+	}
+
+	return names
+}
+
+func TestEmbeddingToolRetriever(t *testing.T) {
+	fake := gochaintest.NewFakeLLM()
+	retriever := gochain.NewEmbeddingToolRetriever(fake, 1, 0)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"what is the weather in Paris", []string{"get_weather"}},
+		{"send an email to Ana", []string{"send_email"}},
+	} {
+		got, err := retriever.RetrieveTools(context.Background(), tt.query, tools)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(toolNames(got), tt.want) {
+			t.Errorf("RetrieveTools(%q) = %q, want %q", tt.query, toolNames(got), tt.want)
+		}
+	}
+
+	// The tools are embedded once, then only the queries.
+	if calls := fake.EmbedCalls(); len(calls) != 3 || len(calls[0]) != len(tools) {
+		t.Errorf("embed calls = %q", calls)
+	}
+
+	// A changed description is embedded again.
+	changed := append([]*gochain.Function{{Name: "get_weather", Description: "Forecast"}}, tools[1:]...)
+	if _, err := retriever.RetrieveTools(context.Background(), "weather", changed); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := fake.EmbedCalls(); len(calls[3]) != 1 || calls[3][0] != "get_weather: Forecast" {
+		t.Errorf("embed calls = %q, want the changed tool only", calls[3:])
+	}
+}
+
+func TestEmbeddingToolRetrieverThreshold(t *testing.T) {
+	retriever := gochain.NewEmbeddingToolRetriever(gochaintest.NewFakeLLM(), 0, 0.3)
+
+	got, err := retriever.RetrieveTools(context.Background(), "city", tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both tools mentioning a city, in any order.
+	names := toolNames(got)
+	sort.Strings(names)
+	if want := []string{"book_flight", "get_weather"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("RetrieveTools() = %q, want %q", names, want)
+	}
+}
+
+func TestEmbeddingToolRetrieverCacheIsBounded(t *testing.T) {
+	fake := gochaintest.NewFakeLLM()
+	retriever := gochain.NewEmbeddingToolRetriever(fake, 1, 0)
+
+	if _, err := retriever.RetrieveTools(context.Background(), "weather", tools); err != nil {
+		t.Fatal(err)
+	}
+
+	many := make([]*gochain.Function, 5000)
+	for i := range many {
+		many[i] = &gochain.Function{Name: fmt.Sprintf("tool_%d", i)}
+	}
+
+	if _, err := retriever.RetrieveTools(context.Background(), "weather", many); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first tools were evicted to make room.
+	if _, err := retriever.RetrieveTools(context.Background(), "weather", tools); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := fake.EmbedCalls(); len(calls) != 6 || len(calls[4]) != len(tools) {
+		t.Errorf("%d embed calls, want the tools embedded again", len(calls))
+	}
+}
+
+// shortEmbedder drops the last vector of every batch.
+type shortEmbedder struct{}
+
+func (shortEmbedder) Embed(_ context.Context, texts []string) ([][]float64, error) {
+	return make([][]float64, len(texts)-1), nil
+}
+
+func TestEmbeddingToolRetrieverCountMismatch(t *testing.T) {
+	retriever := gochain.NewEmbeddingToolRetriever(shortEmbedder{}, 1, 0)
+
+	if _, err := retriever.RetrieveTools(context.Background(), "weather", tools); !errors.Is(err, gochain.ErrEmbeddingCount) {
+		t.Errorf("err = %v, want ErrEmbeddingCount", err)
+	}
+}
@@ -0,0 +1,72 @@
+package vectorstore
+
+import "math"
+
+// Distance is the measure used to compare embeddings.
+type Distance int
+
+const (
+	Cosine Distance = iota
+	DotProduct
+	Euclidean
+)
+
+// prepare returns the vector as stored. Cosine vectors are normalized so
+// comparing them is a dot product.
+func (d Distance) prepare(v []float64) []float64 {
+	c := append([]float64{}, v...)
+	if d != Cosine {
+		return c
+	}
+
+	var norm float64
+	for _, x := range c {
+		norm += x * x
+	}
+
+	if norm == 0 {
+		return c
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range c {
+		c[i] /= norm
+	}
+
+	return c
+}
+
+// distance is lower for closer vectors, as the index needs.
+func (d Distance) distance(a, b []float64) float64 {
+	switch d {
+	case Euclidean:
+		var sum float64
+		for i := range a {
+			diff := a[i] - b[i]
+			sum += diff * diff
+		}
+		return math.Sqrt(sum)
+	case Cosine:
+		return 1 - dot(a, b)
+	default:
+		return -dot(a, b)
+	}
+}
+
+// score turns a distance back into the Result score.
+func (d Distance) score(distance float64) float64 {
+	if d == Cosine {
+		return 1 - distance
+	}
+
+	return -distance
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+
+	return sum
+}
@@ -0,0 +1,261 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+)
+
+// HNSWConfig tunes the HNSW graph. M is the number of links per node,
+// EfConstruction and EfSearch the candidate list sizes when inserting and
+// searching; larger values trade speed for recall. Zero values use 16,
+// 200 and 64.
+type HNSWConfig struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+	// Seed makes the graph layout reproducible.
+	Seed int64
+}
+
+// hnsw is a Hierarchical Navigable Small World graph (Malkov and Yashunin,
+// 2016). Deleted nodes stay in the graph to keep it connected and are only
+// skipped in results.
+type hnsw struct {
+	cfg      HNSWConfig
+	mL       float64
+	rng      *rand.Rand
+	vector   func(node int) []float64
+	distance func(a, b []float64) float64
+
+	links    [][][]int32
+	entry    int
+	maxLevel int
+}
+
+func newHNSW(cfg HNSWConfig, vector func(int) []float64, distance func(a, b []float64) float64) *hnsw {
+	if cfg.M <= 0 {
+		cfg.M = defaultHNSWM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = defaultHNSWEfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = defaultHNSWEfSearch
+	}
+
+	return &hnsw{
+		cfg:      cfg,
+		mL:       1 / math.Log(float64(cfg.M)),
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		vector:   vector,
+		distance: distance,
+		entry:    -1,
+	}
+}
+
+func (h *hnsw) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.cfg.M
+	}
+
+	return h.cfg.M
+}
+
+func (h *hnsw) add(node int) {
+	level := int(-math.Log(1-h.rng.Float64()) * h.mL)
+
+	for len(h.links) <= node {
+		h.links = append(h.links, nil)
+	}
+	h.links[node] = make([][]int32, level+1)
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = node, level
+		return
+	}
+
+	q := h.vector(node)
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+
+	eps := []candidate{{node: ep, distance: h.distance(q, h.vector(ep))}}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(q, eps, h.cfg.EfConstruction, l)
+
+		neighbors := h.selectNeighbors(found, h.cfg.M)
+		h.links[node][l] = neighbors
+
+		for _, nb := range neighbors {
+			h.connect(int(nb), node, l)
+		}
+
+		eps = found
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = node, level
+	}
+}
+
+// connect links from to node, pruning the links of from when it has too
+// many.
+func (h *hnsw) connect(from, node, level int) {
+	links := append(h.links[from][level], int32(node))
+	if len(links) <= h.maxLinks(level) {
+		h.links[from][level] = links
+		return
+	}
+
+	v := h.vector(from)
+	candidates := make([]candidate, len(links))
+	for i, nb := range links {
+		candidates[i] = candidate{node: int(nb), distance: h.distance(v, h.vector(int(nb)))}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	h.links[from][level] = h.selectNeighbors(candidates, h.maxLinks(level))
+}
+
+// selectNeighbors picks up to m of the candidates, sorted closest first,
+// preferring ones that are not closer to an already picked neighbor than
+// to the node, so links spread in every direction.
+func (h *hnsw) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var pruned []int32
+
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		v := h.vector(c.node)
+		keep := true
+		for _, s := range selected {
+			if h.distance(v, h.vector(int(s))) < c.distance {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			selected = append(selected, int32(c.node))
+		} else {
+			pruned = append(pruned, int32(c.node))
+		}
+	}
+
+	for _, p := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, p)
+	}
+
+	return selected
+}
+
+// greedy walks towards q on one level and returns the closest node found.
+func (h *hnsw) greedy(q []float64, ep, level int) int {
+	best := h.distance(q, h.vector(ep))
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.links[ep][level] {
+			if d := h.distance(q, h.vector(int(nb))); d < best {
+				ep, best, changed = int(nb), d, true
+			}
+		}
+	}
+
+	return ep
+}
+
+// searchLayer returns up to ef nodes close to q on one level, closest
+// first.
+func (h *hnsw) searchLayer(q []float64, eps []candidate, ef, level int) []candidate {
+	visited := make(map[int]bool, ef*4)
+	candidates := &minHeap{}
+	results := &maxHeap{}
+
+	for _, ep := range eps {
+		visited[ep.node] = true
+		heap.Push(candidates, ep)
+		heap.Push(results, ep)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.distance > (*results)[0].distance {
+			break
+		}
+
+		for _, nb := range h.links[c.node][level] {
+			n := int(nb)
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			d := h.distance(q, h.vector(n))
+			if results.Len() < ef || d < (*results)[0].distance {
+				heap.Push(candidates, candidate{node: n, distance: d})
+				heap.Push(results, candidate{node: n, distance: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	return results.sorted()
+}
+
+// search widens the candidate list until k accepted nodes are found, so
+// restrictive filters still return results, and falls back to comparing
+// every node once the list covers the graph.
+func (h *hnsw) search(q []float64, k int, accept func(int) bool) []candidate {
+	if h.entry < 0 {
+		return nil
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+
+	eps := []candidate{{node: ep, distance: h.distance(q, h.vector(ep))}}
+	for ef := max(h.cfg.EfSearch, k); ; ef *= 2 {
+		if ef >= len(h.links) {
+			return h.scan(q, k, accept)
+		}
+
+		var accepted []candidate
+		for _, c := range h.searchLayer(q, eps, ef, 0) {
+			if accept(c.node) {
+				accepted = append(accepted, c)
+			}
+		}
+
+		if len(accepted) >= k {
+			return accepted[:k]
+		}
+	}
+}
+
+func (h *hnsw) scan(q []float64, k int, accept func(int) bool) []candidate {
+	return topK(len(h.links), k, accept, func(node int) float64 {
+		return h.distance(q, h.vector(node))
+	})
+}
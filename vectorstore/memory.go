@@ -0,0 +1,347 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"sync"
+)
+
+type Option func(*Memory)
+
+// WithDistance sets the distance used to compare embeddings. Defaults to
+// Cosine.
+func WithDistance(d Distance) Option {
+	return func(m *Memory) {
+		m.distance = d
+	}
+}
+
+// WithHNSW indexes documents in an HNSW graph instead of comparing the
+// query with every document. Searches become approximate and sub-linear.
+func WithHNSW(cfg HNSWConfig) Option {
+	return func(m *Memory) {
+		m.hnswConfig = &cfg
+	}
+}
+
+// index finds the nodes closest to a query among those accepted.
+type index interface {
+	add(node int)
+	search(query []float64, k int, accept func(node int) bool) []candidate
+}
+
+type candidate struct {
+	node     int
+	distance float64
+}
+
+type entry struct {
+	id      string
+	doc     gochain.Document
+	vector  []float64
+	deleted bool
+}
+
+// Memory is an in-memory VectorStore. Deleted and replaced documents stay
+// in the index as tombstones until Compact is called.
+type Memory struct {
+	embedder   gochain.Embedder
+	distance   Distance
+	hnswConfig *HNSWConfig
+
+	mu      sync.RWMutex
+	entries []*entry
+	ids     map[string]int
+	dim     int
+	index   index
+}
+
+func NewMemory(embedder gochain.Embedder, opts ...Option) *Memory {
+	m := &Memory{embedder: embedder, ids: map[string]int{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.index = m.newIndex()
+
+	return m
+}
+
+func (m *Memory) newIndex() index {
+	if m.hnswConfig != nil {
+		return newHNSW(*m.hnswConfig, m.vector, m.distance.distance)
+	}
+
+	return &bruteForce{m: m}
+}
+
+func (m *Memory) vector(node int) []float64 {
+	return m.entries[node].vector
+}
+
+func (m *Memory) AddDocuments(ctx context.Context, docs []gochain.Document, opts ...AddOption) ([]string, error) {
+	texts := make([]string, len(docs))
+	for i, d := range docs {
+		texts[i] = d.PageContent
+	}
+
+	vectors, err := m.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.AddVectors(docs, vectors, opts...)
+}
+
+// AddVectors stores documents with embeddings computed elsewhere.
+func (m *Memory) AddVectors(docs []gochain.Document, vectors [][]float64, opts ...AddOption) ([]string, error) {
+	var o addOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(vectors) != len(docs) {
+		return nil, ErrEmbeddingCount
+	}
+
+	if o.ids != nil && len(o.ids) != len(docs) {
+		return nil, fmt.Errorf("vectorstore: %d ids for %d documents", len(o.ids), len(docs))
+	}
+
+	ids := o.ids
+	if ids == nil {
+		ids = make([]string, len(docs))
+		for i := range ids {
+			ids[i] = newID()
+		}
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateID, id)
+		}
+		seen[id] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, v := range vectors {
+		dim := m.dim
+		if dim == 0 {
+			dim = len(vectors[0])
+		}
+
+		if len(v) != dim || dim == 0 {
+			return nil, ErrDimensionMismatch
+		}
+	}
+
+	if m.dim == 0 && len(vectors) > 0 {
+		m.dim = len(vectors[0])
+	}
+
+	for i, doc := range docs {
+		if node, ok := m.ids[ids[i]]; ok {
+			m.entries[node].deleted = true
+		}
+
+		node := len(m.entries)
+		m.entries = append(m.entries, &entry{id: ids[i], doc: doc, vector: m.distance.prepare(vectors[i])})
+		m.ids[ids[i]] = node
+		m.index.add(node)
+	}
+
+	return ids, nil
+}
+
+func (m *Memory) SimilaritySearch(ctx context.Context, query string, k int, opts ...SearchOption) ([]Result, error) {
+	vectors, err := m.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vectors) != 1 {
+		return nil, ErrEmbeddingCount
+	}
+
+	return m.SimilaritySearchByVector(vectors[0], k, opts...)
+}
+
+// SimilaritySearchByVector searches with an embedding computed elsewhere.
+func (m *Memory) SimilaritySearchByVector(vector []float64, k int, opts ...SearchOption) ([]Result, error) {
+	o := newSearchOptions(opts)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.entries) == 0 || k <= 0 {
+		return nil, nil
+	}
+
+	if len(vector) != m.dim {
+		return nil, ErrDimensionMismatch
+	}
+
+	accept := func(node int) bool {
+		e := m.entries[node]
+		return !e.deleted && (o.filter == nil || o.filter(e.doc.Metadata))
+	}
+
+	var results []Result
+	for _, c := range m.index.search(m.distance.prepare(vector), k, accept) {
+		score := m.distance.score(c.distance)
+		if o.threshold != nil && score < *o.threshold {
+			continue
+		}
+
+		e := m.entries[c.node]
+		results = append(results, Result{ID: e.id, Document: e.doc, Score: score})
+	}
+
+	return results, nil
+}
+
+func (m *Memory) Delete(_ context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if node, ok := m.ids[id]; ok {
+			m.entries[node].deleted = true
+			delete(m.ids, id)
+		}
+	}
+
+	return nil
+}
+
+// Compact drops deleted and replaced documents and rebuilds the index over
+// the remaining ones. For HNSW this costs as much as adding them again.
+func (m *Memory) Compact() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.ids) == len(m.entries) {
+		return
+	}
+
+	live := make([]*entry, 0, len(m.ids))
+	for _, e := range m.entries {
+		if !e.deleted {
+			live = append(live, e)
+		}
+	}
+
+	m.entries = live
+	m.ids = make(map[string]int, len(live))
+	m.index = m.newIndex()
+	for node, e := range live {
+		m.ids[e.id] = node
+		m.index.add(node)
+	}
+}
+
+// Get returns the document stored under id.
+func (m *Memory) Get(id string) (gochain.Document, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.ids[id]
+	if !ok {
+		return gochain.Document{}, false
+	}
+
+	return m.entries[node].doc, true
+}
+
+// Len returns the number of stored documents.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.ids)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// bruteForce compares the query with every document.
+type bruteForce struct {
+	m *Memory
+}
+
+func (b *bruteForce) add(int) {}
+
+func (b *bruteForce) search(query []float64, k int, accept func(int) bool) []candidate {
+	return topK(len(b.m.entries), k, accept, func(node int) float64 {
+		return b.m.distance.distance(query, b.m.entries[node].vector)
+	})
+}
+
+// topK returns the k accepted nodes of 0..n-1 with the lowest distance,
+// closest first.
+func topK(n, k int, accept func(int) bool, distance func(int) float64) []candidate {
+	best := &maxHeap{}
+	for node := 0; node < n; node++ {
+		if !accept(node) {
+			continue
+		}
+
+		d := distance(node)
+		if best.Len() < k {
+			heap.Push(best, candidate{node: node, distance: d})
+		} else if d < (*best)[0].distance {
+			(*best)[0] = candidate{node: node, distance: d}
+			heap.Fix(best, 0)
+		}
+	}
+
+	return best.sorted()
+}
+
+// minHeap and maxHeap order candidates by distance.
+type minHeap []candidate
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].distance < h[j].distance }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].distance > h[j].distance }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// sorted empties the heap, returning its candidates closest first.
+func (h *maxHeap) sorted() []candidate {
+	out := make([]candidate, h.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(h).(candidate)
+	}
+
+	return out
+}
@@ -0,0 +1,163 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotVersion = 2
+
+func init() {
+	// Metadata values are encoded as interfaces, gob has to know every
+	// concrete type beyond the basic ones.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+}
+
+// snapshot is the file format of a saved Memory. Metadata values are gob
+// encoded with their type, so an int64 page number is an int64 again after
+// Load.
+type snapshot struct {
+	Version  int
+	Distance Distance
+	Dim      int
+	Entries  []snapshotEntry
+	HNSW     *hnswSnapshot
+}
+
+type snapshotEntry struct {
+	ID       string
+	Content  string
+	Metadata map[string]interface{}
+	Vector   []float64
+}
+
+type hnswSnapshot struct {
+	Config   HNSWConfig
+	Links    [][][]int32
+	Entry    int
+	MaxLevel int
+}
+
+// Open returns a store loaded from path, or an empty one when the file
+// does not exist yet.
+func Open(path string, embedder gochain.Embedder, opts ...Option) (*Memory, error) {
+	m := NewMemory(embedder, opts...)
+
+	err := m.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Save writes the live documents to path, replacing the file atomically.
+// The HNSW graph is saved along when the store has no tombstones, so Load
+// can skip rebuilding it; call Compact first to keep it.
+//
+// Metadata values of types other than the basic ones, []interface{},
+// map[string]interface{} and time.Time must be registered with
+// gob.Register.
+func (m *Memory) Save(path string) error {
+	m.mu.RLock()
+	s := snapshot{Version: snapshotVersion, Distance: m.distance, Dim: m.dim}
+	for _, e := range m.entries {
+		if e.deleted {
+			continue
+		}
+
+		s.Entries = append(s.Entries, snapshotEntry{
+			ID:       e.id,
+			Content:  e.doc.PageContent,
+			Metadata: e.doc.Metadata,
+			Vector:   e.vector,
+		})
+	}
+
+	if h, ok := m.index.(*hnsw); ok && len(m.ids) == len(m.entries) {
+		s.HNSW = &hnswSnapshot{Config: h.cfg, Links: h.links, Entry: h.entry, MaxLevel: h.maxLevel}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".vectorstore-*")
+	if err != nil {
+		m.mu.RUnlock()
+		return err
+	}
+
+	err = gob.NewEncoder(f).Encode(&s)
+	m.mu.RUnlock()
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Load replaces the contents of the store with the file at path. A saved
+// HNSW graph is reused when the store indexes with HNSW, otherwise the
+// index is rebuilt.
+func (m *Memory) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var s snapshot
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		return err
+	}
+
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("vectorstore: unsupported file version %d", s.Version)
+	}
+
+	if s.Distance != m.distance {
+		return fmt.Errorf("vectorstore: %s was saved with another distance", path)
+	}
+
+	entries := make([]*entry, len(s.Entries))
+	ids := make(map[string]int, len(s.Entries))
+	for i, se := range s.Entries {
+		entries[i] = &entry{
+			id:     se.ID,
+			doc:    gochain.Document{PageContent: se.Content, Metadata: se.Metadata},
+			vector: se.Vector,
+		}
+		ids[se.ID] = i
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries, m.ids, m.dim = entries, ids, s.Dim
+	m.index = m.newIndex()
+
+	if h, ok := m.index.(*hnsw); ok && s.HNSW != nil && len(s.HNSW.Links) == len(entries) {
+		h.links, h.entry, h.maxLevel = s.HNSW.Links, s.HNSW.Entry, s.HNSW.MaxLevel
+		return nil
+	}
+
+	for node := range m.entries {
+		m.index.add(node)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package vectorstore stores embedded documents and finds the ones most
+// similar to a query.
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"github.com/ryanbekhen/gochain"
+	"reflect"
+)
+
+var (
+	ErrDimensionMismatch = errors.New("vectorstore: embedding dimension mismatch")
+	ErrEmbeddingCount    = gochain.ErrEmbeddingCount
+	ErrDuplicateID       = errors.New("vectorstore: duplicate document id")
+)
+
+type VectorStore interface {
+	// AddDocuments embeds and stores docs, returning their ids.
+	AddDocuments(ctx context.Context, docs []gochain.Document, opts ...AddOption) ([]string, error)
+	// SimilaritySearch returns up to k documents closest to query, best
+	// first.
+	SimilaritySearch(ctx context.Context, query string, k int, opts ...SearchOption) ([]Result, error)
+	Delete(ctx context.Context, ids ...string) error
+}
+
+// Result is a matched document. Score is higher for closer documents: the
+// cosine similarity, the dot product or the negated Euclidean distance,
+// depending on the store's Distance.
+type Result struct {
+	ID       string
+	Document gochain.Document
+	Score    float64
+}
+
+type AddOption func(*addOptions)
+
+type addOptions struct {
+	ids []string
+}
+
+// WithIDs stores the documents under the given ids instead of generated
+// ones. Adding an id that exists replaces the document.
+func WithIDs(ids ...string) AddOption {
+	return func(o *addOptions) {
+		o.ids = ids
+	}
+}
+
+type SearchOption func(*searchOptions)
+
+type searchOptions struct {
+	filter    Filter
+	threshold *float64
+}
+
+func newSearchOptions(opts []SearchOption) searchOptions {
+	var o searchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithFilter only returns documents whose metadata passes filter.
+func WithFilter(filter Filter) SearchOption {
+	return func(o *searchOptions) {
+		o.filter = filter
+	}
+}
+
+// WithScoreThreshold drops results scoring below threshold.
+func WithScoreThreshold(threshold float64) SearchOption {
+	return func(o *searchOptions) {
+		o.threshold = &threshold
+	}
+}
+
+// Filter selects documents by metadata.
+type Filter func(metadata map[string]interface{}) bool
+
+// Equal matches documents whose metadata key holds value.
+func Equal(key string, value interface{}) Filter {
+	return func(metadata map[string]interface{}) bool {
+		v, ok := metadata[key]
+		return ok && equalValue(v, value)
+	}
+}
+
+// In matches documents whose metadata key holds one of values.
+func In(key string, values ...interface{}) Filter {
+	return func(metadata map[string]interface{}) bool {
+		v, ok := metadata[key]
+		if !ok {
+			return false
+		}
+
+		for _, value := range values {
+			if equalValue(v, value) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Exists matches documents that have the metadata key.
+func Exists(key string) Filter {
+	return func(metadata map[string]interface{}) bool {
+		_, ok := metadata[key]
+		return ok
+	}
+}
+
+func And(filters ...Filter) Filter {
+	return func(metadata map[string]interface{}) bool {
+		for _, f := range filters {
+			if !f(metadata) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func Or(filters ...Filter) Filter {
+	return func(metadata map[string]interface{}) bool {
+		for _, f := range filters {
+			if f(metadata) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func Not(filter Filter) Filter {
+	return func(metadata map[string]interface{}) bool {
+		return !filter(metadata)
+	}
+}
+
+// equalValue compares metadata values, treating numbers of different
+// types as equal when their values are, so Equal("page", 1) matches a page
+// stored as an int64.
+func equalValue(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
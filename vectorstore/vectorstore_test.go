@@ -0,0 +1,375 @@
+package vectorstore_test
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/ryanbekhen/gochain"
+	"github.com/ryanbekhen/gochain/gochaintest"
+	"github.com/ryanbekhen/gochain/vectorstore"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// randomVectors returns n reproducible vectors of dim dimensions.
+func randomVectors(seed int64, n, dim int) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.NormFloat64()
+		}
+	}
+
+	return vectors
+}
+
+// fill adds one document per vector, with ids "0", "1", ... and the index
+// under "n".
+func fill(t *testing.T, m *vectorstore.Memory, vectors [][]float64) {
+	t.Helper()
+
+	docs := make([]gochain.Document, len(vectors))
+	ids := make([]string, len(vectors))
+	for i := range vectors {
+		ids[i] = fmt.Sprint(i)
+		docs[i] = gochain.Document{PageContent: "doc " + ids[i], Metadata: map[string]interface{}{"n": i, "even": i%2 == 0}}
+	}
+
+	if _, err := m.AddVectors(docs, vectors, vectorstore.WithIDs(ids...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func search(t *testing.T, m *vectorstore.Memory, query []float64, k int, opts ...vectorstore.SearchOption) []string {
+	t.Helper()
+
+	results, err := m.SimilaritySearchByVector(query, k, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+
+	return ids
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vectors := randomVectors(1, 2000, 16)
+	queries := randomVectors(2, 50, 16)
+
+	for _, distance := range []vectorstore.Distance{vectorstore.Cosine, vectorstore.DotProduct, vectorstore.Euclidean} {
+		exact := vectorstore.NewMemory(nil, vectorstore.WithDistance(distance))
+		approx := vectorstore.NewMemory(nil, vectorstore.WithDistance(distance), vectorstore.WithHNSW(vectorstore.HNSWConfig{Seed: 1}))
+		fill(t, exact, vectors)
+		fill(t, approx, vectors)
+
+		const k = 10
+		found := 0
+		for _, q := range queries {
+			want := map[string]bool{}
+			for _, id := range search(t, exact, q, k) {
+				want[id] = true
+			}
+
+			for _, id := range search(t, approx, q, k) {
+				if want[id] {
+					found++
+				}
+			}
+		}
+
+		if recall := float64(found) / float64(k*len(queries)); recall < 0.95 {
+			t.Errorf("distance %d: recall@%d = %.2f, want at least 0.95", distance, k, recall)
+		}
+	}
+}
+
+func TestBruteForceOrder(t *testing.T) {
+	m := vectorstore.NewMemory(nil)
+	fill(t, m, [][]float64{{1, 0}, {1, 1}, {0, 1}, {-1, 0}})
+
+	results, err := m.SimilaritySearchByVector([]float64{1, 0.1}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i, r := range results {
+		ids = append(ids, r.ID)
+		if i > 0 && r.Score > results[i-1].Score {
+			t.Errorf("results not sorted by score: %+v", results)
+		}
+	}
+
+	if !reflect.DeepEqual(ids, []string{"0", "1", "2"}) {
+		t.Errorf("results = %q", ids)
+	}
+
+	if got := search(t, m, []float64{1, 0.1}, 4, vectorstore.WithScoreThreshold(0.5)); !reflect.DeepEqual(got, []string{"0", "1"}) {
+		t.Errorf("results above the threshold = %q", got)
+	}
+
+	if _, err := m.SimilaritySearchByVector([]float64{1, 0, 0}, 1); !errors.Is(err, vectorstore.ErrDimensionMismatch) {
+		t.Errorf("err = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestDeleteWidensSearch(t *testing.T) {
+	vectors := randomVectors(3, 500, 8)
+	query := vectors[0]
+
+	exact := vectorstore.NewMemory(nil)
+	approx := vectorstore.NewMemory(nil, vectorstore.WithHNSW(vectorstore.HNSWConfig{Seed: 1, EfSearch: 10}))
+	fill(t, exact, vectors)
+	fill(t, approx, vectors)
+
+	// Delete the 100 documents closest to the query: the first candidate
+	// lists only hold tombstones.
+	closest := search(t, exact, query, 100)
+	for _, m := range []*vectorstore.Memory{exact, approx} {
+		if err := m.Delete(context.Background(), closest...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if approx.Len() != 400 {
+		t.Errorf("Len = %d, want 400", approx.Len())
+	}
+
+	if _, ok := approx.Get(closest[0]); ok {
+		t.Error("deleted document still returned by Get")
+	}
+
+	want := search(t, exact, query, 5)
+	if got := search(t, approx, query, 5); len(got) != 5 || !reflect.DeepEqual(got, want) {
+		t.Errorf("results after deletes = %q, want %q", got, want)
+	}
+
+	// A filter accepting only the farthest document widens the search the
+	// same way.
+	live := search(t, exact, query, 400)
+	farthest := live[len(live)-1]
+	n, err := strconv.Atoi(farthest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search(t, approx, query, 5, vectorstore.WithFilter(vectorstore.Equal("n", n))); !reflect.DeepEqual(got, []string{farthest}) {
+		t.Errorf("filtered results = %q, want %s", got, farthest)
+	}
+}
+
+func TestReplaceByID(t *testing.T) {
+	m := vectorstore.NewMemory(nil, vectorstore.WithHNSW(vectorstore.HNSWConfig{Seed: 1}))
+	fill(t, m, [][]float64{{1, 0}, {0, 1}})
+
+	if _, err := m.AddVectors([]gochain.Document{{PageContent: "new"}}, [][]float64{{0, 1}}, vectorstore.WithIDs("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Len() != 2 {
+		t.Errorf("Len = %d, want 2", m.Len())
+	}
+
+	// The old vector of "0" is a tombstone, only the new one matches.
+	results, err := m.SimilaritySearchByVector([]float64{1, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Document.PageContent == "doc 0" || results[1].Document.PageContent == "doc 0" {
+		t.Errorf("results = %+v", results)
+	}
+
+	if _, err := m.AddVectors(make([]gochain.Document, 2), [][]float64{{1, 0}, {0, 1}}, vectorstore.WithIDs("a", "a")); !errors.Is(err, vectorstore.ErrDuplicateID) {
+		t.Errorf("err = %v, want ErrDuplicateID", err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	vectors := randomVectors(4, 200, 8)
+
+	for _, opts := range [][]vectorstore.Option{nil, {vectorstore.WithHNSW(vectorstore.HNSWConfig{Seed: 1})}} {
+		m := vectorstore.NewMemory(nil, opts...)
+		fill(t, m, vectors)
+
+		var deleted []string
+		for i := 0; i < 200; i += 3 {
+			deleted = append(deleted, fmt.Sprint(i))
+		}
+		if err := m.Delete(context.Background(), deleted...); err != nil {
+			t.Fatal(err)
+		}
+
+		before := search(t, m, vectors[0], 10)
+		m.Compact()
+
+		if got := search(t, m, vectors[0], 10); !reflect.DeepEqual(got, before) {
+			t.Errorf("results after Compact = %q, want %q", got, before)
+		}
+
+		if m.Len() != 200-len(deleted) {
+			t.Errorf("Len = %d", m.Len())
+		}
+
+		// Ids still find their documents, and deleted ones stay gone.
+		if doc, ok := m.Get("1"); !ok || doc.PageContent != "doc 1" {
+			t.Errorf("Get(1) = %+v, %v", doc, ok)
+		}
+
+		if _, ok := m.Get("0"); ok {
+			t.Error("Get(0) found a deleted document")
+		}
+	}
+}
+
+type custom struct {
+	Name string
+}
+
+func TestSaveLoad(t *testing.T) {
+	gob.Register(custom{})
+
+	metadata := map[string]interface{}{
+		"int":     7,
+		"int64":   int64(1) << 40,
+		"float":   0.5,
+		"string":  "s",
+		"bool":    true,
+		"nil":     nil,
+		"list":    []interface{}{"a", 1},
+		"strings": []string{"a", "b"},
+		"nested":  map[string]interface{}{"page": int64(3)},
+		"time":    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"custom":  custom{Name: "x"},
+	}
+
+	vectors := randomVectors(5, 100, 8)
+
+	for _, opts := range [][]vectorstore.Option{nil, {vectorstore.WithHNSW(vectorstore.HNSWConfig{Seed: 1})}} {
+		path := filepath.Join(t.TempDir(), "store.gob")
+
+		m := vectorstore.NewMemory(nil, opts...)
+		fill(t, m, vectors)
+		if _, err := m.AddVectors([]gochain.Document{{PageContent: "typed", Metadata: metadata}}, vectors[:1], vectorstore.WithIDs("typed")); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Delete(context.Background(), "5"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := vectorstore.Open(path, nil, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if loaded.Len() != m.Len() {
+			t.Errorf("Len = %d, want %d", loaded.Len(), m.Len())
+		}
+
+		doc, ok := loaded.Get("typed")
+		if !ok || !reflect.DeepEqual(doc.Metadata, metadata) {
+			t.Errorf("metadata after Load = %#v, want %#v", doc.Metadata, metadata)
+		}
+
+		if _, ok := loaded.Get("5"); ok {
+			t.Error("deleted document saved")
+		}
+
+		for _, q := range randomVectors(6, 5, 8) {
+			if got, want := search(t, loaded, q, 5), search(t, m, q, 5); !reflect.DeepEqual(got, want) {
+				t.Errorf("results after Load = %q, want %q", got, want)
+			}
+		}
+
+		// Numbers keep their type, filters match them by value.
+		if got := search(t, loaded, vectors[7], 1, vectorstore.WithFilter(vectorstore.Equal("n", int64(7)))); !reflect.DeepEqual(got, []string{"7"}) {
+			t.Errorf("filtered results = %q", got)
+		}
+	}
+}
+
+func TestSaveLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := vectorstore.Open(filepath.Join(dir, "missing.gob"), nil)
+	if err != nil || m.Len() != 0 {
+		t.Fatalf("Open(missing) = %v, %v", m, err)
+	}
+
+	// Unregistered types fail the save and leave no file behind.
+	type unregistered struct{}
+	if _, err := m.AddVectors([]gochain.Document{{Metadata: map[string]interface{}{"v": unregistered{}}}}, [][]float64{{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Save(filepath.Join(dir, "bad.gob")); err == nil {
+		t.Error("expected an error for an unregistered metadata type")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left after a failed save", len(entries))
+	}
+
+	path := filepath.Join(dir, "cosine.gob")
+	if err := vectorstore.NewMemory(nil).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vectorstore.Open(path, nil, vectorstore.WithDistance(vectorstore.Euclidean)); err == nil {
+		t.Error("expected an error for another distance")
+	}
+}
+
+func TestSimilaritySearch(t *testing.T) {
+	llm := gochaintest.NewFakeLLM()
+	m := vectorstore.NewMemory(llm)
+
+	ids, err := m.AddDocuments(context.Background(), []gochain.Document{
+		{PageContent: "the weather in paris", Metadata: map[string]interface{}{"lang": "en"}},
+		{PageContent: "go generics tutorial", Metadata: map[string]interface{}{"lang": "en"}},
+		{PageContent: "le temps à paris", Metadata: map[string]interface{}{"lang": "fr"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 3 || ids[0] == "" || ids[0] == ids[1] {
+		t.Errorf("ids = %q", ids)
+	}
+
+	results, err := m.SimilaritySearch(context.Background(), "paris weather", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].ID != ids[0] {
+		t.Errorf("results = %+v", results)
+	}
+
+	filter := vectorstore.And(vectorstore.Exists("lang"), vectorstore.Not(vectorstore.In("lang", "en")))
+	results, err = m.SimilaritySearch(context.Background(), "paris weather", 3, vectorstore.WithFilter(filter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].ID != ids[2] {
+		t.Errorf("filtered results = %+v", results)
+	}
+}